@@ -0,0 +1,155 @@
+package applescript
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+const (
+	taskID    = "TaskSeeds0000000000001"
+	projectID = "ProjectGarden000000001"
+	areaID    = "AreaHome00000000000001"
+	otherArea = "AreaWork00000000000001"
+)
+
+func script(lines ...string) string {
+	return strings.Join(lines, "\n")
+}
+
+func TestDeleteScripts(t *testing.T) {
+	tests := []struct {
+		name    string
+		build   func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "task",
+			build: func() (string, error) { return DeleteTaskScript(taskID) },
+			want: script(
+				`tell application "Things3"`,
+				`	move (first to do whose id is "TaskSeeds0000000000001") to list "Trash"`,
+				`end tell`),
+		},
+		{
+			name:  "project with its to-dos",
+			build: func() (string, error) { return DeleteProjectScript(projectID, models.ContentsTrash, "") },
+			want: script(
+				`tell application "Things3"`,
+				`	set p to first project whose id is "ProjectGarden000000001"`,
+				`	set taskList to to dos of p`,
+				`	repeat with t in taskList`,
+				`		move t to list "Trash"`,
+				`	end repeat`,
+				`	delete p`,
+				`end tell`),
+		},
+		{
+			name:  "project, to-dos to the Inbox",
+			build: func() (string, error) { return DeleteProjectScript(projectID, models.ContentsInbox, "") },
+			want: script(
+				`tell application "Things3"`,
+				`	set p to first project whose id is "ProjectGarden000000001"`,
+				`	set taskList to to dos of p`,
+				`	repeat with t in taskList`,
+				`		move t to list "Inbox"`,
+				`	end repeat`,
+				`	delete p`,
+				`end tell`),
+		},
+		{
+			name:  "project, to-dos to an area",
+			build: func() (string, error) { return DeleteProjectScript(projectID, models.ContentsArea, otherArea) },
+			want: script(
+				`tell application "Things3"`,
+				`	set p to first project whose id is "ProjectGarden000000001"`,
+				`	set targetArea to first area whose id is "AreaWork00000000000001"`,
+				`	set taskList to to dos of p`,
+				`	repeat with t in taskList`,
+				`		move t to targetArea`,
+				`	end repeat`,
+				`	delete p`,
+				`end tell`),
+		},
+		{
+			name:  "area with its projects",
+			build: func() (string, error) { return DeleteAreaScript(areaID, models.ContentsTrash, "") },
+			want: script(
+				`tell application "Things3"`,
+				`	set a to first area whose id is "AreaHome00000000000001"`,
+				`	set taskList to to dos of a`,
+				`	repeat with t in taskList`,
+				`		move t to list "Trash"`,
+				`	end repeat`,
+				`	set projList to projects of a`,
+				`	repeat with proj in projList`,
+				`		delete proj`,
+				`	end repeat`,
+				`	delete a`,
+				`end tell`),
+		},
+		{
+			name:  "area, contents out of any area",
+			build: func() (string, error) { return DeleteAreaScript(areaID, models.ContentsInbox, "") },
+			want: script(
+				`tell application "Things3"`,
+				`	set a to first area whose id is "AreaHome00000000000001"`,
+				`	set taskList to to dos of a`,
+				`	repeat with t in taskList`,
+				`		move t to list "Inbox"`,
+				`	end repeat`,
+				`	set projList to projects of a`,
+				`	repeat with proj in projList`,
+				`		set area of proj to missing value`,
+				`	end repeat`,
+				`	delete a`,
+				`end tell`),
+		},
+		{
+			name:  "area, contents to another area",
+			build: func() (string, error) { return DeleteAreaScript(areaID, models.ContentsArea, otherArea) },
+			want: script(
+				`tell application "Things3"`,
+				`	set a to first area whose id is "AreaHome00000000000001"`,
+				`	set targetArea to first area whose id is "AreaWork00000000000001"`,
+				`	set taskList to to dos of a`,
+				`	repeat with t in taskList`,
+				`		move t to targetArea`,
+				`	end repeat`,
+				`	set projList to projects of a`,
+				`	repeat with proj in projList`,
+				`		set area of proj to targetArea`,
+				`	end repeat`,
+				`	delete a`,
+				`end tell`),
+		},
+		{
+			name:    "invalid task ID",
+			build:   func() (string, error) { return DeleteTaskScript(`x" to list "Inbox`) },
+			wantErr: true,
+		},
+		{
+			name:    "invalid target area",
+			build:   func() (string, error) { return DeleteProjectScript(projectID, models.ContentsArea, `x"`) },
+			wantErr: true,
+		},
+		{
+			name:    "unknown contents policy",
+			build:   func() (string, error) { return DeleteAreaScript(areaID, "archive", "") },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.build()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("script =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -21,7 +21,8 @@ func GetAllProjects() ([]models.Project, error) {
 			set pArea to name of area of p
 		end try
 		set taskCount to count of to dos of p
-		set output to output & pId & tab & pName & tab & pNotes & tab & pArea & tab & (taskCount as string) & linefeed
+		set pStatus to status of p
+		set output to output & pId & tab & pName & tab & pNotes & tab & pArea & tab & (taskCount as string) & tab & (pStatus as string) & linefeed
 	end repeat
 	return output
 end tell`
@@ -34,7 +35,7 @@ end tell`
 }
 
 // parseProjects parses tab-delimited AppleScript output into Project structs.
-// Expected format per line: id<TAB>name<TAB>notes<TAB>area<TAB>taskCount
+// Expected format per line: id<TAB>name<TAB>notes<TAB>area<TAB>taskCount[<TAB>status]
 func parseProjects(output string) []models.Project {
 	var projects []models.Project
 	if output == "" {
@@ -70,6 +71,9 @@ func parseProjects(output string) []models.Project {
 				project.TaskCount = count
 			}
 		}
+		if len(fields) > 5 {
+			project.Status = normalizeStatus(fields[5])
+		}
 
 		projects = append(projects, project)
 	}
@@ -92,7 +96,8 @@ func GetProjectByID(id string) (*models.Project, error) {
 		set pArea to name of area of p
 	end try
 	set taskCount to count of to dos of p
-	return pId & tab & pName & tab & pNotes & tab & pArea & tab & (taskCount as string)
+	set pStatus to status of p
+	return pId & tab & pName & tab & pNotes & tab & pArea & tab & (taskCount as string) & tab & (pStatus as string)
 end tell`, EscapeString(id))
 
 	out, err := Run(script)
@@ -109,6 +114,17 @@ end tell`, EscapeString(id))
 
 // CreateProject creates a new project in Things 3 and returns the created project.
 func CreateProject(req models.CreateProjectRequest) (*models.Project, error) {
+	out, err := Run(CreateProjectScript(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	newID := strings.TrimSpace(out)
+	return GetProjectByID(newID)
+}
+
+// CreateProjectScript returns the AppleScript that CreateProject runs.
+func CreateProjectScript(req models.CreateProjectRequest) string {
 	props := fmt.Sprintf(`name:"%s"`, EscapeString(req.Name))
 	if req.Notes != "" {
 		props += fmt.Sprintf(`, notes:"%s"`, EscapeString(req.Notes))
@@ -156,21 +172,28 @@ func CreateProject(req models.CreateProjectRequest) (*models.Project, error) {
 		`end tell`,
 	)
 
-	script := strings.Join(scriptParts, "\n")
+	return strings.Join(scriptParts, "\n")
+}
 
-	out, err := Run(script)
+// UpdateProject updates an existing project and returns the updated project.
+func UpdateProject(id string, req models.UpdateProjectRequest) (*models.Project, error) {
+	script, err := UpdateProjectScript(id, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create project: %w", err)
+		return nil, err
 	}
 
-	newID := strings.TrimSpace(out)
-	return GetProjectByID(newID)
+	_, err = Run(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update project %s: %w", id, err)
+	}
+
+	return GetProjectByID(id)
 }
 
-// UpdateProject updates an existing project and returns the updated project.
-func UpdateProject(id string, req models.UpdateProjectRequest) (*models.Project, error) {
+// UpdateProjectScript returns the AppleScript that UpdateProject runs.
+func UpdateProjectScript(id string, req models.UpdateProjectRequest) (string, error) {
 	if err := models.ValidateThingsID(id); err != nil {
-		return nil, err
+		return "", err
 	}
 
 	var scriptParts []string
@@ -204,34 +227,205 @@ func UpdateProject(id string, req models.UpdateProjectRequest) (*models.Project,
 
 	scriptParts = append(scriptParts, `end tell`)
 
-	script := strings.Join(scriptParts, "\n")
+	return strings.Join(scriptParts, "\n"), nil
+}
 
-	_, err := Run(script)
+// SetProjectStatus sets a project's status to "open", "completed" or
+// "canceled". When cascade is set, closing a project applies the same status
+// to its open to-dos, and reopening it reopens to-dos whose status matches
+// the project's previous status.
+func SetProjectStatus(id, status string, cascade bool) error {
+	script, err := ProjectStatusScript(id, status, cascade)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update project %s: %w", id, err)
+		return err
 	}
 
-	return GetProjectByID(id)
+	_, err = Run(script)
+	if err != nil {
+		return fmt.Errorf("failed to set project %s status to %s: %w", id, status, err)
+	}
+	return nil
 }
 
-// CompleteProject marks a project as completed.
-func CompleteProject(id string) error {
+// ProjectStatusScript returns the AppleScript that SetProjectStatus runs.
+func ProjectStatusScript(id, status string, cascade bool) (string, error) {
 	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+	if !models.IsValidStatus(status) {
+		return "", fmt.Errorf("invalid status %q", status)
+	}
+
+	var scriptParts []string
+	scriptParts = append(scriptParts,
+		`tell application "Things3"`,
+		fmt.Sprintf(`	set p to first project whose id is "%s"`, EscapeString(id)),
+	)
+
+	switch {
+	case cascade && status == "open":
+		scriptParts = append(scriptParts,
+			`	set prevStatus to status of p`,
+			`	set status of p to open`,
+			`	if prevStatus is not open then`,
+			`		repeat with t in (to dos of p whose status is prevStatus)`,
+			`			set status of t to open`,
+			`		end repeat`,
+			`	end if`,
+		)
+	case cascade:
+		scriptParts = append(scriptParts,
+			`	repeat with t in (to dos of p whose status is open)`,
+			fmt.Sprintf(`		set status of t to %s`, status),
+			`	end repeat`,
+			fmt.Sprintf(`	set status of p to %s`, status),
+		)
+	default:
+		scriptParts = append(scriptParts,
+			fmt.Sprintf(`	set status of p to %s`, status),
+		)
+	}
+
+	scriptParts = append(scriptParts, `end tell`)
+
+	return strings.Join(scriptParts, "\n"), nil
+}
+
+// DeleteProject moves a project to the Trash. Its to-dos are trashed with it,
+// moved to the Inbox, or moved to the area targetAreaID, depending on contents.
+func DeleteProject(id, contents, targetAreaID string) error {
+	script, err := DeleteProjectScript(id, contents, targetAreaID)
+	if err != nil {
 		return err
 	}
 
-	script := fmt.Sprintf(`tell application "Things3"
-	set p to first project whose id is "%s"
-	set status of p to completed
-end tell`, EscapeString(id))
+	_, err = Run(script)
+	if err != nil {
+		return fmt.Errorf("failed to delete project %s: %w", id, err)
+	}
+	return nil
+}
 
-	_, err := Run(script)
+// DeleteProjectScript returns the AppleScript that DeleteProject runs.
+func DeleteProjectScript(id, contents, targetAreaID string) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+
+	scriptParts := []string{
+		`tell application "Things3"`,
+		fmt.Sprintf(`	set p to first project whose id is "%s"`, EscapeString(id)),
+	}
+	moveParts, err := moveContentsScript("p", contents, targetAreaID, false)
 	if err != nil {
-		return fmt.Errorf("failed to complete project %s: %w", id, err)
+		return "", err
+	}
+	scriptParts = append(scriptParts, moveParts...)
+	scriptParts = append(scriptParts,
+		`	delete p`,
+		`end tell`,
+	)
+
+	return strings.Join(scriptParts, "\n"), nil
+}
+
+// PlaceProject sets the area of a project to areaID, or removes it from its
+// area if areaID is empty. With restore, the project is first taken out of
+// the Trash.
+func PlaceProject(id, areaID string, restore bool) error {
+	if err := models.ValidateThingsID(id); err != nil {
+		return err
+	}
+	if areaID != "" {
+		if err := models.ValidateThingsID(areaID); err != nil {
+			return err
+		}
+	}
+
+	scriptParts := []string{
+		`tell application "Things3"`,
+		fmt.Sprintf(`	set p to first project whose id is "%s"`, EscapeString(id)),
+	}
+	if restore {
+		scriptParts = append(scriptParts, `	move p to list "Anytime"`)
+	}
+	if areaID == "" {
+		scriptParts = append(scriptParts, `	set area of p to missing value`)
+	} else {
+		scriptParts = append(scriptParts,
+			fmt.Sprintf(`	set area of p to (first area whose id is "%s")`, EscapeString(areaID)),
+		)
+	}
+	scriptParts = append(scriptParts, `end tell`)
+
+	_, err := Run(strings.Join(scriptParts, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to move project %s: %w", id, err)
 	}
 	return nil
 }
 
+// moveContentsScript returns AppleScript lines that relocate the contents of
+// the container in variable `varName` according to contents. With
+// withProjects, the container's projects are handled too (areas only).
+func moveContentsScript(varName, contents, targetAreaID string, withProjects bool) ([]string, error) {
+	var parts []string
+	switch contents {
+	case models.ContentsTrash:
+		parts = append(parts,
+			fmt.Sprintf(`	set taskList to to dos of %s`, varName),
+			`	repeat with t in taskList`,
+			`		move t to list "Trash"`,
+			`	end repeat`,
+		)
+		if withProjects {
+			parts = append(parts,
+				fmt.Sprintf(`	set projList to projects of %s`, varName),
+				`	repeat with proj in projList`,
+				`		delete proj`,
+				`	end repeat`,
+			)
+		}
+	case models.ContentsInbox:
+		parts = append(parts,
+			fmt.Sprintf(`	set taskList to to dos of %s`, varName),
+			`	repeat with t in taskList`,
+			`		move t to list "Inbox"`,
+			`	end repeat`,
+		)
+		if withProjects {
+			parts = append(parts,
+				fmt.Sprintf(`	set projList to projects of %s`, varName),
+				`	repeat with proj in projList`,
+				`		set area of proj to missing value`,
+				`	end repeat`,
+			)
+		}
+	case models.ContentsArea:
+		if err := models.ValidateThingsID(targetAreaID); err != nil {
+			return nil, err
+		}
+		parts = append(parts,
+			fmt.Sprintf(`	set targetArea to first area whose id is "%s"`, EscapeString(targetAreaID)),
+			fmt.Sprintf(`	set taskList to to dos of %s`, varName),
+			`	repeat with t in taskList`,
+			`		move t to targetArea`,
+			`	end repeat`,
+		)
+		if withProjects {
+			parts = append(parts,
+				fmt.Sprintf(`	set projList to projects of %s`, varName),
+				`	repeat with proj in projList`,
+				`		set area of proj to targetArea`,
+				`	end repeat`,
+			)
+		}
+	default:
+		return nil, fmt.Errorf("invalid contents policy %q", contents)
+	}
+	return parts, nil
+}
+
 // GetAllAreas retrieves all areas from Things 3.
 func GetAllAreas() ([]models.Area, error) {
 	script := `tell application "Things3"
@@ -337,12 +531,7 @@ end tell`, EscapeString(areaID))
 
 // CreateArea creates a new area in Things 3 and returns the created area.
 func CreateArea(req models.CreateAreaRequest) (*models.Area, error) {
-	script := fmt.Sprintf(`tell application "Things3"
-	set newArea to make new area with properties {name:"%s"}
-	return id of newArea
-end tell`, EscapeString(req.Name))
-
-	out, err := Run(script)
+	out, err := Run(CreateAreaScript(req))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create area: %w", err)
 	}
@@ -351,12 +540,35 @@ end tell`, EscapeString(req.Name))
 	return GetAreaByID(newID)
 }
 
+// CreateAreaScript returns the AppleScript that CreateArea runs.
+func CreateAreaScript(req models.CreateAreaRequest) string {
+	return fmt.Sprintf(`tell application "Things3"
+	set newArea to make new area with properties {name:"%s"}
+	return id of newArea
+end tell`, EscapeString(req.Name))
+}
+
 // UpdateArea updates an existing area and returns the updated area.
 func UpdateArea(id string, req models.UpdateAreaRequest) (*models.Area, error) {
-	if err := models.ValidateThingsID(id); err != nil {
+	script, err := UpdateAreaScript(id, req)
+	if err != nil {
 		return nil, err
 	}
 
+	_, err = Run(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update area %s: %w", id, err)
+	}
+
+	return GetAreaByID(id)
+}
+
+// UpdateAreaScript returns the AppleScript that UpdateArea runs.
+func UpdateAreaScript(id string, req models.UpdateAreaRequest) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+
 	var scriptParts []string
 	scriptParts = append(scriptParts,
 		`tell application "Things3"`,
@@ -371,12 +583,44 @@ func UpdateArea(id string, req models.UpdateAreaRequest) (*models.Area, error) {
 
 	scriptParts = append(scriptParts, `end tell`)
 
-	script := strings.Join(scriptParts, "\n")
+	return strings.Join(scriptParts, "\n"), nil
+}
 
-	_, err := Run(script)
+// DeleteArea deletes an area. Its projects and to-dos are trashed, moved out
+// of the area (to-dos to the Inbox), or moved to the area targetAreaID,
+// depending on contents.
+func DeleteArea(id, contents, targetAreaID string) error {
+	script, err := DeleteAreaScript(id, contents, targetAreaID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update area %s: %w", id, err)
+		return err
 	}
 
-	return GetAreaByID(id)
+	_, err = Run(script)
+	if err != nil {
+		return fmt.Errorf("failed to delete area %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteAreaScript returns the AppleScript that DeleteArea runs.
+func DeleteAreaScript(id, contents, targetAreaID string) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+
+	scriptParts := []string{
+		`tell application "Things3"`,
+		fmt.Sprintf(`	set a to first area whose id is "%s"`, EscapeString(id)),
+	}
+	moveParts, err := moveContentsScript("a", contents, targetAreaID, true)
+	if err != nil {
+		return "", err
+	}
+	scriptParts = append(scriptParts, moveParts...)
+	scriptParts = append(scriptParts,
+		`	delete a`,
+		`end tell`,
+	)
+
+	return strings.Join(scriptParts, "\n"), nil
 }
@@ -295,6 +295,65 @@ end tell`, EscapeString(tag))
 	return parseTasks(out), nil
 }
 
+// GetProjectTasks returns all to-dos in the project with the given ID.
+func GetProjectTasks(projectID string) ([]models.Task, error) {
+	return getTasksByContainerID("project", projectID)
+}
+
+// GetAreaTasks returns the to-dos directly in the area with the given ID
+// (not those inside the area's projects).
+func GetAreaTasks(areaID string) ([]models.Task, error) {
+	return getTasksByContainerID("area", areaID)
+}
+
+// getTasksByContainerID retrieves the to-dos of a project or area by ID.
+// `class` is "project" or "area".
+func getTasksByContainerID(class, id string) ([]models.Task, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return nil, err
+	}
+
+	script := fmt.Sprintf(`tell application "Things3"
+	set c to first %s whose id is "%s"
+	set taskList to to dos of c
+	set output to ""
+	repeat with t in taskList
+		set taskId to id of t
+		set taskName to name of t
+		set taskNotes to notes of t
+		set taskStatus to status of t
+		set projName to ""
+		try
+			set projName to name of project of t
+		end try
+		set areaName to ""
+		try
+			set areaName to name of area of t
+		end try
+		set tagList to ""
+		try
+			set tagList to tag names of t
+		end try
+		set dueVal to "missing value"
+		try
+			set dueVal to due date of t as string
+		end try
+		set createdVal to "missing value"
+		try
+			set createdVal to creation date of t as string
+		end try
+		set output to output & taskId & tab & taskName & tab & taskNotes & tab & (taskStatus as string) & tab & projName & tab & areaName & tab & tagList & tab & dueVal & tab & createdVal & linefeed
+	end repeat
+	return output
+end tell`, class, EscapeString(id))
+
+	out, err := Run(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tasks of %s %s: %w", class, id, err)
+	}
+	return parseTasks(out), nil
+}
+
 // GetTaskByID retrieves a single task by its Things 3 ID.
 func GetTaskByID(id string) (*models.Task, error) {
 	if err := models.ValidateThingsID(id); err != nil {
@@ -344,6 +403,17 @@ end tell`, EscapeString(id))
 
 // CreateTask creates a new task in Things 3 and returns the created task.
 func CreateTask(req models.CreateTaskRequest) (*models.Task, error) {
+	out, err := Run(CreateTaskScript(req))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	newID := strings.TrimSpace(out)
+	return GetTaskByID(newID)
+}
+
+// CreateTaskScript returns the AppleScript that CreateTask runs.
+func CreateTaskScript(req models.CreateTaskRequest) string {
 	// Build the properties portion of the AppleScript.
 	props := fmt.Sprintf(`name:"%s"`, EscapeString(req.Title))
 	if req.Notes != "" {
@@ -430,21 +500,28 @@ func CreateTask(req models.CreateTaskRequest) (*models.Task, error) {
 		`end tell`,
 	)
 
-	script := strings.Join(scriptParts, "\n")
+	return strings.Join(scriptParts, "\n")
+}
 
-	out, err := Run(script)
+// UpdateTask updates an existing task and returns the updated task.
+func UpdateTask(id string, req models.UpdateTaskRequest) (*models.Task, error) {
+	script, err := UpdateTaskScript(id, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create task: %w", err)
+		return nil, err
 	}
 
-	newID := strings.TrimSpace(out)
-	return GetTaskByID(newID)
+	_, err = Run(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task %s: %w", id, err)
+	}
+
+	return GetTaskByID(id)
 }
 
-// UpdateTask updates an existing task and returns the updated task.
-func UpdateTask(id string, req models.UpdateTaskRequest) (*models.Task, error) {
+// UpdateTaskScript returns the AppleScript that UpdateTask runs.
+func UpdateTaskScript(id string, req models.UpdateTaskRequest) (string, error) {
 	if err := models.ValidateThingsID(id); err != nil {
-		return nil, err
+		return "", err
 	}
 
 	var scriptParts []string
@@ -529,31 +606,27 @@ func UpdateTask(id string, req models.UpdateTaskRequest) (*models.Task, error) {
 			)
 		}
 	}
+	if req.Status != nil {
+		// Status values are validated against a fixed list, so they are safe
+		// to embed as AppleScript constants.
+		scriptParts = append(scriptParts,
+			fmt.Sprintf(`	set status of t to %s`, *req.Status),
+		)
+	}
 
 	scriptParts = append(scriptParts, `end tell`)
 
-	script := strings.Join(scriptParts, "\n")
-
-	_, err := Run(script)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update task %s: %w", id, err)
-	}
-
-	return GetTaskByID(id)
+	return strings.Join(scriptParts, "\n"), nil
 }
 
 // CompleteTask marks a task as completed.
 func CompleteTask(id string) error {
-	if err := models.ValidateThingsID(id); err != nil {
+	script, err := TaskStatusScript(id, "completed")
+	if err != nil {
 		return err
 	}
 
-	script := fmt.Sprintf(`tell application "Things3"
-	set t to first to do whose id is "%s"
-	set status of t to completed
-end tell`, EscapeString(id))
-
-	_, err := Run(script)
+	_, err = Run(script)
 	if err != nil {
 		return fmt.Errorf("failed to complete task %s: %w", id, err)
 	}
@@ -562,36 +635,111 @@ end tell`, EscapeString(id))
 
 // CancelTask marks a task as canceled.
 func CancelTask(id string) error {
-	if err := models.ValidateThingsID(id); err != nil {
+	script, err := TaskStatusScript(id, "canceled")
+	if err != nil {
 		return err
 	}
 
-	script := fmt.Sprintf(`tell application "Things3"
-	set t to first to do whose id is "%s"
-	set status of t to canceled
-end tell`, EscapeString(id))
-
-	_, err := Run(script)
+	_, err = Run(script)
 	if err != nil {
 		return fmt.Errorf("failed to cancel task %s: %w", id, err)
 	}
 	return nil
 }
 
+// ReopenTask sets a completed or canceled task back to open.
+func ReopenTask(id string) error {
+	script, err := TaskStatusScript(id, "open")
+	if err != nil {
+		return err
+	}
+
+	_, err = Run(script)
+	if err != nil {
+		return fmt.Errorf("failed to reopen task %s: %w", id, err)
+	}
+	return nil
+}
+
 // DeleteTask moves a task to the Trash list.
 func DeleteTask(id string) error {
-	if err := models.ValidateThingsID(id); err != nil {
+	script, err := DeleteTaskScript(id)
+	if err != nil {
 		return err
 	}
 
-	script := fmt.Sprintf(`tell application "Things3"
-	move (first to do whose id is "%s") to list "Trash"
-end tell`, EscapeString(id))
-
-	_, err := Run(script)
+	_, err = Run(script)
 	if err != nil {
 		return fmt.Errorf("failed to delete task %s: %w", id, err)
 	}
 	return nil
 }
 
+// TaskStatusScript returns the AppleScript that sets a to-do's status to
+// "open", "completed" or "canceled".
+func TaskStatusScript(id, status string) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+	if !models.IsValidStatus(status) {
+		return "", fmt.Errorf("invalid status %q", status)
+	}
+
+	// Status values are validated against a fixed list, so they are safe
+	// to embed as AppleScript constants.
+	return fmt.Sprintf(`tell application "Things3"
+	set t to first to do whose id is "%s"
+	set status of t to %s
+end tell`, EscapeString(id), status), nil
+}
+
+// DeleteTaskScript returns the AppleScript that DeleteTask runs.
+func DeleteTaskScript(id string) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`tell application "Things3"
+	move (first to do whose id is "%s") to list "Trash"
+end tell`, EscapeString(id)), nil
+}
+
+// PlaceTask moves a to-do into the project projectID, else the area areaID,
+// else the Inbox. With restore, the to-do is first taken out of the Trash.
+func PlaceTask(id, projectID, areaID string, restore bool) error {
+	for _, v := range []string{id, projectID, areaID} {
+		if v == "" {
+			continue
+		}
+		if err := models.ValidateThingsID(v); err != nil {
+			return err
+		}
+	}
+
+	scriptParts := []string{
+		`tell application "Things3"`,
+		fmt.Sprintf(`	set t to first to do whose id is "%s"`, EscapeString(id)),
+	}
+	if restore {
+		scriptParts = append(scriptParts, `	move t to list "Inbox"`)
+	}
+	switch {
+	case projectID != "":
+		scriptParts = append(scriptParts,
+			fmt.Sprintf(`	move t to (first project whose id is "%s")`, EscapeString(projectID)),
+		)
+	case areaID != "":
+		scriptParts = append(scriptParts,
+			fmt.Sprintf(`	move t to (first area whose id is "%s")`, EscapeString(areaID)),
+		)
+	case !restore:
+		scriptParts = append(scriptParts, `	move t to list "Inbox"`)
+	}
+	scriptParts = append(scriptParts, `end tell`)
+
+	_, err := Run(strings.Join(scriptParts, "\n"))
+	if err != nil {
+		return fmt.Errorf("failed to move task %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package audit keeps an append-only log of write requests as JSON lines,
+// rotated by size, and the per-request record handlers fill in.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// Log is an append-only audit log file. When it grows past maxSize it is
+// renamed to <path>.1 (shifting older files up) and at most keep rotated
+// files are kept.
+type Log struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	keep    int
+	file    *os.File
+	size    int64
+}
+
+// Open opens or creates the audit log at path.
+func Open(path string, maxSize int64, keep int) (*Log, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create audit log directory: %w", err)
+	}
+	l := &Log{path: path, maxSize: maxSize, keep: keep}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot open audit log: %w", err)
+	}
+	l.file, l.size = f, info.Size()
+	return nil
+}
+
+// Append writes e as one line, rotating first if the file is full.
+func (l *Log) Append(e models.AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("cannot encode audit entry: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.size > 0 && l.size+int64(len(data)) > l.maxSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("cannot write audit log: %w", err)
+	}
+	return nil
+}
+
+// rotate shifts <path>.N files up by one, dropping the oldest, and starts a
+// new file. Caller holds mu.
+func (l *Log) rotate() error {
+	l.file.Close()
+	os.Remove(l.rotated(l.keep))
+	for i := l.keep - 1; i >= 1; i-- {
+		os.Rename(l.rotated(i), l.rotated(i+1))
+	}
+	if l.keep > 0 {
+		if err := os.Rename(l.path, l.rotated(1)); err != nil {
+			return fmt.Errorf("cannot rotate audit log: %w", err)
+		}
+	} else {
+		os.Remove(l.path)
+	}
+	return l.open()
+}
+
+func (l *Log) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", l.path, i)
+}
+
+// Query returns the entries matching f, newest first, up to f.Limit. Files
+// are read from the end, newest first, and reading stops once f.Limit
+// entries have matched.
+func (l *Log) Query(f models.AuditFilter) ([]models.AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := []models.AuditEntry{}
+	paths := []string{l.path}
+	for i := 1; i <= l.keep; i++ {
+		paths = append(paths, l.rotated(i))
+	}
+	for _, path := range paths {
+		err := readReverse(path, func(line []byte) bool {
+			var e models.AuditEntry
+			if json.Unmarshal(line, &e) != nil || !f.Matches(e) {
+				return true
+			}
+			out = append(out, e)
+			return len(out) != f.Limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
+// readChunk is how much of a log file readReverse reads at a time.
+const readChunk = 64 * 1024
+
+// readReverse calls fn with each line of one log file, newest first, until
+// fn returns false. Lines that cannot be parsed (e.g. cut off by a crash)
+// are left to fn to skip. A missing file has no lines.
+func readReverse(path string, fn func(line []byte) bool) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read audit log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read audit log: %w", err)
+	}
+	buf := make([]byte, readChunk)
+	var partial []byte // start of the line cut by the previous chunk
+	for off := info.Size(); off > 0; {
+		n := int(min(int64(len(buf)), off))
+		off -= int64(n)
+		if _, err := f.ReadAt(buf[:n], off); err != nil {
+			return fmt.Errorf("cannot read audit log: %w", err)
+		}
+		data := append(buf[:n:n], partial...)
+		for {
+			i := bytes.LastIndexByte(data, '\n')
+			if i < 0 {
+				break
+			}
+			if line := data[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			data = data[:i]
+		}
+		partial = append(partial[:0:0], data...)
+	}
+	if len(partial) > 0 {
+		fn(partial)
+	}
+	return nil
+}
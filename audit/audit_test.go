@@ -0,0 +1,46 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func TestQueryNewestFirst(t *testing.T) {
+	// Small enough to rotate a few times, large enough for files to span
+	// several read chunks.
+	l, err := Open(filepath.Join(t.TempDir(), "audit.jsonl"), 100*1024, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const total = 5000
+	for i := 0; i < total; i++ {
+		e := models.AuditEntry{ID: fmt.Sprint(i), Method: "POST", Path: "/tasks", Status: 201}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := l.Query(models.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || len(all) == total {
+		t.Fatalf("got %d entries, want some but not all to have rotated out", len(all))
+	}
+	for i, e := range all {
+		if want := fmt.Sprint(total - 1 - i); e.ID != want {
+			t.Fatalf("entry %d has ID %s, want %s", i, e.ID, want)
+		}
+	}
+
+	limited, err := l.Query(models.AuditFilter{Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited) != 3 || limited[0].ID != "4999" || limited[2].ID != "4997" {
+		t.Errorf("limited query = %+v, want the 3 newest entries", limited)
+	}
+}
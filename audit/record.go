@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// Record is the part of an audit entry filled in by the handler: the
+// operation, its targets and changed fields. All methods are safe on a nil
+// *Record, which is what handlers get when auditing is off.
+type Record struct {
+	Operation string
+	Type      string
+	Targets   []string
+	Changes   map[string]models.AuditChange
+	DryRun    bool
+	Replayed  bool
+}
+
+type recordKey struct{}
+
+// WithRecord returns a copy of ctx carrying rec.
+func WithRecord(ctx context.Context, rec *Record) context.Context {
+	return context.WithValue(ctx, recordKey{}, rec)
+}
+
+// FromContext returns the record of the current request, or nil.
+func FromContext(ctx context.Context) *Record {
+	rec, _ := ctx.Value(recordKey{}).(*Record)
+	return rec
+}
+
+// Set names the operation (such as "task.update") and item type.
+func (r *Record) Set(operation, itemType string, targets ...string) {
+	if r == nil {
+		return
+	}
+	r.Operation, r.Type = operation, itemType
+	r.AddTarget(targets...)
+}
+
+// AddTarget adds target IDs, e.g. the ID of a created item.
+func (r *Record) AddTarget(ids ...string) {
+	if r == nil {
+		return
+	}
+	for _, id := range ids {
+		if id != "" {
+			r.Targets = append(r.Targets, id)
+		}
+	}
+}
+
+// MarkDryRun notes that the write was only previewed.
+func (r *Record) MarkDryRun() {
+	if r != nil {
+		r.DryRun = true
+	}
+}
+
+// MarkReplay notes that the response was replayed from an earlier request
+// with the same Idempotency-Key, whose operation, type and targets it takes;
+// nothing was changed.
+func (r *Record) MarkReplay(operation, itemType string, targets []string) {
+	if r == nil {
+		return
+	}
+	r.Replayed = true
+	r.Operation, r.Type, r.Targets = operation, itemType, targets
+}
+
+// Change records a single field change.
+func (r *Record) Change(field string, before, after any) {
+	if r == nil || reflect.DeepEqual(before, after) {
+		return
+	}
+	if r.Changes == nil {
+		r.Changes = make(map[string]models.AuditChange)
+	}
+	r.Changes[field] = models.AuditChange{Before: before, After: after}
+}
+
+// Diff records the fields set in req (a request body whose unset fields
+// encode as null) with their values in before (the item as it was, or nil
+// for a create). Fields whose value does not change are left out.
+func (r *Record) Diff(before, req any) {
+	if r == nil {
+		return
+	}
+	beforeFields := fields(before)
+	for name, after := range fields(req) {
+		if after != nil {
+			r.Change(name, beforeFields[name], after)
+		}
+	}
+}
+
+// Removed records every field of the deleted item v as changed to null.
+func (r *Record) Removed(v any) {
+	if r == nil {
+		return
+	}
+	for name, before := range fields(v) {
+		if name != "id" && before != nil {
+			r.Change(name, before, nil)
+		}
+	}
+}
+
+// fields returns the JSON fields of v.
+func fields(v any) map[string]any {
+	m := map[string]any{}
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return m
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(data, &m)
+	return m
+}
@@ -0,0 +1,101 @@
+// Package auth manages API tokens, the authenticated client of a request and
+// the scopes each route requires.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// DefaultClient is the name of the admin client that authenticates with the
+// legacy THINGS_API_TOKEN. Tokens cannot use it, as idempotency keys, rate
+// limits, the audit log and the undo journal tell clients apart by name.
+const DefaultClient = "default"
+
+// Client is the authenticated caller of a request. Areas and Projects are
+// the allowlists of a restricted client; both empty means no restriction.
+// A ReadOnly client may only read.
+type Client struct {
+	Name     string
+	Scopes   []string
+	Areas    []string
+	Projects []string
+	ReadOnly bool
+}
+
+// Restricted reports whether the client is limited to some areas or projects.
+func (c *Client) Restricted() bool {
+	return len(c.Areas) > 0 || len(c.Projects) > 0
+}
+
+// HasScope reports whether the client was granted scope. The admin scope
+// grants every scope.
+func (c *Client) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope || s == models.ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+type clientKey struct{}
+
+// WithClient returns a copy of ctx carrying c.
+func WithClient(ctx context.Context, c *Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, c)
+}
+
+// ClientFrom returns the client stored in ctx, if any.
+func ClientFrom(ctx context.Context) (*Client, bool) {
+	c, ok := ctx.Value(clientKey{}).(*Client)
+	return c, ok
+}
+
+// RequiredScope returns the scope needed for a request. Reads need
+// tasks:read. Writes to tasks and smart lists, and undos, need tasks:write
+// (undo checks the scope of the operation itself); writes to projects,
+// areas, templates and imports need projects:write. Webhooks, the audit log,
+// backups and anything else need admin.
+func RequiredScope(method, path string) string {
+	if hasPrefix(path, "/webhooks") || hasPrefix(path, "/audit") || hasPrefix(path, "/admin") {
+		return models.ScopeAdmin
+	}
+	if method == http.MethodGet || method == http.MethodHead {
+		return models.ScopeTasksRead
+	}
+	switch {
+	case hasPrefix(path, "/tasks"), hasPrefix(path, "/smart-lists"), hasPrefix(path, "/undo"):
+		return models.ScopeTasksWrite
+	case hasPrefix(path, "/projects"), hasPrefix(path, "/areas"),
+		hasPrefix(path, "/templates"), hasPrefix(path, "/import"):
+		return models.ScopeProjectsWrite
+	default:
+		return models.ScopeAdmin
+	}
+}
+
+// RequiresFullAccess reports whether a request is unavailable to restricted
+// clients because its results cannot be limited to their areas and
+// projects: the change feed, webhooks, the audit log, backups, sync, imports,
+// database profiles and template instantiation.
+func RequiresFullAccess(method, path string) bool {
+	switch {
+	case hasPrefix(path, "/events"), hasPrefix(path, "/webhooks"), hasPrefix(path, "/audit"),
+		hasPrefix(path, "/admin"), hasPrefix(path, "/sync"), hasPrefix(path, "/import"),
+		hasPrefix(path, "/profiles"):
+		return true
+	case hasPrefix(path, "/templates"):
+		return method == http.MethodPost && strings.HasSuffix(strings.TrimSuffix(path, "/"), "/instantiate")
+	default:
+		return false
+	}
+}
+
+// hasPrefix matches prefix as a whole path segment.
+func hasPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// tokenPrefix marks API token secrets so they are easy to recognise.
+const tokenPrefix = "t3_"
+
+// lastUsedResolution limits how often LastUsedAt is written to disk.
+const lastUsedResolution = time.Minute
+
+// Tokens is the file-backed token store. The token CLI writes the token
+// file while the server runs, so the server reloads it when it changes and
+// never writes it back. Last use is kept in a separate usage collection,
+// keyed by token ID, that only the server writes.
+type Tokens struct {
+	mu    sync.Mutex
+	items *store.Collection[models.APIToken]
+	usage *store.Collection[string]
+}
+
+// NewTokens wraps a token collection and the collection recording when each
+// token was last used.
+func NewTokens(items *store.Collection[models.APIToken], usage *store.Collection[string]) *Tokens {
+	return &Tokens{items: items, usage: usage}
+}
+
+// List returns all tokens ordered by name, with their last use.
+func (t *Tokens) List() []models.APIToken {
+	t.reload()
+	list := t.items.List()
+	for i := range list {
+		if last, ok := t.usage.Get(list[i].ID); ok {
+			list[i].LastUsedAt = last
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// reload picks up token and usage changes made by another process. On
+// error the tokens already loaded stay in use.
+func (t *Tokens) reload() {
+	if _, err := t.items.Reload(); err != nil {
+		slog.Error("cannot reload tokens", "error", err)
+	}
+	if _, err := t.usage.Reload(); err != nil {
+		slog.Error("cannot reload token usage", "error", err)
+	}
+}
+
+// Create mints a token and returns its secret, which is not stored and
+// cannot be retrieved later. Token names are unique and cannot be
+// DefaultClient.
+func (t *Tokens) Create(req models.CreateTokenRequest) (string, models.APIToken, error) {
+	if req.Name == DefaultClient {
+		return "", models.APIToken{}, fmt.Errorf("the token name %q is reserved for THINGS_API_TOKEN", DefaultClient)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reload()
+
+	for _, tok := range t.items.List() {
+		if tok.Name == req.Name {
+			return "", models.APIToken{}, fmt.Errorf("a token named %q already exists", req.Name)
+		}
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", models.APIToken{}, fmt.Errorf("cannot generate token: %w", err)
+	}
+	secret := tokenPrefix + hex.EncodeToString(b)
+
+	now := time.Now().UTC()
+	tok := models.APIToken{
+		ID:        store.NewID(),
+		Name:      req.Name,
+		Hash:      hashSecret(secret),
+		Scopes:    req.Scopes,
+		Areas:     req.Areas,
+		Projects:  req.Projects,
+		ReadOnly:  req.ReadOnly,
+		CreatedAt: now.Format(time.RFC3339),
+	}
+	if req.ExpiresIn > 0 {
+		tok.ExpiresAt = now.Add(req.ExpiresIn).Format(time.RFC3339)
+	}
+	if err := t.items.Put(tok.ID, tok); err != nil {
+		return "", models.APIToken{}, err
+	}
+	return secret, tok, nil
+}
+
+// Revoke deletes the token with the given name or ID.
+func (t *Tokens) Revoke(nameOrID string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reload()
+
+	for _, tok := range t.items.List() {
+		if tok.ID == nameOrID || tok.Name == nameOrID {
+			ok, err := t.items.Delete(tok.ID)
+			if ok {
+				if _, err := t.usage.Delete(tok.ID); err != nil {
+					slog.Error("cannot remove token usage", "token", tok.Name, "error", err)
+				}
+			}
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Authenticate returns the unexpired token matching secret and records its
+// use. Tokens created or revoked since the last call take effect at once.
+func (t *Tokens) Authenticate(secret string) (models.APIToken, bool) {
+	hash := hashSecret(secret)
+	now := time.Now().UTC()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reload()
+
+	for _, tok := range t.items.List() {
+		if subtle.ConstantTimeCompare([]byte(tok.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if tok.Expired(now) {
+			return models.APIToken{}, false
+		}
+		prev, _ := t.usage.Get(tok.ID)
+		last, err := time.Parse(time.RFC3339, prev)
+		if err != nil || now.Sub(last) >= lastUsedResolution {
+			if err := t.usage.Put(tok.ID, now.Format(time.RFC3339)); err != nil {
+				slog.Error("cannot record token use", "token", tok.Name, "error", err)
+			}
+		}
+		return tok, true
+	}
+	return models.APIToken{}, false
+}
+
+// ClientFor returns the request identity for tok.
+func ClientFor(tok models.APIToken) *Client {
+	return &Client{Name: tok.Name, Scopes: tok.Scopes, Areas: tok.Areas, Projects: tok.Projects, ReadOnly: tok.ReadOnly}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
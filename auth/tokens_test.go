@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+func TestCreateRejectsTakenNames(t *testing.T) {
+	dir := t.TempDir()
+	items, err := store.Open[models.APIToken](filepath.Join(dir, "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usage, err := store.Open[string](filepath.Join(dir, "token-usage.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokens(items, usage)
+
+	if _, _, err := tokens.Create(models.CreateTokenRequest{Name: "bot", Scopes: []string{models.ScopeTasksRead}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bot", DefaultClient} {
+		if _, _, err := tokens.Create(models.CreateTokenRequest{Name: name, Scopes: []string{models.ScopeTasksRead}}); err == nil {
+			t.Errorf("Create(%q) succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package backups keeps copies of the Things database in a directory.
+//
+// Backups are written with the SQLite backup API, so they are consistent
+// even while Things is writing, and are checked before they are kept. Each
+// is named after the time it was made and what caused it:
+//
+//	things-20260110T030000Z-scheduled.sqlite
+//
+// Only the most recent manual and scheduled backups are kept; copies taken
+// before a restore are left for the user to remove.
+package backups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// stampLayout is the time format in backup names (UTC).
+const stampLayout = "20060102T150405Z"
+
+var namePattern = regexp.MustCompile(`^things-(\d{8}T\d{6}Z)-(manual|scheduled|pre-restore)\.sqlite$`)
+
+var (
+	// ErrNotFound is returned for a backup name that does not exist.
+	ErrNotFound = errors.New("backup not found")
+
+	// ErrExists is returned when a backup with the same trigger was already
+	// made in the same second.
+	ErrExists = errors.New("backup already exists")
+)
+
+// Manager creates, lists and prunes the backups in a directory.
+type Manager struct {
+	dir  string
+	keep int
+
+	mu sync.Mutex
+}
+
+// NewManager returns a manager for the backups in dir that keeps the keep
+// most recent manual and scheduled backups; 0 keeps all of them.
+func NewManager(dir string, keep int) *Manager {
+	return &Manager{dir: dir, keep: keep}
+}
+
+// Dir returns the backup directory.
+func (m *Manager) Dir() string {
+	return m.dir
+}
+
+// Create backs up the database, then removes the oldest backups beyond the
+// retention limit. The copy is written under a temporary name and only
+// renamed once it has been verified.
+func (m *Manager) Create(trigger string) (models.Backup, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return models.Backup{}, fmt.Errorf("cannot create backup directory: %w", err)
+	}
+	name := fmt.Sprintf("things-%s-%s.sqlite", time.Now().UTC().Format(stampLayout), trigger)
+	if !namePattern.MatchString(name) {
+		return models.Backup{}, fmt.Errorf("unknown backup trigger %q", trigger)
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return models.Backup{}, fmt.Errorf("%w: %s", ErrExists, name)
+	}
+
+	tmp := path + ".partial"
+	if err := database.BackupTo(tmp); err != nil {
+		os.Remove(tmp)
+		return models.Backup{}, err
+	}
+	if _, err := database.VerifyBackup(tmp); err != nil {
+		os.Remove(tmp)
+		return models.Backup{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return models.Backup{}, fmt.Errorf("cannot save backup: %w", err)
+	}
+
+	if err := m.prune(); err != nil {
+		slog.Warn("backups: cannot remove old backups", "error", err)
+	}
+	return backupInfo(path)
+}
+
+// List returns the backups, newest first.
+func (m *Manager) List() ([]models.Backup, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []models.Backup{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read backup directory: %w", err)
+	}
+
+	backups := []models.Backup{}
+	for _, e := range entries {
+		if e.IsDir() || !namePattern.MatchString(e.Name()) {
+			continue
+		}
+		b, err := backupInfo(filepath.Join(m.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, b)
+	}
+	// Names sort by time; break ties on the name itself.
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].CreatedAt != backups[j].CreatedAt {
+			return backups[i].CreatedAt > backups[j].CreatedAt
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// Path returns the path of the backup called name.
+func (m *Manager) Path(name string) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", ErrNotFound
+	}
+	path := filepath.Join(m.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Run makes a scheduled backup every day at the local time at ("15:04")
+// until ctx is canceled.
+func (m *Manager) Run(ctx context.Context, at string) {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		slog.Error("backups: invalid schedule", "at", at, "error", err)
+		return
+	}
+
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		b, err := m.Create(models.BackupScheduled)
+		if err != nil {
+			slog.Error("backups: scheduled backup failed", "error", err)
+			continue
+		}
+		slog.Info("backups: saved scheduled backup", "name", b.Name, "size", b.Size)
+	}
+}
+
+// prune removes the oldest manual and scheduled backups beyond keep.
+func (m *Manager) prune() error {
+	if m.keep == 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, b := range backups {
+		if b.Trigger == models.BackupPreRestore {
+			continue
+		}
+		kept++
+		if kept <= m.keep {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, b.Name)); err != nil {
+			return err
+		}
+		slog.Info("backups: removed old backup", "name", b.Name)
+	}
+	return nil
+}
+
+func backupInfo(path string) (models.Backup, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Backup{}, fmt.Errorf("cannot read backup: %w", err)
+	}
+	m := namePattern.FindStringSubmatch(info.Name())
+	created, _ := time.Parse(stampLayout, m[1])
+	return models.Backup{
+		Name:      info.Name(),
+		Trigger:   m[2],
+		Size:      info.Size(),
+		CreatedAt: created.Format(time.RFC3339),
+	}, nil
+}
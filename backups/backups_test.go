@@ -0,0 +1,64 @@
+package backups
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	files := []string{
+		"things-20260110T030000Z-scheduled.sqlite",
+		"things-20260111T030000Z-scheduled.sqlite",
+		"things-20260111T120000Z-manual.sqlite",
+		"things-20260112T030000Z-pre-restore.sqlite",
+		"things-20260112T030000Z-scheduled.sqlite",
+		"things-20260113T030000Z-scheduled.sqlite.partial",
+		"notes.txt",
+	}
+	tests := []struct {
+		name string
+		keep int
+		want []string // removed
+	}{
+		{name: "keep all", keep: 0},
+		{name: "within the limit", keep: 4},
+		{
+			name: "oldest beyond the limit",
+			keep: 2,
+			want: []string{"things-20260110T030000Z-scheduled.sqlite", "things-20260111T030000Z-scheduled.sqlite"},
+		},
+		{
+			name: "manual and scheduled count together",
+			keep: 1,
+			want: []string{"things-20260110T030000Z-scheduled.sqlite", "things-20260111T030000Z-scheduled.sqlite", "things-20260111T120000Z-manual.sqlite"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range files {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := NewManager(dir, tt.keep).prune(); err != nil {
+				t.Fatal(err)
+			}
+
+			var removed []string
+			for _, name := range files {
+				if _, err := os.Stat(filepath.Join(dir, name)); os.IsNotExist(err) {
+					removed = append(removed, name)
+				}
+			}
+			sort.Strings(removed)
+			if !reflect.DeepEqual(removed, tt.want) {
+				t.Errorf("removed %q, want %q", removed, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/backups"
+	"github.com/egorkaBurkenya/things3-api/config"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+const usage = `Usage:
+  things3-api                          start the server
+  things3-api token create -name NAME -scopes SCOPES [-areas IDS] [-projects IDS] [-read-only] [-expires DURATION]
+  things3-api token list
+  things3-api token revoke NAME|ID
+  things3-api backup create
+  things3-api backup list
+  things3-api backup restore [-yes] NAME|PATH
+  things3-api databases                list the Things databases found on this Mac
+
+Scopes: tasks:read, tasks:write, projects:write, admin (comma-separated).
+`
+
+// runCommand runs a command-line subcommand and returns the exit code.
+func runCommand(cfg *config.Config, args []string) int {
+	switch args[0] {
+	case "token":
+		return runTokenCommand(cfg, args[1:])
+	case "backup":
+		return runBackupCommand(cfg, args[1:])
+	case "databases":
+		return listDatabases(cfg)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func openTokens(cfg *config.Config) (*auth.Tokens, error) {
+	items, err := store.Open[models.APIToken](filepath.Join(cfg.DataDir, "tokens.json"))
+	if err != nil {
+		return nil, err
+	}
+	usage, err := store.Open[string](filepath.Join(cfg.DataDir, "token-usage.json"))
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewTokens(items, usage), nil
+}
+
+func runTokenCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	tokens, err := openTokens(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+		name := fs.String("name", "", "client name, e.g. phone-shortcut")
+		scopes := fs.String("scopes", "", "comma-separated scopes")
+		areas := fs.String("areas", "", "comma-separated area IDs the token is limited to")
+		projects := fs.String("projects", "", "comma-separated project IDs the token is limited to")
+		readOnly := fs.Bool("read-only", false, "only allow GET and HEAD requests")
+		expires := fs.Duration("expires", 0, "lifetime, e.g. 720h (default: never)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+
+		req := models.CreateTokenRequest{
+			Name:      *name,
+			Scopes:    splitList(*scopes),
+			Areas:     splitList(*areas),
+			Projects:  splitList(*projects),
+			ReadOnly:  *readOnly,
+			ExpiresIn: *expires,
+		}
+		if err := req.Validate(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		secret, tok, err := tokens.Create(req)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Created token %q (%s). Store it now; it will not be shown again:\n\n%s\n", tok.Name, tok.ID, secret)
+		return 0
+
+	case "list":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tAREAS\tPROJECTS\tMODE\tEXPIRES\tLAST USED")
+		for _, tok := range tokens.List() {
+			mode := "read-write"
+			if tok.ReadOnly {
+				mode = "read-only"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", tok.ID, tok.Name,
+				strings.Join(tok.Scopes, ","), orDash(strings.Join(tok.Areas, ",")),
+				orDash(strings.Join(tok.Projects, ",")), mode, orDash(tok.ExpiresAt), orDash(tok.LastUsedAt))
+		}
+		tw.Flush()
+		return 0
+
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		ok, err := tokens.Revoke(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if !ok {
+			fmt.Fprintf(os.Stderr, "token %q not found\n", args[1])
+			return 1
+		}
+		fmt.Printf("Revoked token %q\n", args[1])
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown token command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+func runBackupCommand(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	m := backups.NewManager(cfg.BackupDir, cfg.BackupKeep)
+
+	switch args[0] {
+	case "create":
+		b, err := m.Create(models.BackupManual)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("Saved backup %s (%d bytes) in %s\n", b.Name, b.Size, m.Dir())
+		return 0
+
+	case "list":
+		list, err := m.List()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tTRIGGER\tSIZE\tCREATED")
+		for _, b := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", b.Name, b.Trigger, b.Size, b.CreatedAt)
+		}
+		tw.Flush()
+		return 0
+
+	case "restore":
+		fs := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+		yes := fs.Bool("yes", false, "do not ask for confirmation")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, usage)
+			return 2
+		}
+		if err := restoreBackup(cfg, m, fs.Arg(0), *yes); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+
+	default:
+		fmt.Fprintf(os.Stderr, "unknown backup command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// listDatabases prints the Things databases in the Things container and
+// which one the server uses.
+func listDatabases(cfg *config.Config) int {
+	found, err := database.FindDatabases()
+	if err != nil && cfg.DBPath == "" {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, path := range found {
+		fmt.Println(path)
+	}
+
+	switch path, err := database.DatabasePath(); {
+	case cfg.DBPath != "":
+		fmt.Printf("\nUsing %s (THINGS_DB_PATH)\n", path)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "\n%v\n", err)
+		return 1
+	default:
+		fmt.Printf("\nUsing %s\n", path)
+	}
+	return 0
+}
+
+// restoreBackup replaces the Things database with a backup, given by name or
+// path. It refuses while Things or the server is running and when the
+// backup is damaged or from a different database version, and saves a
+// pre-restore backup of the current database first.
+func restoreBackup(cfg *config.Config, m *backups.Manager, arg string, yes bool) error {
+	if database.ReadOnlyBuild {
+		return fmt.Errorf("restore is not available in a read-only build")
+	}
+
+	src, err := m.Path(arg)
+	if err != nil {
+		if _, statErr := os.Stat(arg); statErr != nil {
+			return fmt.Errorf("backup %q not found in %s", arg, m.Dir())
+		}
+		src = arg
+	}
+
+	if applescript.IsThings3Running() {
+		return fmt.Errorf("Things 3 is running; quit it before restoring")
+	}
+	if conn, err := net.DialTimeout("tcp", cfg.Addr(), time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("things3-api is running on %s; stop it before restoring", cfg.Addr())
+	}
+
+	backupVersion, err := database.VerifyBackup(src)
+	if err != nil {
+		return err
+	}
+	currentVersion, err := database.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if backupVersion != currentVersion {
+		return fmt.Errorf("backup is database version %d but Things uses version %d; it cannot be restored", backupVersion, currentVersion)
+	}
+
+	dbPath, err := database.DatabasePath()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("This replaces the Things database\n  %s\nwith the backup\n  %s\n", dbPath, src)
+	if !yes {
+		fmt.Print("Type \"restore\" to continue: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(answer) != "restore" {
+			return fmt.Errorf("restore canceled")
+		}
+	}
+
+	pre, err := m.Create(models.BackupPreRestore)
+	if err != nil {
+		return fmt.Errorf("cannot back up the current database, nothing was restored: %w", err)
+	}
+	fmt.Printf("Saved the current database as %s\n", pre.Name)
+
+	if err := database.RestoreFrom(src); err != nil {
+		return err
+	}
+	fmt.Println("Restored. With Things Cloud on, changes synced after the backup was made may come back when Things syncs.")
+	return nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
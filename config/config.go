@@ -3,24 +3,68 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	Token         string
-	Port          string
-	Host          string
-	LogLevel      string
+	Token          string
+	Port           string
+	Host           string
+	LogLevel       string
 	ThingsURLToken string
+	DataDir        string
+	EventsInterval time.Duration
+	IdempotencyTTL time.Duration
+	UndoRetention  time.Duration
+
+	// DryRun makes every write a dry run, as if dry_run=true were passed.
+	DryRun bool
+
+	// ReadOnly rejects every request other than GET and HEAD.
+	ReadOnly bool
+
+	// DirectWrites allows writes straight to the Things database where the
+	// URL scheme cannot make the change. DirectWritesRequireQuit refuses
+	// them while Things is running instead of only logging a warning.
+	DirectWrites            bool
+	DirectWritesRequireQuit bool
+
+	// BackupDir holds database backups. BackupKeep is how many manual and
+	// scheduled backups are kept (0 keeps all); BackupAt is the local time
+	// of day ("15:04") of the daily backup, empty for none.
+	BackupDir  string
+	BackupKeep int
+	BackupAt   string
+
+	// DBPath is the Things database to use instead of the one found in the
+	// Things container. Profiles maps names to other databases served
+	// read-only under /profiles/{name}.
+	DBPath   string
+	Profiles map[string]string
+
+	// Per-client request budgets (per minute) and concurrency cap; 0 disables.
+	RateLimitRead  int
+	RateLimitWrite int
+	MaxConcurrent  int
+
+	// Failed authentication attempts with one credential from one IP
+	// before that credential is locked out there for AuthLockout, and
+	// failed attempts allowed per IP per minute; 0 disables either.
+	AuthMaxFailures       int
+	AuthLockout           time.Duration
+	AuthFailuresPerMinute int
 }
 
 func Load() (*Config, error) {
 	loadDotEnv()
 
+	// THINGS_API_TOKEN is optional once tokens have been created with
+	// "things3-api token create"; main checks that at least one exists.
 	token := os.Getenv("THINGS_API_TOKEN")
-	if token == "" {
-		return nil, fmt.Errorf("THINGS_API_TOKEN environment variable is required")
-	}
 
 	port := os.Getenv("THINGS_API_PORT")
 	if port == "" {
@@ -39,15 +83,200 @@ func Load() (*Config, error) {
 
 	thingsURLToken := os.Getenv("THINGS_URL_TOKEN")
 
+	dataDir := os.Getenv("THINGS_API_DATA_DIR")
+	if dataDir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot determine home directory for THINGS_API_DATA_DIR: %w", err)
+		}
+		dataDir = filepath.Join(home, ".things3-api")
+	}
+
+	eventsInterval := 2 * time.Second
+	if v := os.Getenv("THINGS_API_EVENTS_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("THINGS_API_EVENTS_INTERVAL must be a positive duration such as 2s")
+		}
+		eventsInterval = d
+	}
+
+	idempotencyTTL := 24 * time.Hour
+	if v := os.Getenv("THINGS_API_IDEMPOTENCY_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("THINGS_API_IDEMPOTENCY_TTL must be a positive duration such as 24h")
+		}
+		idempotencyTTL = d
+	}
+
+	undoRetention := 7 * 24 * time.Hour
+	if v := os.Getenv("THINGS_API_UNDO_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("THINGS_API_UNDO_RETENTION must be a positive duration such as 168h")
+		}
+		undoRetention = d
+	}
+
+	dryRun, err := boolEnv("THINGS_API_DRY_RUN")
+	if err != nil {
+		return nil, err
+	}
+
+	readOnly, err := boolEnv("THINGS_API_READ_ONLY")
+	if err != nil {
+		return nil, err
+	}
+
+	directWrites, err := boolEnv("THINGS_API_DIRECT_WRITES")
+	if err != nil {
+		return nil, err
+	}
+	directWritesRequireQuit, err := boolEnv("THINGS_API_DIRECT_WRITES_REQUIRE_QUIT")
+	if err != nil {
+		return nil, err
+	}
+
+	backupDir := os.Getenv("THINGS_API_BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = filepath.Join(dataDir, "backups")
+	}
+	backupKeep, err := intEnv("THINGS_API_BACKUP_KEEP", 7)
+	if err != nil {
+		return nil, err
+	}
+	backupAt := os.Getenv("THINGS_API_BACKUP_AT")
+	if backupAt != "" {
+		if _, err := time.Parse("15:04", backupAt); err != nil {
+			return nil, fmt.Errorf("THINGS_API_BACKUP_AT must be a time of day such as 03:00")
+		}
+	}
+
+	dbPath := os.Getenv("THINGS_DB_PATH")
+	profiles, err := parseProfiles(os.Getenv("THINGS_API_PROFILES"))
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitRead, err := intEnv("THINGS_API_RATE_LIMIT_READ", 120)
+	if err != nil {
+		return nil, err
+	}
+	rateLimitWrite, err := intEnv("THINGS_API_RATE_LIMIT_WRITE", 30)
+	if err != nil {
+		return nil, err
+	}
+	maxConcurrent, err := intEnv("THINGS_API_MAX_CONCURRENT", 4)
+	if err != nil {
+		return nil, err
+	}
+	authMaxFailures, err := intEnv("THINGS_API_AUTH_MAX_FAILURES", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	authFailuresPerMinute, err := intEnv("THINGS_API_AUTH_FAILURES_PER_MINUTE", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	authLockout := 15 * time.Minute
+	if v := os.Getenv("THINGS_API_AUTH_LOCKOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("THINGS_API_AUTH_LOCKOUT must be a positive duration such as 15m")
+		}
+		authLockout = d
+	}
+
 	return &Config{
 		Token:          token,
 		Port:           port,
 		Host:           host,
 		LogLevel:       logLevel,
 		ThingsURLToken: thingsURLToken,
+		DataDir:        dataDir,
+		EventsInterval: eventsInterval,
+		IdempotencyTTL: idempotencyTTL,
+		UndoRetention:  undoRetention,
+
+		DryRun:   dryRun,
+		ReadOnly: readOnly,
+
+		DirectWrites:            directWrites,
+		DirectWritesRequireQuit: directWritesRequireQuit,
+
+		BackupDir:  backupDir,
+		BackupKeep: backupKeep,
+		BackupAt:   backupAt,
+
+		DBPath:   dbPath,
+		Profiles: profiles,
+
+		RateLimitRead:  rateLimitRead,
+		RateLimitWrite: rateLimitWrite,
+		MaxConcurrent:  maxConcurrent,
+
+		AuthMaxFailures:       authMaxFailures,
+		AuthLockout:           authLockout,
+		AuthFailuresPerMinute: authFailuresPerMinute,
 	}, nil
 }
 
+var profileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// parseProfiles parses THINGS_API_PROFILES, a comma-separated list of
+// name=path pairs such as "archive=/backups/things-2024.sqlite".
+func parseProfiles(v string) (map[string]string, error) {
+	profiles := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(pair, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("THINGS_API_PROFILES: %q must be name=path", pair)
+		}
+		if !profileName.MatchString(name) {
+			return nil, fmt.Errorf("THINGS_API_PROFILES: profile name %q must be lower-case letters, digits, - and _", name)
+		}
+		if _, dup := profiles[name]; dup {
+			return nil, fmt.Errorf("THINGS_API_PROFILES: profile %q is listed twice", name)
+		}
+		profiles[name] = path
+	}
+	return profiles, nil
+}
+
+// intEnv reads a non-negative integer environment variable.
+func intEnv(name string, def int) (int, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return n, nil
+}
+
+// boolEnv reads a boolean environment variable; unset means false.
+func boolEnv(name string) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
 func (c *Config) Addr() string {
 	return c.Host + ":" + c.Port
 }
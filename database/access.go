@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// Containers returns every project that is not in the trash, with its area,
+// and every area.
+func Containers() ([]models.Container, error) {
+	var rows []struct {
+		Type  string  `json:"type"`
+		UUID  string  `json:"uuid"`
+		Title string  `json:"title"`
+		Area  *string `json:"area"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT '%[2]s' AS type, uuid, COALESCE(title, '') AS title, area FROM TMTask WHERE type = %[1]d AND trashed = 0
+		 UNION ALL
+		 SELECT '%[3]s', uuid, COALESCE(title, ''), NULL FROM TMArea`,
+		taskTypeProject, models.ItemTypeProject, models.ItemTypeArea,
+	)
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read projects and areas: %w", err)
+	}
+
+	containers := make([]models.Container, len(rows))
+	for i, r := range rows {
+		containers[i] = models.Container{ID: r.UUID, Type: r.Type, Name: r.Title, AreaID: str(r.Area)}
+	}
+	return containers, nil
+}
+
+// TaskContainers returns the project and area IDs of every to-do, keyed by
+// to-do ID. To-dos under a heading report the heading's project.
+func TaskContainers() (map[string]models.TaskContainer, error) {
+	var rows []struct {
+		UUID    string  `json:"uuid"`
+		Project *string `json:"project"`
+		Area    *string `json:"area"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT t.uuid, COALESCE(t.project, h.project) AS project, t.area
+		 FROM TMTask t LEFT JOIN TMTask h ON h.uuid = t.heading
+		 WHERE t.type = %d`,
+		taskTypeToDo,
+	)
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read task containers: %w", err)
+	}
+
+	containers := make(map[string]models.TaskContainer, len(rows))
+	for _, r := range rows {
+		containers[r.UUID] = models.TaskContainer{ProjectID: str(r.Project), AreaID: str(r.Area)}
+	}
+	return containers, nil
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// snapshot copies the database into dir and returns the copy's path.
+func snapshot(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("cannot create snapshot directory: %w", err)
+	}
+	dest := filepath.Join(dir, "main-"+time.Now().UTC().Format("20060102T150405Z")+".sqlite")
+	if err := BackupTo(dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// DatabasePath returns the path of the Things database.
+func DatabasePath() (string, error) {
+	return thingsDBPath()
+}
+
+// BackupTo writes a consistent copy of the database to dest with the SQLite
+// backup API, which also copies changes not yet checkpointed from the WAL.
+// The copy is switched out of WAL mode so that it is a single file.
+func BackupTo(dest string) error {
+	dbPath, err := thingsDBPath()
+	if err != nil {
+		return err
+	}
+
+	for _, args := range [][]string{
+		{"-cmd", ".timeout 5000", dbPath, fmt.Sprintf(".backup %q", dest)},
+		{dest, "PRAGMA journal_mode = DELETE"},
+	} {
+		out, err := exec.Command("sqlite3", args...).CombinedOutput()
+		if err != nil {
+			errMsg := strings.TrimSpace(string(out))
+			if errMsg == "" {
+				errMsg = err.Error()
+			}
+			return fmt.Errorf("failed to back up database: %s", errMsg)
+		}
+	}
+	return nil
+}
+
+// VerifyBackup checks that the database file at path is intact and returns
+// its Things database version.
+func VerifyBackup(path string) (int, error) {
+	cmd := exec.Command("sqlite3", "-readonly", path,
+		"PRAGMA quick_check; SELECT value FROM Meta WHERE key = 'databaseVersion';")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		errMsg := strings.TrimSpace(string(out))
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return 0, fmt.Errorf("%s is not a Things database: %s", path, errMsg)
+	}
+
+	check, version, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if check != "ok" {
+		return 0, fmt.Errorf("%s is damaged: %s", path, check)
+	}
+	v, err := parseVersion(version)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a Things database: %w", path, err)
+	}
+	return v, nil
+}
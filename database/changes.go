@@ -0,0 +1,74 @@
+package database
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// ChangeVersion returns a token that changes whenever main.sqlite or its
+// write-ahead log is written. Things writes through the WAL, so the main
+// file alone may not change until a checkpoint.
+func ChangeVersion() (string, error) {
+	dbPath, err := thingsDBPath()
+	if err != nil {
+		return "", err
+	}
+
+	version := ""
+	for _, path := range []string{dbPath, dbPath + "-wal"} {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot stat %s: %w", path, err)
+		}
+		version += fmt.Sprintf("%d:%d;", info.ModTime().UnixNano(), info.Size())
+	}
+	return version, nil
+}
+
+// Snapshot returns the change-tracking state of every to-do, project, area
+// and checklist item that is not in the trash.
+func Snapshot() ([]models.ItemVersion, error) {
+	var rows []struct {
+		Type     string  `json:"type"`
+		UUID     string  `json:"uuid"`
+		Title    string  `json:"title"`
+		Status   int     `json:"status"`
+		Task     string  `json:"task"`
+		Modified float64 `json:"modified"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT CASE type WHEN %[1]d THEN '%[3]s' ELSE '%[4]s' END AS type, uuid, COALESCE(title, '') AS title,
+		        status, '' AS task, COALESCE(userModificationDate, 0) AS modified
+		 FROM TMTask WHERE type IN (%[1]d, %[2]d) AND trashed = 0
+		 UNION ALL
+		 SELECT '%[5]s', uuid, COALESCE(title, ''), 0, '', 0 FROM TMArea
+		 UNION ALL
+		 SELECT '%[6]s', c.uuid, COALESCE(c.title, ''), c.status, c.task, COALESCE(c.userModificationDate, 0)
+		 FROM TMChecklistItem c JOIN TMTask t ON t.uuid = c.task WHERE t.trashed = 0`,
+		taskTypeToDo, taskTypeProject,
+		models.ItemTypeTask, models.ItemTypeProject, models.ItemTypeArea, models.ItemTypeChecklistItem,
+	)
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read database snapshot: %w", err)
+	}
+
+	items := make([]models.ItemVersion, len(rows))
+	for i, r := range rows {
+		items[i] = models.ItemVersion{
+			Type:     r.Type,
+			ID:       r.UUID,
+			Title:    r.Title,
+			TaskID:   r.Task,
+			Modified: r.Modified,
+		}
+		if r.Type != models.ItemTypeArea {
+			items[i].Status = statusName(r.Status)
+		}
+	}
+	return items, nil
+}
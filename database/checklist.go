@@ -2,48 +2,30 @@ package database
 
 import (
 	"fmt"
-	"net/url"
-	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
 )
 
-// thingsDBPath returns the path to the Things 3 SQLite database.
-func thingsDBPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
-	}
-
-	container := filepath.Join(home, "Library", "Group Containers", "JLMPQHK86H.com.culturedcode.ThingsMac")
-	entries, err := os.ReadDir(container)
-	if err != nil {
-		return "", fmt.Errorf("cannot read Things container: %w", err)
-	}
-
-	for _, e := range entries {
-		if e.IsDir() && strings.HasPrefix(e.Name(), "ThingsData-") {
-			dbPath := filepath.Join(container, e.Name(), "Things Database.thingsdatabase", "main.sqlite")
-			if _, err := os.Stat(dbPath); err == nil {
-				return dbPath, nil
-			}
-		}
-	}
-	return "", fmt.Errorf("Things 3 database not found")
+// query runs a sqlite3 query on the Things database and returns the output.
+func query(sql string) (string, error) {
+	return defaultDB.query(sql)
 }
 
-// query runs a sqlite3 query and returns the output.
-func query(sql string) (string, error) {
-	dbPath, err := thingsDBPath()
+// query runs a sqlite3 query and returns the output. Like queryJSON, it
+// passes the query on standard input.
+func (db *DB) query(sql string) (string, error) {
+	args, err := db.args("-bail", "-separator", "\t")
 	if err != nil {
 		return "", err
 	}
 
-	cmd := exec.Command("sqlite3", "-separator", "\t", dbPath, sql)
+	cmd := exec.Command("sqlite3", args...)
+	cmd.Stdin = strings.NewReader(sql)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		errMsg := strings.TrimSpace(string(out))
@@ -55,21 +37,6 @@ func query(sql string) (string, error) {
 	return strings.TrimSpace(string(out)), nil
 }
 
-// openThingsURL opens a things:/// URL via AppleScript.
-func openThingsURL(thingsURL string) error {
-	script := fmt.Sprintf(`open location "%s"`, thingsURL)
-	cmd := exec.Command("osascript", "-e", script)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		errMsg := strings.TrimSpace(string(out))
-		if errMsg == "" {
-			errMsg = err.Error()
-		}
-		return fmt.Errorf("failed to open things URL: %s", errMsg)
-	}
-	return nil
-}
-
 // GetChecklistItems retrieves all checklist items for a task (via SQLite).
 func GetChecklistItems(taskID string) ([]models.ChecklistItem, error) {
 	if err := models.ValidateThingsID(taskID); err != nil {
@@ -77,7 +44,7 @@ func GetChecklistItems(taskID string) ([]models.ChecklistItem, error) {
 	}
 
 	sql := fmt.Sprintf(
-		`SELECT uuid, title, status FROM TMChecklistItem WHERE task='%s' ORDER BY "index" ASC`,
+		`SELECT uuid, title, status, COALESCE(userModificationDate, 0) FROM TMChecklistItem WHERE task='%s' ORDER BY "index" ASC`,
 		escapeSQLite(taskID),
 	)
 
@@ -93,33 +60,8 @@ func GetChecklistItems(taskID string) ([]models.ChecklistItem, error) {
 // then looks up the created task ID from SQLite.
 // Returns the task ID.
 func CreateTaskWithChecklist(title string, checklistItems []string, notes, project, area, due, when string, tags []string) (string, error) {
-	params := url.Values{}
-	params.Set("title", title)
-	params.Set("checklist-items", strings.Join(checklistItems, "\n"))
-	if notes != "" {
-		params.Set("notes", notes)
-	}
-	if project != "" {
-		params.Set("list", project)
-	}
-	if area != "" && project == "" {
-		params.Set("list", area)
-	}
-	if due != "" {
-		params.Set("deadline", due)
-	}
-	if when != "" {
-		params.Set("when", when)
-	}
-	if len(tags) > 0 {
-		params.Set("tags", strings.Join(tags, ","))
-	}
-	params.Set("show-quick-entry", "false")
-
-	// Things URL scheme expects percent-encoding (%20), not form-encoding (+).
-	thingsURL := "things:///add?" + strings.ReplaceAll(params.Encode(), "+", "%20")
-
-	if err := openThingsURL(thingsURL); err != nil {
+	cmd := checklistTaskCommand(title, checklistItems, notes, project, area, due, when, tags)
+	if err := thingsurl.NewClient("", nil).Run(cmd); err != nil {
 		return "", err
 	}
 
@@ -153,6 +95,29 @@ func CreateTaskWithChecklist(title string, checklistItems []string, notes, proje
 	return taskID, nil
 }
 
+// CreateTaskWithChecklistURL returns the things:/// URL that
+// CreateTaskWithChecklist opens.
+func CreateTaskWithChecklistURL(title string, checklistItems []string, notes, project, area, due, when string, tags []string) (string, error) {
+	cmd := checklistTaskCommand(title, checklistItems, notes, project, area, due, when, tags)
+	return thingsurl.NewClient("", nil).Preview(cmd)
+}
+
+func checklistTaskCommand(title string, checklistItems []string, notes, project, area, due, when string, tags []string) thingsurl.Add {
+	list := project
+	if list == "" {
+		list = area
+	}
+	return thingsurl.Add{
+		Title:          title,
+		Notes:          notes,
+		When:           when,
+		Deadline:       due,
+		Tags:           tags,
+		ChecklistItems: checklistItems,
+		List:           list,
+	}
+}
+
 // AddChecklistItem adds a checklist item to an existing task via URL scheme.
 // Requires Things URL Scheme auth token.
 func AddChecklistItem(taskID string, title string, authToken string) error {
@@ -160,56 +125,154 @@ func AddChecklistItem(taskID string, title string, authToken string) error {
 		return err
 	}
 
-	params := url.Values{}
-	params.Set("id", taskID)
-	params.Set("append-checklist-items", title)
-	if authToken != "" {
-		params.Set("auth-token", authToken)
+	cmd := thingsurl.Update{
+		ID:                   taskID,
+		AppendChecklistItems: []string{title},
 	}
+	return thingsurl.NewClient(authToken, nil).Run(cmd)
+}
 
-	thingsURL := "things:///update?" + strings.ReplaceAll(params.Encode(), "+", "%20")
-	return openThingsURL(thingsURL)
+// AddChecklistItemURL returns the things:/// URL that AddChecklistItem opens,
+// with the auth token left out.
+func AddChecklistItemURL(taskID string, title string) (string, error) {
+	if err := models.ValidateThingsID(taskID); err != nil {
+		return "", err
+	}
+
+	cmd := thingsurl.Update{
+		ID:                   taskID,
+		AppendChecklistItems: []string{title},
+	}
+	return thingsurl.NewClient("", nil).Preview(cmd)
 }
 
-// AddChecklistItemDirect adds a checklist item directly via SQLite.
-// Used when no auth token is available. Items appear in API reads
-// but may not immediately appear in Things UI.
-func AddChecklistItemDirect(taskID string, req models.CreateChecklistItemRequest) (*models.ChecklistItem, error) {
+// EditChecklist returns the checklist of a to-do before and after applying
+// req to item itemID, or removing the item when req is nil, and the item's
+// position. The URL scheme can only change a checklist as a whole, so the
+// result is meant for SetChecklist. Entries carry the IDs the items have
+// before the change.
+func EditChecklist(taskID, itemID string, req *models.UpdateChecklistItemRequest) (before, after []models.ChecklistEntry, index int, err error) {
+	if err := models.ValidateThingsID(taskID); err != nil {
+		return nil, nil, 0, err
+	}
+	var rows []struct {
+		UUID   string `json:"uuid"`
+		Title  string `json:"title"`
+		Status int    `json:"status"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT uuid, title, status FROM TMChecklistItem WHERE task='%s' ORDER BY "index" ASC`,
+		escapeSQLite(taskID),
+	)
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to get checklist items: %w", err)
+	}
+
+	index = -1
+	for i, row := range rows {
+		before = append(before, models.ChecklistEntry{ID: row.UUID, Title: row.Title, Status: statusName(row.Status)})
+		if row.UUID == itemID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return nil, nil, 0, fmt.Errorf("checklist item not found")
+	}
+
+	after = append([]models.ChecklistEntry(nil), before...)
+	if req == nil {
+		return before, append(after[:index], after[index+1:]...), index, nil
+	}
+	if req.Title != nil {
+		after[index].Title = *req.Title
+	}
+	if req.Completed != nil {
+		after[index].Status = "open"
+		if *req.Completed {
+			after[index].Status = "completed"
+		}
+	}
+	return before, after, index, nil
+}
+
+// SetChecklist replaces the checklist of a to-do via things:///json, keeping
+// the status of each entry. Things gives every item a new ID, including
+// those left unchanged. Requires Things URL Scheme auth token.
+func SetChecklist(taskID string, entries []models.ChecklistEntry, authToken string) error {
+	cmd, err := setChecklistCommand(taskID, entries)
+	if err != nil {
+		return err
+	}
+	return thingsurl.NewClient(authToken, nil).Run(cmd)
+}
+
+// SetChecklistURL returns the things:/// URL that SetChecklist opens, with
+// the auth token left out.
+func SetChecklistURL(taskID string, entries []models.ChecklistEntry) (string, error) {
+	cmd, err := setChecklistCommand(taskID, entries)
+	if err != nil {
+		return "", err
+	}
+	return thingsurl.NewClient("", nil).Preview(cmd)
+}
+
+func setChecklistCommand(taskID string, entries []models.ChecklistEntry) (thingsurl.Command, error) {
 	if err := models.ValidateThingsID(taskID); err != nil {
 		return nil, err
 	}
+	// The json command leaves out an empty checklist-items list, so an
+	// empty checklist is set with update instead.
+	if len(entries) == 0 {
+		return thingsurl.Update{ID: taskID, ChecklistItems: []string{}}, nil
+	}
+
+	items := make([]thingsurl.Item, len(entries))
+	for i, e := range entries {
+		items[i] = thingsurl.Item{
+			Type: thingsurl.TypeChecklistItem,
+			Attributes: thingsurl.Attributes{
+				Title:     e.Title,
+				Completed: e.Status == "completed",
+				Canceled:  e.Status == "canceled",
+			},
+		}
+	}
+	return thingsurl.JSON{Items: []thingsurl.Item{{
+		Type:       thingsurl.TypeToDo,
+		Operation:  thingsurl.OperationUpdate,
+		ID:         taskID,
+		Attributes: thingsurl.Attributes{ChecklistItems: items},
+	}}}, nil
+}
 
-	uuid := generateUUID()
-	now := coreDataTimestamp()
+// AddChecklistItemSQL returns the statement AddChecklistItemDirect runs. The
+// item ID in it is a fresh one; the real insert generates its own.
+func AddChecklistItemSQL(taskID string, req models.CreateChecklistItemRequest) (string, error) {
+	if err := models.ValidateThingsID(taskID); err != nil {
+		return "", err
+	}
+	return addChecklistItemSQL(generateUUID(), taskID, req.Title, coreDataTimestamp()), nil
+}
 
-	sql := fmt.Sprintf(
+func addChecklistItemSQL(uuid, taskID, title string, now float64) string {
+	return fmt.Sprintf(
 		`INSERT INTO TMChecklistItem (uuid, task, title, status, "index", creationDate, userModificationDate, leavesTombstone)
 		VALUES ('%s', '%s', '%s', 0, (SELECT COALESCE(MAX("index"), 0) + 1 FROM TMChecklistItem WHERE task='%s'), %f, %f, 1)`,
 		escapeSQLite(uuid),
 		escapeSQLite(taskID),
-		escapeSQLite(req.Title),
+		escapeSQLite(title),
 		escapeSQLite(taskID),
 		now, now,
 	)
-
-	if _, err := query(sql); err != nil {
-		return nil, fmt.Errorf("failed to add checklist item: %w", err)
-	}
-
-	return &models.ChecklistItem{
-		ID:        uuid,
-		Title:     req.Title,
-		Completed: false,
-	}, nil
 }
 
-// UpdateChecklistItem updates a checklist item via SQLite.
-func UpdateChecklistItem(taskID, itemID string, req models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+// UpdateChecklistItemSQL returns the statement UpdateChecklistItem runs.
+func UpdateChecklistItemSQL(taskID, itemID string, req models.UpdateChecklistItemRequest) (string, error) {
 	if err := models.ValidateThingsID(taskID); err != nil {
-		return nil, err
+		return "", err
 	}
 	if err := models.ValidateThingsID(itemID); err != nil {
-		return nil, err
+		return "", err
 	}
 
 	var sets []string
@@ -229,55 +292,32 @@ func UpdateChecklistItem(taskID, itemID string, req models.UpdateChecklistItemRe
 	}
 	sets = append(sets, fmt.Sprintf("userModificationDate=%f", now))
 
-	sql := fmt.Sprintf(
+	return fmt.Sprintf(
 		`UPDATE TMChecklistItem SET %s WHERE uuid='%s' AND task='%s'`,
 		strings.Join(sets, ", "),
 		escapeSQLite(itemID),
 		escapeSQLite(taskID),
-	)
-
-	if _, err := query(sql); err != nil {
-		return nil, fmt.Errorf("failed to update checklist item: %w", err)
-	}
-
-	readSQL := fmt.Sprintf(
-		`SELECT uuid, title, status FROM TMChecklistItem WHERE uuid='%s' AND task='%s'`,
-		escapeSQLite(itemID),
-		escapeSQLite(taskID),
-	)
-	out, err := query(readSQL)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read updated checklist item: %w", err)
-	}
-	items := parseChecklistItems(out)
-	if len(items) == 0 {
-		return nil, fmt.Errorf("checklist item not found")
-	}
-	return &items[0], nil
+	), nil
 }
 
-// DeleteChecklistItem removes a checklist item via SQLite.
-func DeleteChecklistItem(taskID, itemID string) error {
+// DeleteChecklistItemSQL returns the statement DeleteChecklistItem runs.
+func DeleteChecklistItemSQL(taskID, itemID string) (string, error) {
 	if err := models.ValidateThingsID(taskID); err != nil {
-		return err
+		return "", err
 	}
 	if err := models.ValidateThingsID(itemID); err != nil {
-		return err
+		return "", err
 	}
 
-	sql := fmt.Sprintf(
+	return fmt.Sprintf(
 		`DELETE FROM TMChecklistItem WHERE uuid='%s' AND task='%s'`,
 		escapeSQLite(itemID),
 		escapeSQLite(taskID),
-	)
-
-	if _, err := query(sql); err != nil {
-		return fmt.Errorf("failed to delete checklist item: %w", err)
-	}
-	return nil
+	), nil
 }
 
 // parseChecklistItems parses sqlite3 tab-delimited output into ChecklistItem structs.
+// Expected format per line: uuid<TAB>title<TAB>status[<TAB>userModificationDate]
 func parseChecklistItems(output string) []models.ChecklistItem {
 	var items []models.ChecklistItem
 	if output == "" {
@@ -294,11 +334,17 @@ func parseChecklistItems(output string) []models.ChecklistItem {
 		if len(fields) < 3 {
 			continue
 		}
-		items = append(items, models.ChecklistItem{
+		item := models.ChecklistItem{
 			ID:        fields[0],
 			Title:     fields[1],
 			Completed: fields[2] == "3",
-		})
+		}
+		if len(fields) > 3 {
+			if ts, err := strconv.ParseFloat(fields[3], 64); err == nil {
+				item.ModifiedAt = formatCoreDataTime(ts)
+			}
+		}
+		items = append(items, item)
 	}
 	return items
 }
@@ -315,6 +361,21 @@ func coreDataTimestamp() float64 {
 	return time.Since(epoch).Seconds()
 }
 
+// coreDataTime converts a Core Data timestamp to local time.
+func coreDataTime(ts float64) time.Time {
+	epoch := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	return epoch.Add(time.Duration(ts * float64(time.Second))).Local()
+}
+
+// formatCoreDataTime formats a Core Data timestamp as RFC 3339. Zero (no
+// timestamp) formats as "".
+func formatCoreDataTime(ts float64) string {
+	if ts <= 0 {
+		return ""
+	}
+	return coreDataTime(ts).Format(time.RFC3339)
+}
+
 // generateUUID generates a Things-style UUID (22 chars, base62).
 func generateUUID() string {
 	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
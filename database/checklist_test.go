@@ -0,0 +1,89 @@
+package database
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func entry(title, status string) models.ChecklistEntry {
+	return models.ChecklistEntry{Title: title, Status: status}
+}
+
+func entryID(id, title, status string) models.ChecklistEntry {
+	return models.ChecklistEntry{ID: id, Title: title, Status: status}
+}
+
+func TestEditChecklist(t *testing.T) {
+	path := fixtureDB(t, "things-v26")
+	sqlite(t, path, `INSERT INTO TMChecklistItem VALUES ('ItemChives000000000001', 812538000, 812538000, 'Chives', 2, 812538000, 2, 'TaskSeeds0000000000001', 0);`)
+	SetPath(path)
+	t.Cleanup(func() { SetPath("") })
+
+	const task = "TaskSeeds0000000000001"
+	tomatoes := entryID("ItemTomato000000000001", "Tomatoes", "open")
+	basil := entryID("ItemBasil0000000000001", "Basil", "completed")
+	chives := entryID("ItemChives000000000001", "Chives", "canceled")
+	before := []models.ChecklistEntry{tomatoes, basil, chives}
+	title, done := "Cherry tomatoes", true
+
+	tests := []struct {
+		name  string
+		item  string
+		req   *models.UpdateChecklistItemRequest
+		after []models.ChecklistEntry
+		index int
+	}{
+		{
+			name:  "update keeps the other items and their status",
+			item:  "ItemTomato000000000001",
+			req:   &models.UpdateChecklistItemRequest{Title: &title, Completed: &done},
+			after: []models.ChecklistEntry{entryID("ItemTomato000000000001", "Cherry tomatoes", "completed"), basil, chives},
+		},
+		{
+			name:  "delete",
+			item:  "ItemBasil0000000000001",
+			after: []models.ChecklistEntry{tomatoes, chives},
+			index: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBefore, gotAfter, index, err := EditChecklist(task, tt.item, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotBefore, before) || !reflect.DeepEqual(gotAfter, tt.after) || index != tt.index {
+				t.Errorf("EditChecklist = %v, %v, %d; want %v, %v, %d", gotBefore, gotAfter, index, before, tt.after, tt.index)
+			}
+		})
+	}
+
+	if _, _, _, err := EditChecklist(task, "ItemMissing00000000001", nil); err == nil || err.Error() != "checklist item not found" {
+		t.Errorf("missing item: err = %v", err)
+	}
+}
+
+func TestSetChecklistURL(t *testing.T) {
+	got, err := SetChecklistURL("TaskSeeds0000000000001", []models.ChecklistEntry{entry("Tomatoes", "open"), entry("Chives", "canceled")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"type":"to-do","operation":"update","id":"TaskSeeds0000000000001","attributes":{"checklist-items":[` +
+		`{"type":"checklist-item","attributes":{"title":"Tomatoes"}},` +
+		`{"type":"checklist-item","attributes":{"title":"Chives","canceled":true}}]}}]`
+	if u.Host != "" || u.Path != "/json" || u.Query().Get("data") != want {
+		t.Errorf("SetChecklistURL = %s", got)
+	}
+
+	// An empty checklist cannot be sent with the json command.
+	if got, _ := SetChecklistURL("TaskSeeds0000000000001", nil); got != "things:///update?auth-token=REDACTED&checklist-items=&id=TaskSeeds0000000000001" {
+		t.Errorf("SetChecklistURL(empty) = %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMultipleDatabases is returned when more than one Things database is
+// found and none was chosen with SetPath.
+var ErrMultipleDatabases = errors.New("multiple Things databases found")
+
+// DB is a Things database file. Reads and writes of the package go to the
+// database of the local Things installation; other databases, such as an
+// archived copy, are opened with Open and only read.
+type DB struct {
+	path     string
+	readOnly bool
+
+	schema struct {
+		sync.Mutex
+		state   *schemaState
+		err     error
+		checked time.Time
+	}
+}
+
+// defaultDB is the database of the local Things installation.
+var defaultDB = &DB{}
+
+// Open returns a read-only DB for the database file at path. The file is
+// not read until the DB is used.
+func Open(path string) *DB {
+	return &DB{path: path, readOnly: true}
+}
+
+// SetPath makes the package use the database at path (THINGS_DB_PATH)
+// instead of looking for it in the Things container. Must be called before
+// the database is used.
+func SetPath(path string) {
+	defaultDB.path = path
+}
+
+// Path returns the path of the database file.
+func (db *DB) Path() (string, error) {
+	if db.path != "" {
+		return db.path, nil
+	}
+	return findThingsDB()
+}
+
+// args returns the sqlite3 arguments that open the database, after opts.
+func (db *DB) args(opts ...string) ([]string, error) {
+	path, err := db.Path()
+	if err != nil {
+		return nil, err
+	}
+	if db.readOnly {
+		opts = append(opts, "-readonly")
+	}
+	return append(opts, path), nil
+}
+
+// thingsDBPath returns the path to the Things 3 SQLite database.
+func thingsDBPath() (string, error) {
+	return defaultDB.Path()
+}
+
+// findThingsDB looks for the Things database in the Things container. It
+// fails with ErrMultipleDatabases if more than one ThingsData folder holds
+// a database.
+func findThingsDB() (string, error) {
+	found, err := FindDatabases()
+	if err != nil {
+		return "", err
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("Things 3 database not found")
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("%w: %s; set THINGS_DB_PATH to choose one", ErrMultipleDatabases, strings.Join(found, ", "))
+	}
+}
+
+// FindDatabases returns the Things databases in the Things container: one
+// per ThingsData folder, or the database directly in the container as
+// stored before Things 3.15.16.
+func FindDatabases() ([]string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine home directory: %w", err)
+	}
+
+	container := filepath.Join(home, "Library", "Group Containers", "JLMPQHK86H.com.culturedcode.ThingsMac")
+	entries, err := os.ReadDir(container)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read Things container: %w", err)
+	}
+
+	var found []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "ThingsData-") {
+			dbPath := filepath.Join(container, e.Name(), "Things Database.thingsdatabase", "main.sqlite")
+			if _, err := os.Stat(dbPath); err == nil {
+				found = append(found, dbPath)
+			}
+		}
+	}
+	if len(found) == 0 {
+		dbPath := filepath.Join(container, "Things Database.thingsdatabase", "main.sqlite")
+		if _, err := os.Stat(dbPath); err == nil {
+			found = append(found, dbPath)
+		}
+	}
+	return found, nil
+}
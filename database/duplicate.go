@@ -0,0 +1,210 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// DuplicateTask deep-copies a to-do (notes, tags, dates, checklist items)
+// via things:///json and returns the ID of the copy. Unless req.ListID is
+// set, the copy is placed in the same project, heading or area.
+func DuplicateTask(id string, req models.DuplicateTaskRequest) (string, error) {
+	item, err := DuplicateTaskItem(id, req)
+	if err != nil {
+		return "", err
+	}
+	return importOne(item)
+}
+
+// DuplicateTaskItem returns the Things JSON item that DuplicateTask imports.
+func DuplicateTaskItem(id string, req models.DuplicateTaskRequest) (thingsurl.Item, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return thingsurl.Item{}, err
+	}
+
+	src, err := getTaskRow(id)
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("failed to read task %s: %w", id, err)
+	}
+	if src.Type != taskTypeToDo {
+		return thingsurl.Item{}, fmt.Errorf("task %s not found", id)
+	}
+
+	item := todoItem(*src, req.ShiftDays, req.IncludeCompleted)
+	item.Attributes.Completed = false
+	item.Attributes.Canceled = false
+	if req.Title != nil {
+		item.Attributes.Title = *req.Title
+	}
+
+	switch {
+	case req.ListID != "":
+		item.Attributes.ListID = req.ListID
+	case src.Heading != nil:
+		heading, err := getTaskRow(*src.Heading)
+		if err != nil {
+			return thingsurl.Item{}, fmt.Errorf("failed to read heading of task %s: %w", id, err)
+		}
+		item.Attributes.ListID = str(heading.Project)
+		item.Attributes.HeadingID = heading.UUID
+	case src.Project != nil:
+		item.Attributes.ListID = *src.Project
+	case src.Area != nil:
+		item.Attributes.ListID = *src.Area
+	}
+
+	return item, nil
+}
+
+// DuplicateProject deep-copies a project with its headings, to-dos and their
+// checklist items via things:///json and returns the ID of the copy.
+// Completed and canceled to-dos are only copied when req.IncludeCompleted is set.
+func DuplicateProject(id string, req models.DuplicateProjectRequest) (string, error) {
+	item, err := DuplicateProjectItem(id, req)
+	if err != nil {
+		return "", err
+	}
+	return importOne(item)
+}
+
+// DuplicateProjectItem returns the Things JSON item that DuplicateProject
+// imports.
+func DuplicateProjectItem(id string, req models.DuplicateProjectRequest) (thingsurl.Item, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return thingsurl.Item{}, err
+	}
+
+	src, err := getTaskRow(id)
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("failed to read project %s: %w", id, err)
+	}
+	if src.Type != taskTypeProject {
+		return thingsurl.Item{}, fmt.Errorf("project %s not found", id)
+	}
+
+	children, err := getTaskRows(fmt.Sprintf(
+		`project = '%[1]s' OR heading IN (SELECT uuid FROM TMTask WHERE project = '%[1]s' AND type = 2)`,
+		escapeSQLite(id),
+	))
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("failed to read project %s contents: %w", id, err)
+	}
+
+	item := thingsurl.Item{
+		Type: thingsurl.TypeProject,
+		Attributes: thingsurl.Attributes{
+			Title:    src.Title,
+			Notes:    str(src.Notes),
+			When:     whenValue(*src, req.ShiftDays),
+			Deadline: dateValue(src.Deadline, req.ShiftDays),
+			Tags:     src.Tags,
+			AreaID:   str(src.Area),
+		},
+	}
+	if req.Name != nil {
+		item.Attributes.Title = *req.Name
+	}
+	if req.AreaID != "" {
+		item.Attributes.AreaID = req.AreaID
+	}
+
+	// Things shows to-dos without a heading first, then each heading
+	// followed by its to-dos; children are already in manual order.
+	byHeading := make(map[string][]taskRow)
+	var headings []taskRow
+	for _, c := range children {
+		switch {
+		case c.Type == taskTypeHeading:
+			headings = append(headings, c)
+		case c.Type == taskTypeToDo:
+			byHeading[str(c.Heading)] = append(byHeading[str(c.Heading)], c)
+		}
+	}
+	appendTodos := func(rows []taskRow) {
+		for _, row := range rows {
+			if row.Status != statusOpen && !req.IncludeCompleted {
+				continue
+			}
+			item.Attributes.Items = append(item.Attributes.Items, todoItem(row, req.ShiftDays, req.IncludeCompleted))
+		}
+	}
+	appendTodos(byHeading[""])
+	for _, h := range headings {
+		item.Attributes.Items = append(item.Attributes.Items, thingsurl.Item{
+			Type:       thingsurl.TypeHeading,
+			Attributes: thingsurl.Attributes{Title: h.Title},
+		})
+		appendTodos(byHeading[h.UUID])
+	}
+
+	return item, nil
+}
+
+// importOne imports a single top-level item and returns its new ID.
+func importOne(item thingsurl.Item) (string, error) {
+	created, err := ImportThingsJSON([]thingsurl.Item{item}, "")
+	if err != nil {
+		return "", err
+	}
+	if len(created) == 0 || created[0].ID == "" {
+		return "", fmt.Errorf("item created via URL scheme but could not find ID in database")
+	}
+	return created[0].ID, nil
+}
+
+// todoItem converts a to-do row into a Things JSON to-do, shifting dates by
+// shiftDays. Completed checklist items are only kept when includeCompleted is set.
+func todoItem(row taskRow, shiftDays int, includeCompleted bool) thingsurl.Item {
+	item := thingsurl.Item{
+		Type: thingsurl.TypeToDo,
+		Attributes: thingsurl.Attributes{
+			Title:     row.Title,
+			Notes:     str(row.Notes),
+			When:      whenValue(row, shiftDays),
+			Deadline:  dateValue(row.Deadline, shiftDays),
+			Tags:      row.Tags,
+			Completed: row.Status == statusCompleted,
+			Canceled:  row.Status == statusCanceled,
+		},
+	}
+	for _, ci := range row.Checklist {
+		if ci.Status != statusOpen && !includeCompleted {
+			continue
+		}
+		item.Attributes.ChecklistItems = append(item.Attributes.ChecklistItems, thingsurl.Item{
+			Type: thingsurl.TypeChecklistItem,
+			Attributes: thingsurl.Attributes{
+				Title:     ci.Title,
+				Completed: ci.Status == statusCompleted,
+				Canceled:  ci.Status == statusCanceled,
+			},
+		})
+	}
+	return item
+}
+
+// whenValue returns the Things "when" value for a row: its scheduled date
+// (shifted) if any, otherwise anytime or someday.
+func whenValue(row taskRow, shiftDays int) string {
+	if row.StartDate != nil {
+		return dateValue(row.StartDate, shiftDays)
+	}
+	switch row.Start {
+	case startAnytime:
+		return "anytime"
+	case startSomeday:
+		return "someday"
+	default:
+		return ""
+	}
+}
+
+// dateValue formats a packed Things date as YYYY-MM-DD, shifted by shiftDays.
+func dateValue(v *int64, shiftDays int) string {
+	if v == nil || *v == 0 {
+		return ""
+	}
+	return decodeThingsDate(*v).AddDate(0, 0, shiftDays).Format("2006-01-02")
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// duplicateFixture points the package at the v26 fixture with a heading in the Garden
+// project, an open to-do under it and a completed to-do in the project.
+func duplicateFixture(t *testing.T) {
+	t.Helper()
+	path := fixtureDB(t, "things-v26")
+	sqlite(t, path, `INSERT INTO TMTask VALUES
+		('HeadingTools0000000001', 0, 812538000, 812538000, 2, 0, NULL, 0, 'Tools', NULL, 1, NULL, 0, NULL, 4, 0, NULL, 'ProjectGarden000000001', NULL),
+		('TaskRake00000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Rake', NULL, 2, NULL, 0, NULL, 0, 0, NULL, NULL, 'HeadingTools0000000001'),
+		('TaskDig000000000000001', 0, 812538000, 812538000, 0, 3, 812538000, 0, 'Dig beds', NULL, 1, NULL, 0, NULL, 3, 0, NULL, 'ProjectGarden000000001', NULL);`)
+	SetPath(path)
+	t.Cleanup(func() { SetPath("") })
+}
+
+func checklistItem(title string, completed bool) thingsurl.Item {
+	return thingsurl.Item{Type: thingsurl.TypeChecklistItem, Attributes: thingsurl.Attributes{Title: title, Completed: completed}}
+}
+
+func TestDuplicateTaskItem(t *testing.T) {
+	duplicateFixture(t)
+	title := "Buy more seeds"
+	seeds := thingsurl.Attributes{
+		Title:          "Buy seeds",
+		Notes:          "Tomatoes",
+		When:           "2026-10-20",
+		Deadline:       "2026-10-31",
+		Tags:           []string{"errand"},
+		ListID:         "ProjectGarden000000001",
+		ChecklistItems: []thingsurl.Item{checklistItem("Tomatoes", false)},
+	}
+	tests := []struct {
+		name string
+		id   string
+		req  models.DuplicateTaskRequest
+		want func(a *thingsurl.Attributes)
+	}{
+		{name: "same project", id: "TaskSeeds0000000000001", want: func(a *thingsurl.Attributes) {}},
+		{name: "title and list", id: "TaskSeeds0000000000001", req: models.DuplicateTaskRequest{Title: &title, ListID: "AreaHome00000000000001"}, want: func(a *thingsurl.Attributes) {
+			a.Title = title
+			a.ListID = "AreaHome00000000000001"
+		}},
+		{name: "shifted with completed checklist items", id: "TaskSeeds0000000000001", req: models.DuplicateTaskRequest{ShiftDays: 3, IncludeCompleted: true}, want: func(a *thingsurl.Attributes) {
+			a.When = "2026-10-23"
+			a.Deadline = "2026-11-03"
+			a.ChecklistItems = append(a.ChecklistItems, checklistItem("Basil", true))
+		}},
+		{name: "under a heading", id: "TaskRake00000000000001", want: func(a *thingsurl.Attributes) {
+			*a = thingsurl.Attributes{Title: "Rake", When: "someday", ListID: "ProjectGarden000000001", HeadingID: "HeadingTools0000000001"}
+		}},
+		{name: "completed copy is open", id: "TaskOld000000000000001", want: func(a *thingsurl.Attributes) {
+			*a = thingsurl.Attributes{Title: "Old to-do", When: "anytime", ListID: "AreaHome00000000000001"}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DuplicateTaskItem(tt.id, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := seeds
+			want.ChecklistItems = append([]thingsurl.Item(nil), seeds.ChecklistItems...)
+			tt.want(&want)
+			if !reflect.DeepEqual(got, thingsurl.Item{Type: thingsurl.TypeToDo, Attributes: want}) {
+				t.Errorf("item = %+v, want %+v", got.Attributes, want)
+			}
+		})
+	}
+
+	if _, err := DuplicateTaskItem("ProjectGarden000000001", models.DuplicateTaskRequest{}); err == nil {
+		t.Error("duplicating a project as a task: err = nil")
+	}
+}
+
+func TestDuplicateProjectItem(t *testing.T) {
+	duplicateFixture(t)
+	name := "Garden 2027"
+	tests := []struct {
+		name      string
+		req       models.DuplicateProjectRequest
+		wantTitle string
+		wantArea  string
+		wantItems []string
+		wantWhen  string // When of the first to-do
+	}{
+		{
+			name:      "open to-dos, then headings",
+			wantTitle: "Garden", wantArea: "AreaHome00000000000001",
+			wantItems: []string{"to-do Buy seeds", "to-do Paint the shed", "heading Tools", "to-do Rake"},
+			wantWhen:  "2026-10-20",
+		},
+		{
+			name:      "renamed, moved and shifted with completed to-dos",
+			req:       models.DuplicateProjectRequest{Name: &name, AreaID: "AreaWork00000000000001", ShiftDays: 365, IncludeCompleted: true},
+			wantTitle: name, wantArea: "AreaWork00000000000001",
+			wantItems: []string{"to-do Buy seeds", "to-do Paint the shed", "to-do Dig beds", "heading Tools", "to-do Rake"},
+			wantWhen:  "2027-10-20",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DuplicateProjectItem("ProjectGarden000000001", tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type != thingsurl.TypeProject || got.Attributes.Title != tt.wantTitle || got.Attributes.AreaID != tt.wantArea {
+				t.Errorf("project = %s %q in %q, want project %q in %q", got.Type, got.Attributes.Title, got.Attributes.AreaID, tt.wantTitle, tt.wantArea)
+			}
+			var items []string
+			for _, item := range got.Attributes.Items {
+				items = append(items, item.Type+" "+item.Attributes.Title)
+			}
+			if !reflect.DeepEqual(items, tt.wantItems) {
+				t.Errorf("items = %q, want %q", items, tt.wantItems)
+			}
+			if when := got.Attributes.Items[0].Attributes.When; when != tt.wantWhen {
+				t.Errorf("first to-do when = %q, want %q", when, tt.wantWhen)
+			}
+		})
+	}
+
+	if _, err := DuplicateProjectItem("TaskSeeds0000000000001", models.DuplicateProjectRequest{}); err == nil {
+		t.Error("duplicating a to-do as a project: err = nil")
+	}
+}
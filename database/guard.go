@@ -0,0 +1,28 @@
+package database
+
+import "errors"
+
+var (
+	// ErrDirectWritesDisabled is returned by direct writes when they have
+	// not been enabled.
+	ErrDirectWritesDisabled = errors.New("direct database writes are disabled")
+
+	// ErrThingsRunning is returned by direct writes while Things is running
+	// when they are configured to require it to be quit.
+	ErrThingsRunning = errors.New("Things is running; quit it to write to its database directly")
+
+	// ErrUnsupportedSchema is returned by direct writes when the database
+	// is not a version they were written for.
+	ErrUnsupportedSchema = errors.New("unsupported Things database schema")
+)
+
+// checkWriteSchema probes the database and verifies that direct writes
+// support its version and that it has every column they set.
+func checkWriteSchema() error {
+	s, err := defaultDB.probeSchema()
+	if err != nil {
+		return err
+	}
+	defaultDB.cacheSchema(s)
+	return s.writable()
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// TMTask.type values.
+const (
+	taskTypeToDo    = 0
+	taskTypeProject = 1
+	taskTypeHeading = 2
+)
+
+// ImportThingsJSON submits items through things:///json, then resolves the
+// IDs of created to-dos, projects and headings from SQLite by matching titles
+// among rows modified since the import started. Updated items keep their IDs.
+func ImportThingsJSON(items []thingsurl.Item, authToken string) ([]models.ImportedItem, error) {
+	since := coreDataTimestamp()
+
+	cmd := thingsurl.JSON{Items: items}
+	if err := thingsurl.NewClient(authToken, nil).Run(cmd); err != nil {
+		return nil, err
+	}
+
+	result := flattenImport(items)
+
+	var pending int
+	for _, r := range result {
+		if r.ID == "" {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return result, nil
+	}
+
+	// Wait for Things to process the URL and write to database.
+	time.Sleep(1500 * time.Millisecond)
+
+	for i := 0; i < 15 && pending > 0; i++ {
+		sql := fmt.Sprintf(
+			`SELECT uuid, type, title FROM TMTask
+			 WHERE userModificationDate >= %f AND trashed = 0
+			 ORDER BY creationDate ASC, "index" ASC`,
+			since,
+		)
+		var rows []taskRow
+		if err := queryJSON(sql, &rows); err == nil {
+			pending = matchImported(result, rows)
+		}
+		if pending > 0 {
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+
+	if pending == len(result) {
+		return nil, fmt.Errorf("items imported via URL scheme but could not find IDs in database")
+	}
+	return result, nil
+}
+
+// PlanImport returns the things:/// URL that ImportThingsJSON opens for
+// items, with the auth token left out, and the items it would report. Items
+// that are created have no ID yet.
+func PlanImport(items []thingsurl.Item) (string, []models.ImportedItem, error) {
+	u, err := thingsurl.NewClient("", nil).Preview(thingsurl.JSON{Items: items})
+	if err != nil {
+		return "", nil, err
+	}
+	return u, flattenImport(items), nil
+}
+
+// flattenImport lists every to-do, project and heading in items in document
+// order. Checklist items are not included.
+func flattenImport(items []thingsurl.Item) []models.ImportedItem {
+	var result []models.ImportedItem
+	for _, item := range items {
+		op := item.Operation
+		if op == "" {
+			op = thingsurl.OperationCreate
+		}
+		result = append(result, models.ImportedItem{
+			ID:        item.ID,
+			Type:      item.Type,
+			Operation: op,
+			Title:     item.Attributes.Title,
+		})
+		result = append(result, flattenImport(item.Attributes.Items)...)
+	}
+	return result
+}
+
+// matchImported assigns IDs from TMTask rows to unresolved items, using each
+// row at most once. Returns how many items remain unresolved.
+func matchImported(items []models.ImportedItem, rows []taskRow) int {
+	used := make(map[string]bool)
+	for _, item := range items {
+		if item.ID != "" {
+			used[item.ID] = true
+		}
+	}
+
+	pending := 0
+	for i := range items {
+		if items[i].ID != "" {
+			continue
+		}
+		wantType := importTaskType(items[i].Type)
+		for _, row := range rows {
+			if used[row.UUID] || row.Type != wantType || row.Title != items[i].Title {
+				continue
+			}
+			items[i].ID = row.UUID
+			used[row.UUID] = true
+			break
+		}
+		if items[i].ID == "" {
+			pending++
+		}
+	}
+	return pending
+}
+
+func importTaskType(itemType string) int {
+	switch itemType {
+	case thingsurl.TypeProject:
+		return taskTypeProject
+	case thingsurl.TypeHeading:
+		return taskTypeHeading
+	default:
+		return taskTypeToDo
+	}
+}
@@ -0,0 +1,70 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+func TestMatchImported(t *testing.T) {
+	todo := func(title string) models.ImportedItem {
+		return models.ImportedItem{Type: thingsurl.TypeToDo, Title: title}
+	}
+	tests := []struct {
+		name        string
+		items       []models.ImportedItem
+		rows        []taskRow
+		wantIDs     []string
+		wantPending int
+	}{
+		{
+			name:    "by title and type",
+			items:   []models.ImportedItem{{Type: thingsurl.TypeProject, Title: "Garden"}, {Type: thingsurl.TypeHeading, Title: "Garden"}, todo("Garden")},
+			rows:    []taskRow{{UUID: "T1", Type: taskTypeToDo, Title: "Garden"}, {UUID: "H1", Type: taskTypeHeading, Title: "Garden"}, {UUID: "P1", Type: taskTypeProject, Title: "Garden"}},
+			wantIDs: []string{"P1", "H1", "T1"},
+		},
+		{
+			name:    "same titles take rows in order",
+			items:   []models.ImportedItem{todo("Water"), todo("Water")},
+			rows:    []taskRow{{UUID: "T1", Title: "Water"}, {UUID: "T2", Title: "Water"}},
+			wantIDs: []string{"T1", "T2"},
+		},
+		{
+			name:        "each row is used once",
+			items:       []models.ImportedItem{todo("Water"), todo("Water")},
+			rows:        []taskRow{{UUID: "T1", Title: "Water"}},
+			wantIDs:     []string{"T1", ""},
+			wantPending: 1,
+		},
+		{
+			name:    "updated items keep their rows",
+			items:   []models.ImportedItem{todo("Water"), {ID: "T1", Type: thingsurl.TypeToDo, Operation: thingsurl.OperationUpdate, Title: "Water"}},
+			rows:    []taskRow{{UUID: "T1", Title: "Water"}, {UUID: "T2", Title: "Water"}},
+			wantIDs: []string{"T2", "T1"},
+		},
+		{
+			name:        "no matching row",
+			items:       []models.ImportedItem{todo("Water")},
+			rows:        []taskRow{{UUID: "T1", Title: "Weed"}},
+			wantIDs:     []string{""},
+			wantPending: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := matchImported(tt.items, tt.rows)
+			if pending != tt.wantPending {
+				t.Errorf("pending = %d, want %d", pending, tt.wantPending)
+			}
+			ids := make([]string, len(tt.items))
+			for i, item := range tt.items {
+				ids[i] = item.ID
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("IDs = %q, want %q", ids, tt.wantIDs)
+			}
+		})
+	}
+}
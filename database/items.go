@@ -0,0 +1,211 @@
+package database
+
+import (
+	"fmt"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// GetTask returns the to-do with the given ID from the Things database.
+func GetTask(id string) (*models.Task, error) {
+	return defaultDB.Task(id)
+}
+
+// Task returns the to-do with the given ID, read from the database.
+func (db *DB) Task(id string) (*models.Task, error) {
+	rows, err := db.taskRows(fmt.Sprintf(`uuid = '%s' AND type = %d`, escapeSQLite(id), taskTypeToDo))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("task %s not found", id)
+	}
+	tasks, err := db.taskModels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
+
+// GetProject returns the project with the given ID from the Things database.
+func GetProject(id string) (*models.Project, error) {
+	return defaultDB.Project(id)
+}
+
+// Project returns the project with the given ID, read from the database.
+func (db *DB) Project(id string) (*models.Project, error) {
+	rows, err := db.taskRows(fmt.Sprintf(`uuid = '%s' AND type = %d`, escapeSQLite(id), taskTypeProject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("project %s not found", id)
+	}
+	projects, err := db.projectModels(rows)
+	if err != nil {
+		return nil, err
+	}
+	return &projects[0], nil
+}
+
+// GetArea returns the area with the given ID from the Things database.
+func GetArea(id string) (*models.Area, error) {
+	return defaultDB.Area(id)
+}
+
+// Area returns the area with the given ID, without its projects.
+func (db *DB) Area(id string) (*models.Area, error) {
+	var rows []struct {
+		UUID  string `json:"uuid"`
+		Title string `json:"title"`
+	}
+	if err := db.queryJSON(fmt.Sprintf(`SELECT uuid, title FROM TMArea WHERE uuid = '%s'`, escapeSQLite(id)), &rows); err != nil {
+		return nil, fmt.Errorf("failed to read area: %w", err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("area %s not found", id)
+	}
+	return &models.Area{ID: rows[0].UUID, Name: rows[0].Title}, nil
+}
+
+// Projects returns the projects that are not in the trash. Completed and
+// canceled projects are only included when includeClosed is set.
+func (db *DB) Projects(includeClosed bool) ([]models.Project, error) {
+	where := fmt.Sprintf("type = %d", taskTypeProject)
+	if !includeClosed {
+		where += fmt.Sprintf(" AND status = %d", statusOpen)
+	}
+	rows, err := db.taskRows(where)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read projects: %w", err)
+	}
+	return db.projectModels(rows)
+}
+
+// Areas returns all areas in their sidebar order, without their projects.
+func (db *DB) Areas() ([]models.Area, error) {
+	var rows []struct {
+		UUID  string `json:"uuid"`
+		Title string `json:"title"`
+	}
+	if err := db.queryJSON(`SELECT uuid, title FROM TMArea ORDER BY "index"`, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read areas: %w", err)
+	}
+	areas := make([]models.Area, 0, len(rows))
+	for _, r := range rows {
+		areas = append(areas, models.Area{ID: r.UUID, Name: r.Title})
+	}
+	return areas, nil
+}
+
+// ItemStatus returns the status (open, completed or canceled) of a to-do
+// or project.
+func ItemStatus(id string) (string, error) {
+	var rows []struct {
+		Status int `json:"status"`
+	}
+	if err := queryJSON(fmt.Sprintf(`SELECT status FROM TMTask WHERE uuid = '%s'`, escapeSQLite(id)), &rows); err != nil {
+		return "", fmt.Errorf("failed to read status: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("item %s not found", id)
+	}
+	return statusName(rows[0].Status), nil
+}
+
+// Placement returns the IDs of the project and area directly containing the
+// to-do or project id. To-dos under a heading report the heading's project.
+func Placement(id string) (projectID, areaID string, err error) {
+	var rows []struct {
+		Project *string `json:"project"`
+		Area    *string `json:"area"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT COALESCE(t.project, h.project) AS project, t.area
+		 FROM TMTask t LEFT JOIN TMTask h ON h.uuid = t.heading
+		 WHERE t.uuid = '%s'`, escapeSQLite(id))
+	if err := queryJSON(sql, &rows); err != nil {
+		return "", "", fmt.Errorf("failed to read placement: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", "", fmt.Errorf("item %s not found", id)
+	}
+	return str(rows[0].Project), str(rows[0].Area), nil
+}
+
+// ContainedTasks returns the IDs of the to-dos in the project or area with
+// the given ID, in any status. For areas, only to-dos outside projects are
+// included. A non-empty status limits the result to to-dos in that status.
+func ContainedTasks(containerID, status string) ([]string, error) {
+	where := fmt.Sprintf(`(COALESCE(t.project, h.project) = '%[1]s' OR (t.area = '%[1]s' AND COALESCE(t.project, h.project) IS NULL))`,
+		escapeSQLite(containerID))
+	if status != "" {
+		s, ok := statusValue(status)
+		if !ok {
+			return nil, fmt.Errorf("invalid status %q", status)
+		}
+		where += fmt.Sprintf(` AND t.status = %d`, s)
+	}
+	return queryIDs(fmt.Sprintf(
+		`SELECT t.uuid FROM TMTask t LEFT JOIN TMTask h ON h.uuid = t.heading
+		 WHERE t.type = %d AND t.trashed = 0 AND %s ORDER BY t."index"`, taskTypeToDo, where))
+}
+
+// AreaProjects returns the IDs of the projects in the area with the given ID.
+func AreaProjects(areaID string) ([]string, error) {
+	return queryIDs(fmt.Sprintf(`SELECT uuid FROM TMTask WHERE type = %d AND trashed = 0 AND area = '%s' ORDER BY "index"`,
+		taskTypeProject, escapeSQLite(areaID)))
+}
+
+// Neighbors returns the to-dos directly before and after id in manual order:
+// within Today for list "today", otherwise within the to-do's project,
+// heading or area. Either is empty at the start or end of the list.
+func Neighbors(id, list string) (before, after string, err error) {
+	rows, err := getOrderRows(fmt.Sprintf(`t.uuid = '%s' AND t.type = 0 AND t.trashed = 0`, escapeSQLite(id)))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read task order: %w", err)
+	}
+	if len(rows) == 0 {
+		return "", "", fmt.Errorf("task %s not found", id)
+	}
+	task := rows[0]
+
+	column, value := `"index"`, task.Index
+	scope := fmt.Sprintf(`project IS %s AND heading IS %s AND area IS %s AND type = 0 AND trashed = 0`,
+		sqlNullable(task.Project), sqlNullable(task.Heading), sqlNullable(task.Area))
+	if list == "today" {
+		column, value = "todayIndex", task.TodayIndex
+		scope = todayScope(task.StartBucket)
+	}
+
+	for _, q := range []struct {
+		dest  *string
+		op    string
+		order string
+	}{{&before, "<", "DESC"}, {&after, ">", "ASC"}} {
+		ids, err := queryIDs(fmt.Sprintf(`SELECT uuid FROM TMTask WHERE %[1]s AND uuid != '%[2]s' AND %[3]s %[4]s %[5]d ORDER BY %[3]s %[6]s LIMIT 1`,
+			scope, escapeSQLite(id), column, q.op, value, q.order))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read task order: %w", err)
+		}
+		if len(ids) > 0 {
+			*q.dest = ids[0]
+		}
+	}
+	return before, after, nil
+}
+
+// queryIDs runs a query selecting a single uuid column.
+func queryIDs(sql string) ([]string, error) {
+	var rows []struct {
+		UUID string `json:"uuid"`
+	}
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.UUID
+	}
+	return ids, nil
+}
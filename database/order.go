@@ -0,0 +1,206 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+var (
+	// ErrNotInToday is returned by a move in Today when either task is not
+	// in Today.
+	ErrNotInToday = errors.New("both tasks must be in Today")
+
+	// ErrNotSiblings is returned by a move when the tasks are not in the
+	// same project, heading or area.
+	ErrNotSiblings = errors.New("tasks must be in the same project, heading or area")
+)
+
+// orderRow holds the TMTask columns that determine display order.
+type orderRow struct {
+	UUID         string  `json:"uuid"`
+	Index        int64   `json:"index"`
+	TodayIndex   int64   `json:"todayIndex"`
+	StartBucket  int64   `json:"startBucket"`
+	HeadingIndex int64   `json:"headingIndex"`
+	Project      *string `json:"project"`
+	Area         *string `json:"area"`
+	Heading      *string `json:"heading"`
+	Start        int     `json:"start"`
+	StartDate    *int64  `json:"startDate"`
+	Status       int     `json:"status"`
+}
+
+// SortTasks orders tasks as Things displays them and sets their 1-based
+// Position. For "today", tasks are ordered by evening bucket then todayIndex;
+// for "upcoming", by scheduled date; for every other list, to-dos without a
+// heading come first, then by heading and manual index. Tasks missing from
+// the database keep their relative order at the end.
+func SortTasks(tasks []models.Task, list string) error {
+	defer func() {
+		for i := range tasks {
+			tasks[i].Position = i + 1
+		}
+	}()
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	rows, err := getOrderRows(fmt.Sprintf(`t.uuid IN (%s)`, sqlInList(ids)))
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]orderRow, len(rows))
+	for _, row := range rows {
+		byID[row.UUID] = row
+	}
+
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, aok := byID[tasks[i].ID]
+		b, bok := byID[tasks[j].ID]
+		if !aok || !bok {
+			return aok && !bok
+		}
+		if list == "today" {
+			if a.StartBucket != b.StartBucket {
+				return a.StartBucket < b.StartBucket
+			}
+			if a.TodayIndex != b.TodayIndex {
+				return a.TodayIndex < b.TodayIndex
+			}
+			return a.Index < b.Index
+		}
+		if list == "upcoming" {
+			ad, bd := int64(0), int64(0)
+			if a.StartDate != nil {
+				ad = *a.StartDate
+			}
+			if b.StartDate != nil {
+				bd = *b.StartDate
+			}
+			if ad != bd {
+				return ad < bd
+			}
+			return a.TodayIndex < b.TodayIndex
+		}
+		if a.HeadingIndex != b.HeadingIndex {
+			return a.HeadingIndex < b.HeadingIndex
+		}
+		return a.Index < b.Index
+	})
+	return nil
+}
+
+// MoveTaskSQL reads the current order and returns the statements MoveTask
+// runs in one transaction. It fails the same way MoveTask does when the
+// move is not possible.
+func MoveTaskSQL(id string, req models.MoveTaskRequest) (string, error) {
+	if err := models.ValidateThingsID(id); err != nil {
+		return "", err
+	}
+
+	siblingID := req.Before
+	if siblingID == "" {
+		siblingID = req.After
+	}
+
+	rows, err := getOrderRows(fmt.Sprintf(`t.uuid IN ('%s', '%s') AND t.type = 0 AND t.trashed = 0`,
+		escapeSQLite(id), escapeSQLite(siblingID)))
+	if err != nil {
+		return "", fmt.Errorf("failed to read task order: %w", err)
+	}
+	var task, sibling *orderRow
+	for i := range rows {
+		switch rows[i].UUID {
+		case id:
+			task = &rows[i]
+		case siblingID:
+			sibling = &rows[i]
+		}
+	}
+	if task == nil {
+		return "", fmt.Errorf("task %s not found", id)
+	}
+	if sibling == nil {
+		return "", fmt.Errorf("sibling task %s not found", siblingID)
+	}
+
+	column := `"index"`
+	target := sibling.Index
+	var scope, extra string
+	if req.List == "today" {
+		if !inToday(*task) || !inToday(*sibling) {
+			return "", ErrNotInToday
+		}
+		column = "todayIndex"
+		target = sibling.TodayIndex
+		scope = todayScope(sibling.StartBucket)
+		// Moving next to a sibling in "This Evening" (or out of it) changes the bucket.
+		extra = fmt.Sprintf(", startBucket = %d", sibling.StartBucket)
+	} else {
+		if str(task.Project) != str(sibling.Project) || str(task.Heading) != str(sibling.Heading) || str(task.Area) != str(sibling.Area) {
+			return "", ErrNotSiblings
+		}
+		scope = fmt.Sprintf(`project IS %s AND heading IS %s AND area IS %s AND type = 0`,
+			sqlNullable(task.Project), sqlNullable(task.Heading), sqlNullable(task.Area))
+	}
+	if req.After != "" {
+		target++
+	}
+
+	now := coreDataTimestamp()
+	return fmt.Sprintf(
+		`UPDATE TMTask SET %[1]s = %[1]s + 1 WHERE %[2]s AND %[1]s >= %[3]d AND uuid != '%[4]s';
+		 UPDATE TMTask SET %[1]s = %[3]d%[6]s, userModificationDate = %[5]f WHERE uuid = '%[4]s'`,
+		column, scope, target, escapeSQLite(id), now, extra,
+	), nil
+}
+
+// getOrderRows returns order columns for TMTask rows (aliased t) matching where.
+func getOrderRows(where string) ([]orderRow, error) {
+	var rows []orderRow
+	sql := fmt.Sprintf(
+		`SELECT t.uuid, t."index", COALESCE(t.todayIndex, 0) AS todayIndex,
+		        COALESCE(t.startBucket, 0) AS startBucket,
+		        COALESCE(h."index", -2147483648) AS headingIndex,
+		        t.project, t.area, t.heading, t.start, %s AS startDate, t.status
+		 FROM TMTask t LEFT JOIN TMTask h ON h.uuid = t.heading
+		 WHERE %s`, readProfile().startDate("t"), where)
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// inToday reports whether an open to-do is scheduled for today or earlier.
+func inToday(row orderRow) bool {
+	return row.Status == statusOpen && row.Start == startAnytime &&
+		row.StartDate != nil && *row.StartDate <= encodeThingsDate(time.Now())
+}
+
+// todayScope selects the open to-dos in Today in the given evening bucket.
+func todayScope(bucket int64) string {
+	startDate := readProfile().startDate("")
+	return fmt.Sprintf(`start = 1 AND %[1]s IS NOT NULL AND %[1]s <= %[2]d AND status = 0 AND trashed = 0 AND startBucket = %[3]d`,
+		startDate, encodeThingsDate(time.Now()), bucket)
+}
+
+// encodeThingsDate packs a date in the TMTask.startDate format.
+func encodeThingsDate(t time.Time) int64 {
+	return int64(t.Year())<<16 | int64(t.Month())<<12 | int64(t.Day())<<7
+}
+
+// sqlNullable formats a nullable text value as a SQL literal.
+func sqlNullable(s *string) string {
+	if s == nil {
+		return "NULL"
+	}
+	return "'" + escapeSQLite(*s) + "'"
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// orderFixture returns the v26 fixture with more to-dos: TaskWater in the
+// Garden project after TaskSeeds and TaskShed, TaskRake under a heading of
+// the project, and three to-dos in Today, one of them in This Evening.
+func orderFixture(t *testing.T) string {
+	t.Helper()
+	path := fixtureDB(t, "things-v26")
+	today := encodeThingsDate(time.Now())
+	sqlite(t, path, fmt.Sprintf(`INSERT INTO TMTask VALUES
+		('TaskWater0000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Water', NULL, 1, NULL, 0, NULL, 3, 0, NULL, 'ProjectGarden000000001', NULL),
+		('HeadingTools0000000001', 0, 812538000, 812538000, 2, 0, NULL, 0, 'Tools', NULL, 1, NULL, 0, NULL, 0, 0, NULL, 'ProjectGarden000000001', NULL),
+		('TaskRake00000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Rake', NULL, 1, NULL, 0, NULL, 0, 0, NULL, NULL, 'HeadingTools0000000001'),
+		('TaskTodayA000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Today A', NULL, 1, %[1]d, 0, NULL, 0, 1, NULL, NULL, NULL),
+		('TaskTodayB000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Today B', NULL, 1, %[1]d, 0, NULL, 0, 2, NULL, NULL, NULL),
+		('TaskEvening00000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Evening', NULL, 1, %[1]d, 1, NULL, 0, 1, NULL, NULL, NULL);`, today))
+	SetPath(path)
+	t.Cleanup(func() { SetPath("") })
+	return path
+}
+
+// orderOf returns the IDs of the to-dos matching where in the given order.
+func orderOf(t *testing.T, where, order string) []string {
+	t.Helper()
+	var rows []struct {
+		UUID string `json:"uuid"`
+	}
+	if err := queryJSON(fmt.Sprintf(`SELECT uuid FROM TMTask WHERE type = 0 AND %s ORDER BY %s`, where, order), &rows); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i] = strings.TrimRight(r.UUID, "0123456789")
+	}
+	return ids
+}
+
+func TestMoveTaskSQL(t *testing.T) {
+	const project = `project = 'ProjectGarden000000001'`
+	const today = `todayIndex > 0`
+	tests := []struct {
+		name  string
+		id    string
+		req   models.MoveTaskRequest
+		where string
+		order string
+		want  []string
+	}{
+		{
+			name:  "before",
+			id:    "TaskWater0000000000001",
+			req:   models.MoveTaskRequest{Before: "TaskSeeds0000000000001"},
+			where: project, order: `"index"`,
+			want: []string{"TaskWater", "TaskSeeds", "TaskShed"},
+		},
+		{
+			name:  "after",
+			id:    "TaskSeeds0000000000001",
+			req:   models.MoveTaskRequest{After: "TaskShed00000000000001"},
+			where: project, order: `"index"`,
+			want: []string{"TaskShed", "TaskSeeds", "TaskWater"},
+		},
+		{
+			name:  "after the last",
+			id:    "TaskSeeds0000000000001",
+			req:   models.MoveTaskRequest{After: "TaskWater0000000000001"},
+			where: project, order: `"index"`,
+			want: []string{"TaskShed", "TaskWater", "TaskSeeds"},
+		},
+		{
+			name:  "today",
+			id:    "TaskTodayB000000000001",
+			req:   models.MoveTaskRequest{Before: "TaskTodayA000000000001", List: "today"},
+			where: today, order: `startBucket, todayIndex`,
+			want: []string{"TaskTodayB", "TaskTodayA", "TaskEvening"},
+		},
+		{
+			name:  "today into This Evening",
+			id:    "TaskTodayA000000000001",
+			req:   models.MoveTaskRequest{After: "TaskEvening00000000001", List: "today"},
+			where: today, order: `startBucket, todayIndex`,
+			want: []string{"TaskTodayB", "TaskEvening", "TaskTodayA"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := orderFixture(t)
+			sql, err := MoveTaskSQL(tt.id, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlite(t, path, sql+";")
+			if got := orderOf(t, tt.where, tt.order); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveTaskSQLErrors(t *testing.T) {
+	orderFixture(t)
+	tests := []struct {
+		name string
+		id   string
+		req  models.MoveTaskRequest
+		want error
+	}{
+		{"different lists", "TaskSeeds0000000000001", models.MoveTaskRequest{Before: "TaskOld000000000000001"}, ErrNotSiblings},
+		{"under a heading", "TaskRake00000000000001", models.MoveTaskRequest{Before: "TaskSeeds0000000000001"}, ErrNotSiblings},
+		{"not in today", "TaskSeeds0000000000001", models.MoveTaskRequest{Before: "TaskTodayA000000000001", List: "today"}, ErrNotInToday},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := MoveTaskSQL(tt.id, tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortTasks(t *testing.T) {
+	orderFixture(t)
+	tests := []struct {
+		list string
+		ids  []string
+		want []string
+	}{
+		{
+			list: "project",
+			ids:  []string{"TaskRake00000000000001", "TaskWater0000000000001", "TaskMissing00000000001", "TaskSeeds0000000000001"},
+			want: []string{"TaskSeeds0000000000001", "TaskWater0000000000001", "TaskRake00000000000001", "TaskMissing00000000001"},
+		},
+		{
+			list: "today",
+			ids:  []string{"TaskEvening00000000001", "TaskTodayB000000000001", "TaskTodayA000000000001"},
+			want: []string{"TaskTodayA000000000001", "TaskTodayB000000000001", "TaskEvening00000000001"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			tasks := make([]models.Task, len(tt.ids))
+			for i, id := range tt.ids {
+				tasks[i].ID = id
+			}
+			if err := SortTasks(tasks, tt.list); err != nil {
+				t.Fatal(err)
+			}
+			for i, task := range tasks {
+				if task.ID != tt.want[i] || task.Position != i+1 {
+					t.Errorf("tasks[%d] = %s at %d, want %s at %d", i, task.ID, task.Position, tt.want[i], i+1)
+				}
+			}
+		})
+	}
+}
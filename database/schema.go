@@ -0,0 +1,286 @@
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// schemaProfile describes one known layout of the Things database.
+type schemaProfile struct {
+	name string
+	// minVersion and maxVersion bound the database versions (Meta
+	// databaseVersion) known to use this layout.
+	minVersion, maxVersion int
+
+	// startDateColumn and deadlineColumn hold the scheduled and due dates of
+	// a TMTask row. timestampDates is set when they are Core Data
+	// timestamps rather than packed dates (see decodeThingsDate).
+	startDateColumn, deadlineColumn string
+	timestampDates                  bool
+
+	// columns lists the columns reads rely on, by table; writeColumns the
+	// ones direct writes set. Profiles without writeColumns are read-only.
+	columns      map[string][]string
+	writeColumns map[string][]string
+}
+
+// readColumns are the columns every profile reads besides the dates.
+var readColumns = map[string][]string{
+	"TMTask":          {"uuid", "title", "notes", "type", "status", "start", "project", "area", "heading", "index", "creationDate", "userModificationDate", "trashed", "todayIndex", "startBucket"},
+	"TMChecklistItem": {"uuid", "title", "status", "task", "index", "creationDate", "userModificationDate"},
+	"TMArea":          {"uuid", "title", "index"},
+	"TMTag":           {"uuid", "title", "index"},
+	"TMTaskTag":       {"tasks", "tags"},
+}
+
+// profiles lists the known layouts, newest first.
+var profiles = []schemaProfile{
+	{
+		name:            "current",
+		minVersion:      21,
+		maxVersion:      26,
+		startDateColumn: "startDate",
+		deadlineColumn:  "deadline",
+		columns:         withColumns(readColumns, "TMTask", "startDate", "deadline"),
+		writeColumns: map[string][]string{
+			"TMChecklistItem": {"uuid", "task", "title", "status", "index", "creationDate", "userModificationDate", "stopDate", "leavesTombstone"},
+			"TMTask":          {"uuid", "index", "todayIndex", "startBucket", "userModificationDate"},
+		},
+	},
+	{
+		// Before version 21 dates were Core Data timestamps and the due
+		// date was called dueDate.
+		name:            "legacy",
+		minVersion:      0,
+		maxVersion:      20,
+		startDateColumn: "startDate",
+		deadlineColumn:  "dueDate",
+		timestampDates:  true,
+		columns:         withColumns(readColumns, "TMTask", "startDate", "dueDate"),
+	},
+}
+
+// withColumns returns a copy of columns with extra added to table.
+func withColumns(columns map[string][]string, table string, extra ...string) map[string][]string {
+	out := make(map[string][]string, len(columns))
+	for t, c := range columns {
+		out[t] = c
+	}
+	out[table] = append(append([]string(nil), columns[table]...), extra...)
+	return out
+}
+
+// profileFor returns the profile for a database version and whether the
+// version is known. Unknown versions get the nearest profile.
+func profileFor(version int) (*schemaProfile, bool) {
+	for i := range profiles {
+		if version >= profiles[i].minVersion && version <= profiles[i].maxVersion {
+			return &profiles[i], true
+		}
+	}
+	if version > profiles[0].maxVersion {
+		return &profiles[0], false
+	}
+	return &profiles[len(profiles)-1], false
+}
+
+// dateExpr returns an SQL expression for column as a packed date. Core Data
+// timestamps are converted in local time.
+func (p *schemaProfile) dateExpr(column string) string {
+	if !p.timestampDates {
+		return column
+	}
+	part := func(format string) string {
+		return fmt.Sprintf(`CAST(strftime('%s', %s + 978307200, 'unixepoch', 'localtime') AS INTEGER)`, format, column)
+	}
+	return fmt.Sprintf(`((%s << 16) | (%s << 12) | (%s << 7))`, part("%Y"), part("%m"), part("%d"))
+}
+
+// startDate returns the scheduled date of table's rows (an alias or "") as
+// a packed date expression.
+func (p *schemaProfile) startDate(table string) string {
+	return p.dateExpr(qualify(table, p.startDateColumn))
+}
+
+// deadline returns the due date of table's rows as a packed date expression.
+func (p *schemaProfile) deadline(table string) string {
+	return p.dateExpr(qualify(table, p.deadlineColumn))
+}
+
+func qualify(table, column string) string {
+	if table == "" {
+		return column
+	}
+	return table + "." + column
+}
+
+// schemaState is the result of probing the database.
+type schemaState struct {
+	version int
+	profile *schemaProfile
+	known   bool
+	// missing and missingWrite name a column ("TMTask.deadline") the
+	// profile needs for reads or writes that the database lacks.
+	missing, missingWrite string
+}
+
+// writable reports whether direct writes support the database, or why not.
+func (s *schemaState) writable() error {
+	switch {
+	case !s.known:
+		return fmt.Errorf("%w: unknown version %d", ErrUnsupportedSchema, s.version)
+	case s.missing != "":
+		return fmt.Errorf("%w: version %d has no %s column", ErrUnsupportedSchema, s.version, s.missing)
+	case s.profile.writeColumns == nil:
+		return fmt.Errorf("%w: version %d is read-only", ErrUnsupportedSchema, s.version)
+	case s.missingWrite != "":
+		return fmt.Errorf("%w: version %d has no %s column", ErrUnsupportedSchema, s.version, s.missingWrite)
+	}
+	return nil
+}
+
+var versionPattern = regexp.MustCompile(`<integer>(\d+)</integer>|^(\d+)$`)
+
+// SchemaVersion returns the Things database version from the Meta table.
+// Things stores it as a property list, e.g. <integer>26</integer>.
+func SchemaVersion() (int, error) {
+	return defaultDB.schemaVersion()
+}
+
+func (db *DB) schemaVersion() (int, error) {
+	out, err := db.query(`SELECT value FROM Meta WHERE key = 'databaseVersion'`)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read database version: %w", err)
+	}
+	v, err := parseVersion(out)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read database version: %w", err)
+	}
+	return v, nil
+}
+
+// parseVersion parses the databaseVersion value of the Meta table.
+func parseVersion(value string) (int, error) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("unexpected value %q", value)
+	}
+	return strconv.Atoi(m[1] + m[2])
+}
+
+// probeSchema reads the database version and columns and matches them to a
+// profile.
+func (db *DB) probeSchema() (*schemaState, error) {
+	version, err := db.schemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	profile, known := profileFor(version)
+	s := &schemaState{version: version, profile: profile, known: known}
+
+	out, err := db.query(`SELECT m.name, p.name FROM sqlite_master m JOIN pragma_table_info(m.name) p WHERE m.type = 'table' AND m.name LIKE 'TM%'`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read database columns: %w", err)
+	}
+	have := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		if table, column, ok := strings.Cut(strings.TrimSpace(line), "\t"); ok {
+			have[table+"."+column] = true
+		}
+	}
+	s.missing = missingColumn(have, profile.columns)
+	s.missingWrite = missingColumn(have, profile.writeColumns)
+	return s, nil
+}
+
+// missingColumn returns the first of columns not in have, or "".
+func missingColumn(have map[string]bool, columns map[string][]string) string {
+	var missing []string
+	for table, cs := range columns {
+		for _, c := range cs {
+			if !have[table+"."+c] {
+				missing = append(missing, table+"."+c)
+			}
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	sort.Strings(missing)
+	return missing[0]
+}
+
+// schemaTTL is how long a probe, or a failure to probe, is reused for reads
+// and /health.
+const schemaTTL = time.Minute
+
+// readProfile returns the profile reads of the Things database use.
+func readProfile() *schemaProfile {
+	return defaultDB.profile()
+}
+
+// profile returns the profile reads use. If the database cannot be probed,
+// the newest profile is assumed.
+func (db *DB) profile() *schemaProfile {
+	s, err := db.cachedSchema()
+	if err != nil {
+		return &profiles[0]
+	}
+	return s.profile
+}
+
+// cachedSchema returns the last probe if it is fresh, and probes otherwise.
+// A failed probe is logged once and its error reused until it expires.
+func (db *DB) cachedSchema() (*schemaState, error) {
+	db.schema.Lock()
+	defer db.schema.Unlock()
+	if db.schema.checked.IsZero() || time.Since(db.schema.checked) > schemaTTL {
+		s, err := db.probeSchema()
+		if err != nil {
+			slog.Warn("cannot read the Things database schema", "error", err)
+		}
+		db.schema.state, db.schema.err, db.schema.checked = s, err, time.Now()
+	}
+	return db.schema.state, db.schema.err
+}
+
+// cacheSchema keeps a fresh probe for reads.
+func (db *DB) cacheSchema(s *schemaState) {
+	db.schema.Lock()
+	defer db.schema.Unlock()
+	db.schema.state, db.schema.err, db.schema.checked = s, nil, time.Now()
+}
+
+// Schema describes the schema of the Things database for /health.
+func Schema() models.SchemaInfo {
+	return defaultDB.Schema()
+}
+
+// Schema describes the database schema from the cached probe. A DB from
+// Open is never writable. /health does not require authentication, so a
+// failed probe is only reported as such; its details, which may include
+// file paths, are logged.
+func (db *DB) Schema() models.SchemaInfo {
+	s, err := db.cachedSchema()
+	if err != nil {
+		return models.SchemaInfo{Error: "cannot read the Things database"}
+	}
+
+	info := models.SchemaInfo{Version: s.version, Profile: s.profile.name, Writable: !db.readOnly && s.writable() == nil}
+	switch {
+	case !s.known:
+		info.Error = fmt.Sprintf("unknown Things database version %d; direct writes are refused", s.version)
+	case s.missing != "":
+		info.Error = fmt.Sprintf("the database has no %s column; reads may fail", s.missing)
+	case s.missingWrite != "" && !db.readOnly:
+		info.Error = fmt.Sprintf("the database has no %s column; direct writes are refused", s.missingWrite)
+	}
+	return info
+}
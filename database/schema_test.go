@@ -0,0 +1,244 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fixtureDB creates a database from testdata/<name>.sql and returns its path.
+func fixtureDB(t *testing.T, name string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	schema, err := os.ReadFile(filepath.Join("testdata", name+".sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.sqlite")
+	sqlite(t, path, string(schema))
+	return path
+}
+
+// sqlite runs statements against the database at path.
+func sqlite(t *testing.T, path, statements string) {
+	t.Helper()
+	cmd := exec.Command("sqlite3", path)
+	cmd.Stdin = strings.NewReader(statements)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sqlite3: %v: %s", err, out)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "26", want: 26},
+		{value: " 21\n", want: 21},
+		{value: "<?xml version=\"1.0\"?>\n<plist version=\"1.0\">\n<integer>26</integer>\n</plist>", want: 26},
+		{value: "<integer>3</integer>", want: 3},
+		{value: "", wantErr: true},
+		{value: "<string>26</string>", wantErr: true},
+		{value: "26a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseVersion(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseVersion(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestProfileFor(t *testing.T) {
+	tests := []struct {
+		version   int
+		wantName  string
+		wantKnown bool
+	}{
+		{26, "current", true},
+		{21, "current", true},
+		{20, "legacy", true},
+		{0, "legacy", true},
+		{27, "current", false},
+		{-1, "legacy", false},
+	}
+	for _, tt := range tests {
+		p, known := profileFor(tt.version)
+		if p.name != tt.wantName || known != tt.wantKnown {
+			t.Errorf("profileFor(%d) = %s, %v; want %s, %v", tt.version, p.name, known, tt.wantName, tt.wantKnown)
+		}
+	}
+}
+
+func TestMissingColumn(t *testing.T) {
+	columns := map[string][]string{
+		"TMTask": {"uuid", "deadline"},
+		"TMArea": {"uuid"},
+	}
+	have := map[string]bool{"TMTask.uuid": true, "TMTask.deadline": true, "TMArea.uuid": true}
+	if got := missingColumn(have, columns); got != "" {
+		t.Errorf("all columns present: got %q", got)
+	}
+
+	delete(have, "TMTask.deadline")
+	delete(have, "TMArea.uuid")
+	if got := missingColumn(have, columns); got != "TMArea.uuid" {
+		t.Errorf("got %q, want the first missing column in order, TMArea.uuid", got)
+	}
+	if got := missingColumn(have, nil); got != "" {
+		t.Errorf("no columns required: got %q", got)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	current, legacy := &profiles[0], &profiles[1]
+	tests := []struct {
+		name  string
+		state schemaState
+		want  string // substring of the error; "" for writable
+	}{
+		{"current", schemaState{version: 26, profile: current, known: true}, ""},
+		{"unknown version", schemaState{version: 27, profile: current}, "unknown version 27"},
+		{"missing read column", schemaState{version: 26, profile: current, known: true, missing: "TMTask.deadline"}, "no TMTask.deadline column"},
+		{"read-only profile", schemaState{version: 20, profile: legacy, known: true}, "read-only"},
+		{"missing write column", schemaState{version: 26, profile: current, known: true, missingWrite: "TMChecklistItem.stopDate"}, "no TMChecklistItem.stopDate column"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.state.writable()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("writable() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnsupportedSchema) || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("writable() = %v, want ErrUnsupportedSchema mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateExpr(t *testing.T) {
+	if got := profiles[0].deadline("t"); got != "t.deadline" {
+		t.Errorf("current deadline = %q, want the column itself", got)
+	}
+
+	path := fixtureDB(t, "things-v20")
+	t.Setenv("TZ", "UTC")
+
+	// 2026-10-20 12:00 UTC as a Core Data timestamp.
+	expr := profiles[1].dateExpr("814190400")
+	out, err := Open(path).query("SELECT " + expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "132819456"; out != want {
+		t.Fatalf("legacy date = %s, want %s (2026-10-20 packed)", out, want)
+	}
+	if got := dateValue(&[]int64{132819456}[0], 0); got != "2026-10-20" {
+		t.Fatalf("packed date decodes to %s", got)
+	}
+}
+
+func TestProbeFixtures(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		alter    string
+		version  int
+		profile  string
+		known    bool
+		missing  string
+		writeErr string // substring; "" for writable
+	}{
+		{fixture: "things-v26", version: 26, profile: "current", known: true},
+		{fixture: "things-v20", version: 20, profile: "legacy", known: true, writeErr: "read-only"},
+		{
+			fixture: "things-v26", version: 27, profile: "current", writeErr: "unknown version 27",
+			alter: `UPDATE Meta SET value = '<integer>27</integer>' WHERE key = 'databaseVersion';`,
+		},
+		{
+			fixture: "things-v26", version: 26, profile: "current", known: true, writeErr: "no TMChecklistItem.leavesTombstone column",
+			alter: `ALTER TABLE TMChecklistItem DROP COLUMN leavesTombstone;`,
+		},
+		{
+			fixture: "things-v26", version: 26, profile: "current", known: true, missing: "TMTask.deadline", writeErr: "no TMTask.deadline column",
+			alter: `ALTER TABLE TMTask RENAME COLUMN deadline TO dueDate;`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture+tt.alter, func(t *testing.T) {
+			path := fixtureDB(t, tt.fixture)
+			if tt.alter != "" {
+				sqlite(t, path, tt.alter)
+			}
+			s, err := Open(path).probeSchema()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.version != tt.version || s.profile.name != tt.profile || s.known != tt.known || s.missing != tt.missing {
+				t.Fatalf("probe = version %d, profile %s, known %v, missing %q", s.version, s.profile.name, s.known, s.missing)
+			}
+			err = s.writable()
+			if tt.writeErr == "" && err != nil || tt.writeErr != "" && (err == nil || !strings.Contains(err.Error(), tt.writeErr)) {
+				t.Fatalf("writable() = %v, want %q", err, tt.writeErr)
+			}
+		})
+	}
+}
+
+func TestSchemaInfo(t *testing.T) {
+	path := fixtureDB(t, "things-v26")
+	info := Open(path).Schema()
+	if info.Version != 26 || info.Profile != "current" || info.Writable || info.Error != "" {
+		t.Errorf("read-only profile database: %+v", info)
+	}
+
+	missing := &DB{path: filepath.Join(t.TempDir(), "missing", "main.sqlite")}
+	info = missing.Schema()
+	if info.Error == "" || strings.Contains(info.Error, "missing") {
+		t.Errorf("unreadable database should report a generic error, got %q", info.Error)
+	}
+}
+
+// TestFixtureTasks reads the same to-dos from a database of each profile.
+func TestFixtureTasks(t *testing.T) {
+	t.Setenv("TZ", "UTC")
+
+	var results [][]string
+	for _, fixture := range []string{"things-v26", "things-v20"} {
+		tasks, err := Open(fixtureDB(t, fixture)).Tasks(true)
+		if err != nil {
+			t.Fatalf("%s: %v", fixture, err)
+		}
+		var got []string
+		for _, task := range tasks {
+			var items []string
+			for _, ci := range task.ChecklistItems {
+				items = append(items, ci.Title+"/"+map[bool]string{true: "done", false: "open"}[ci.Completed])
+			}
+			got = append(got, strings.Join([]string{task.ID, task.Title, task.Status, task.Project, task.Area,
+				task.When, task.Due, strings.Join(task.Tags, ","), strings.Join(items, ",")}, "|"))
+		}
+		results = append(results, got)
+	}
+
+	want := []string{
+		"TaskSeeds0000000000001|Buy seeds|open|Garden||2026-10-20|2026-10-31|errand|Tomatoes/open,Basil/done",
+		"TaskShed00000000000001|Paint the shed|open|Garden||someday|2026-11-02||",
+		"TaskOld000000000000001|Old to-do|completed||Home|anytime|||",
+	}
+	for i, got := range results {
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("fixture %d tasks:\n got %q\nwant %q", i, got, want)
+		}
+	}
+}
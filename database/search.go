@@ -0,0 +1,183 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// SearchDocuments searches the Things database; see DB.SearchDocuments.
+func SearchDocuments(words []string, status string) ([]models.SearchDocument, error) {
+	return defaultDB.SearchDocuments(words, status)
+}
+
+// SearchDocuments loads to-dos, projects and areas that may match all of the
+// given lower-cased words in their title, notes, tags or checklist items.
+// The SQL LIKE filter is a coarse prefilter; exact matching and ranking are
+// left to the caller. LIKE only folds ASCII case, so words with other
+// characters are not prefiltered at all. status is "open", "completed",
+// "canceled" or "any"; areas are only included for "open" and "any".
+func (db *DB) SearchDocuments(words []string, status string) ([]models.SearchDocument, error) {
+	where := []string{"type IN (0, 1)"}
+	switch status {
+	case "open":
+		where = append(where, fmt.Sprintf("status = %d", statusOpen))
+	case "completed":
+		where = append(where, fmt.Sprintf("status = %d", statusCompleted))
+	case "canceled":
+		where = append(where, fmt.Sprintf("status = %d", statusCanceled))
+	}
+	for _, w := range words {
+		if !isASCII(w) {
+			continue
+		}
+		like := likePattern(w)
+		where = append(where, fmt.Sprintf(
+			`(title LIKE %[1]s ESCAPE '\' OR notes LIKE %[1]s ESCAPE '\'
+			  OR EXISTS (SELECT 1 FROM TMChecklistItem c WHERE c.task = TMTask.uuid AND c.title LIKE %[1]s ESCAPE '\')
+			  OR EXISTS (SELECT 1 FROM TMTaskTag tt JOIN TMTag g ON g.uuid = tt.tags WHERE tt.tasks = TMTask.uuid AND g.title LIKE %[1]s ESCAPE '\'))`,
+			like,
+		))
+	}
+
+	rows, err := db.taskRows(strings.Join(where, " AND "))
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+
+	names, err := db.containerNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []models.SearchDocument
+	for _, row := range rows {
+		doc := models.SearchDocument{
+			ID:      row.UUID,
+			Type:    "task",
+			Title:   row.Title,
+			Notes:   str(row.Notes),
+			Status:  statusName(row.Status),
+			Project: names[str(row.Project)],
+			Area:    names[str(row.Area)],
+			Tags:    row.Tags,
+		}
+		if row.Type == taskTypeProject {
+			doc.Type = "project"
+		}
+		for _, ci := range row.Checklist {
+			doc.Checklist = append(doc.Checklist, ci.Title)
+		}
+		docs = append(docs, doc)
+	}
+
+	if status != "open" && status != "any" {
+		return docs, nil
+	}
+
+	areaWhere := []string{"1 = 1"}
+	for _, w := range words {
+		if !isASCII(w) {
+			continue
+		}
+		like := likePattern(w)
+		areaWhere = append(areaWhere, fmt.Sprintf(
+			`(title LIKE %[1]s ESCAPE '\'
+			  OR EXISTS (SELECT 1 FROM TMAreaTag at JOIN TMTag g ON g.uuid = at.tags WHERE at.areas = TMArea.uuid AND g.title LIKE %[1]s ESCAPE '\'))`,
+			like,
+		))
+	}
+	var areas []struct {
+		UUID  string `json:"uuid"`
+		Title string `json:"title"`
+		Tags  string `json:"tags"`
+	}
+	areaSQL := fmt.Sprintf(
+		`SELECT uuid, title,
+		        COALESCE((SELECT group_concat(g.title, char(31)) FROM TMAreaTag at JOIN TMTag g ON g.uuid = at.tags WHERE at.areas = TMArea.uuid), '') AS tags
+		 FROM TMArea WHERE %s`,
+		strings.Join(areaWhere, " AND "),
+	)
+	if err := db.queryJSON(areaSQL, &areas); err != nil {
+		return nil, fmt.Errorf("failed to search areas: %w", err)
+	}
+	for _, a := range areas {
+		doc := models.SearchDocument{ID: a.UUID, Type: "area", Title: a.Title}
+		if a.Tags != "" {
+			doc.Tags = strings.Split(a.Tags, "\x1f")
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// containerNames maps project and area IDs of the Things database to their titles.
+func containerNames() (map[string]string, error) {
+	return defaultDB.containerNames()
+}
+
+// containerNames maps project and area IDs to their titles.
+func (db *DB) containerNames() (map[string]string, error) {
+	var rows []struct {
+		UUID  string `json:"uuid"`
+		Title string `json:"title"`
+	}
+	sql := `SELECT uuid, title FROM TMTask WHERE type = 1 AND trashed = 0
+	        UNION ALL SELECT uuid, title FROM TMArea`
+	if err := db.queryJSON(sql, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read project and area names: %w", err)
+	}
+	names := make(map[string]string, len(rows))
+	for _, r := range rows {
+		names[r.UUID] = r.Title
+	}
+	return names, nil
+}
+
+// likePattern returns a quoted SQL LIKE pattern matching s anywhere, with
+// LIKE wildcards in s escaped by backslash.
+func likePattern(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `%`, `\%`)
+	s = strings.ReplaceAll(s, `_`, `\_`)
+	return "'%" + escapeSQLite(s) + "%'"
+}
+
+// isASCII reports whether s has only ASCII characters, whose case SQLite's
+// LIKE folds.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// statusName converts a TMTask status to the API status string.
+func statusName(status int) string {
+	switch status {
+	case statusCompleted:
+		return "completed"
+	case statusCanceled:
+		return "canceled"
+	default:
+		return "open"
+	}
+}
+
+// statusValue converts an API status string to a TMTask status.
+func statusValue(status string) (int, bool) {
+	switch status {
+	case "open":
+		return statusOpen, true
+	case "completed":
+		return statusCompleted, true
+	case "canceled":
+		return statusCanceled, true
+	default:
+		return 0, false
+	}
+}
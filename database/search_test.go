@@ -0,0 +1,53 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestSearchDocumentsPrefilter(t *testing.T) {
+	path := fixtureDB(t, "things-v26")
+	sqlite(t, path, `UPDATE TMTask SET title = 'Записаться к Дантисту' WHERE uuid = 'TaskShed00000000000001';`)
+	db := Open(path)
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		// LIKE folds ASCII case, so the prefilter applies.
+		{[]string{"seeds"}, []string{"TaskSeeds0000000000001"}},
+		{[]string{"garden"}, []string{"ProjectGarden000000001"}},
+		// It does not fold Cyrillic case, so such words are left to the caller.
+		{[]string{"дантист"}, []string{"ProjectGarden000000001", "TaskSeeds0000000000001", "TaskShed00000000000001", "AreaHome00000000000001"}},
+		{[]string{"дантист", "seeds"}, []string{"TaskSeeds0000000000001"}},
+	}
+	for _, tt := range tests {
+		docs, err := db.SearchDocuments(tt.words, "open")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range docs {
+			got = append(got, d.ID)
+		}
+		if !sameSet(got, tt.want) {
+			t.Errorf("SearchDocuments(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s] == 0 {
+			return false
+		}
+		seen[s]--
+	}
+	return true
+}
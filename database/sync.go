@@ -0,0 +1,280 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// syncOverlap is subtracted from the time recorded in a sync token, so rows
+// written while a sync was being read are returned again next time rather
+// than missed.
+const syncOverlap = 5 * time.Second
+
+// SyncToken is the decoded form of the opaque token returned by /sync.
+type SyncToken struct {
+	Version int     `json:"v"`
+	Since   float64 `json:"t"` // Core Data timestamp
+	Areas   string  `json:"a"` // hash of area IDs and names
+}
+
+// ParseSyncToken decodes a token produced by Sync.
+func ParseSyncToken(s string) (*SyncToken, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+	var tok SyncToken
+	if err := json.Unmarshal(data, &tok); err != nil || tok.Version != 1 || tok.Since <= 0 {
+		return nil, fmt.Errorf("invalid sync token")
+	}
+	return &tok, nil
+}
+
+func (t SyncToken) encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Sync returns the changes since tok, or a full snapshot if tok is nil, older
+// than maxAge, or older than the oldest deletion Things still records in
+// TMTombstone. Created and updated to-dos, projects and checklist items are
+// found by userModificationDate; to-dos and projects moved to the trash and
+// tombstoned IDs are reported as deleted. Areas have no modification date,
+// so all areas are returned as updated whenever any area changes.
+func Sync(tok *SyncToken, maxAge time.Duration) (*models.SyncResponse, error) {
+	now := coreDataTimestamp()
+
+	areas, areaHash, err := syncAreas()
+	if err != nil {
+		return nil, err
+	}
+
+	full := tok == nil || now-tok.Since > maxAge.Seconds()
+	if !full {
+		var oldest []struct {
+			Date *float64 `json:"deletionDate"`
+		}
+		if err := queryJSON(`SELECT MIN(deletionDate) AS deletionDate FROM TMTombstone`, &oldest); err != nil {
+			return nil, fmt.Errorf("failed to read tombstones: %w", err)
+		}
+		if len(oldest) > 0 && oldest[0].Date != nil && *oldest[0].Date > tok.Since {
+			full = true
+		}
+	}
+
+	resp := &models.SyncResponse{
+		Token:   SyncToken{Version: 1, Since: now - syncOverlap.Seconds(), Areas: areaHash}.encode(),
+		Full:    full,
+		Created: models.NewSyncChanges(),
+		Updated: models.NewSyncChanges(),
+		Deleted: []string{},
+	}
+
+	if full {
+		resp.Created.Areas = areas
+		if err := syncRows(0, &resp.Created, &resp.Created); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	if areaHash != tok.Areas {
+		resp.Updated.Areas = areas
+	}
+	if err := syncRows(tok.Since, &resp.Created, &resp.Updated); err != nil {
+		return nil, err
+	}
+
+	since := fmt.Sprintf("%f", tok.Since)
+
+	var deleted []struct {
+		UUID string `json:"uuid"`
+	}
+	deletedSQL := fmt.Sprintf(
+		`SELECT uuid FROM TMTask WHERE type IN (%[1]d, %[2]d) AND trashed = 1 AND userModificationDate > %[3]s
+		 UNION SELECT deletedObjectUUID FROM TMTombstone WHERE deletionDate > %[3]s`,
+		taskTypeToDo, taskTypeProject, since,
+	)
+	if err := queryJSON(deletedSQL, &deleted); err != nil {
+		return nil, fmt.Errorf("failed to read deletions: %w", err)
+	}
+	for _, d := range deleted {
+		resp.Deleted = append(resp.Deleted, d.UUID)
+	}
+	return resp, nil
+}
+
+// syncRows adds to-dos, projects and checklist items (of non-trashed to-dos)
+// modified after since to created or updated, depending on whether they were
+// also created after since. A zero since reads everything.
+func syncRows(since float64, created, updated *models.SyncChanges) error {
+	modified := "1 = 1"
+	if since > 0 {
+		modified = fmt.Sprintf("userModificationDate > %f", since)
+	}
+
+	rows, err := getTaskRows(fmt.Sprintf("type IN (%d, %d) AND %s", taskTypeToDo, taskTypeProject, modified))
+	if err != nil {
+		return fmt.Errorf("failed to read tasks: %w", err)
+	}
+	isNew := make(map[string]bool)
+	var todoRows, projectRows []taskRow
+	for _, row := range rows {
+		isNew[row.UUID] = row.Created > since
+		if row.Type == taskTypeProject {
+			projectRows = append(projectRows, row)
+		} else {
+			todoRows = append(todoRows, row)
+		}
+	}
+
+	tasks, err := taskModels(todoRows)
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		// Checklist items are reported on their own.
+		t.ChecklistItems = nil
+		if isNew[t.ID] {
+			created.Tasks = append(created.Tasks, t)
+		} else {
+			updated.Tasks = append(updated.Tasks, t)
+		}
+	}
+
+	projects, err := projectModels(projectRows)
+	if err != nil {
+		return err
+	}
+	for _, p := range projects {
+		if isNew[p.ID] {
+			created.Projects = append(created.Projects, p)
+		} else {
+			updated.Projects = append(updated.Projects, p)
+		}
+	}
+
+	var itemRows []checklistRow
+	itemSQL := fmt.Sprintf(
+		`SELECT uuid, title, status, task, COALESCE(creationDate, 0) AS creationDate,
+		        COALESCE(userModificationDate, 0) AS userModificationDate
+		 FROM TMChecklistItem
+		 WHERE %s AND task IN (SELECT uuid FROM TMTask WHERE trashed = 0)
+		 ORDER BY task, "index"`, modified)
+	if err := queryJSON(itemSQL, &itemRows); err != nil {
+		return fmt.Errorf("failed to read checklist items: %w", err)
+	}
+	for _, row := range itemRows {
+		if row.Created > since {
+			created.ChecklistItems = append(created.ChecklistItems, checklistModel(row))
+		} else {
+			updated.ChecklistItems = append(updated.ChecklistItems, checklistModel(row))
+		}
+	}
+	return nil
+}
+
+// projectModels converts project rows of the Things database; see DB.projectModels.
+func projectModels(rows []taskRow) ([]models.Project, error) {
+	return defaultDB.projectModels(rows)
+}
+
+// projectModels converts project rows into API projects. TaskCount is the
+// number of open to-dos in the project.
+func (db *DB) projectModels(rows []taskRow) ([]models.Project, error) {
+	projects := make([]models.Project, 0, len(rows))
+	if len(rows) == 0 {
+		return projects, nil
+	}
+
+	names, err := db.containerNames()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.UUID
+	}
+	var counts []struct {
+		Project string `json:"project"`
+		Count   int    `json:"count"`
+	}
+	countSQL := fmt.Sprintf(
+		`SELECT COALESCE(t.project, h.project) AS project, COUNT(*) AS count
+		 FROM TMTask t LEFT JOIN TMTask h ON h.uuid = t.heading
+		 WHERE t.type = %d AND t.status = %d AND t.trashed = 0 AND COALESCE(t.project, h.project) IN (%s)
+		 GROUP BY 1`, taskTypeToDo, statusOpen, sqlInList(ids))
+	if err := db.queryJSON(countSQL, &counts); err != nil {
+		return nil, fmt.Errorf("failed to count project tasks: %w", err)
+	}
+	byProject := make(map[string]int, len(counts))
+	for _, c := range counts {
+		byProject[c.Project] = c.Count
+	}
+
+	for _, row := range rows {
+		projects = append(projects, models.Project{
+			ID:         row.UUID,
+			Name:       row.Title,
+			Area:       names[str(row.Area)],
+			Notes:      str(row.Notes),
+			Status:     statusName(row.Status),
+			TaskCount:  byProject[row.UUID],
+			ModifiedAt: formatCoreDataTime(row.Modified),
+		})
+	}
+	return projects, nil
+}
+
+// syncAreas returns all areas and a hash of their IDs and names.
+func syncAreas() ([]models.Area, string, error) {
+	var rows []struct {
+		UUID  string `json:"uuid"`
+		Title string `json:"title"`
+	}
+	if err := queryJSON(`SELECT uuid, title FROM TMArea ORDER BY "index"`, &rows); err != nil {
+		return nil, "", fmt.Errorf("failed to read areas: %w", err)
+	}
+
+	areas := make([]models.Area, 0, len(rows))
+	keys := make([]string, 0, len(rows))
+	for _, r := range rows {
+		areas = append(areas, models.Area{ID: r.UUID, Name: r.Title})
+		keys = append(keys, r.UUID+"\x1f"+r.Title)
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\x1e")))
+	return areas, hex.EncodeToString(sum[:8]), nil
+}
+
+// ModificationDates returns the userModificationDate of the given TMTask rows
+// as RFC 3339, keyed by ID.
+func ModificationDates(ids []string) (map[string]string, error) {
+	dates := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return dates, nil
+	}
+
+	var rows []struct {
+		UUID     string  `json:"uuid"`
+		Modified float64 `json:"modified"`
+	}
+	sql := fmt.Sprintf(
+		`SELECT uuid, COALESCE(userModificationDate, 0) AS modified FROM TMTask WHERE uuid IN (%s)`,
+		sqlInList(ids))
+	if err := queryJSON(sql, &rows); err != nil {
+		return nil, fmt.Errorf("failed to read modification dates: %w", err)
+	}
+	for _, r := range rows {
+		dates[r.UUID] = formatCoreDataTime(r.Modified)
+	}
+	return dates, nil
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestModificationDatesManyIDs(t *testing.T) {
+	SetPath(fixtureDB(t, "things-v26"))
+	t.Cleanup(func() { SetPath("") })
+
+	// Far more IDs than fit in one command-line argument.
+	ids := []string{"TaskSeeds0000000000001"}
+	for i := 0; i < 20000; i++ {
+		ids = append(ids, fmt.Sprintf("TaskMissing%011d", i))
+	}
+	dates, err := ModificationDates(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dates["TaskSeeds0000000000001"]; !ok || len(dates) != 1 {
+		t.Errorf("ModificationDates = %v, want only TaskSeeds0000000000001", dates)
+	}
+}
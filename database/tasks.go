@@ -0,0 +1,275 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// TMTask.status values.
+const (
+	statusOpen      = 0
+	statusCanceled  = 2
+	statusCompleted = 3
+)
+
+// TMTask.start values.
+const (
+	startInbox   = 0
+	startAnytime = 1
+	startSomeday = 2
+)
+
+// taskRow is a to-do, project or heading row from TMTask.
+type taskRow struct {
+	UUID      string  `json:"uuid"`
+	Title     string  `json:"title"`
+	Notes     *string `json:"notes"`
+	Type      int     `json:"type"`
+	Status    int     `json:"status"`
+	Start     int     `json:"start"`
+	StartDate *int64  `json:"startDate"`
+	Deadline  *int64  `json:"deadline"`
+	Project   *string `json:"project"`
+	Area      *string `json:"area"`
+	Heading   *string `json:"heading"`
+	Index     int     `json:"index"`
+	Created   float64 `json:"creationDate"`
+	Modified  float64 `json:"userModificationDate"`
+
+	Tags      []string       `json:"-"`
+	Checklist []checklistRow `json:"-"`
+}
+
+// checklistRow is a row from TMChecklistItem.
+type checklistRow struct {
+	UUID     string  `json:"uuid"`
+	Title    string  `json:"title"`
+	Status   int     `json:"status"`
+	Task     string  `json:"task"`
+	Created  float64 `json:"creationDate"`
+	Modified float64 `json:"userModificationDate"`
+}
+
+// taskColumns returns the TMTask columns of a taskRow, with dates read as
+// the profile stores them.
+func taskColumns(p *schemaProfile) string {
+	return fmt.Sprintf(`uuid, title, notes, type, status, start, %s AS startDate, %s AS deadline, project, area, heading, "index",
+	COALESCE(creationDate, 0) AS creationDate, COALESCE(userModificationDate, 0) AS userModificationDate`, p.startDate(""), p.deadline(""))
+}
+
+// queryJSON runs a sqlite3 query on the Things database in JSON output mode
+// and decodes the rows into dest.
+func queryJSON(sql string, dest any) error {
+	return defaultDB.queryJSON(sql, dest)
+}
+
+// queryJSON runs a sqlite3 query in JSON output mode and decodes the rows
+// into dest. Unlike query, values may safely contain tabs and newlines. The
+// query is passed on standard input, as a command-line argument is limited
+// in length (128 KB on Linux) and queries may list many IDs.
+func (db *DB) queryJSON(sql string, dest any) error {
+	args, err := db.args("-bail", "-json")
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sqlite3", args...)
+	cmd.Stdin = strings.NewReader(sql)
+	out, err := cmd.Output()
+	if err != nil {
+		errMsg := err.Error()
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			errMsg = strings.TrimSpace(string(ee.Stderr))
+		}
+		return fmt.Errorf("sqlite3 error: %s", errMsg)
+	}
+	if len(strings.TrimSpace(string(out))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(out, dest); err != nil {
+		return fmt.Errorf("failed to decode sqlite3 output: %w", err)
+	}
+	return nil
+}
+
+// getTaskRows returns TMTask rows of the Things database; see DB.taskRows.
+func getTaskRows(where string) ([]taskRow, error) {
+	return defaultDB.taskRows(where)
+}
+
+// taskRows returns TMTask rows matching the given WHERE clause (excluding
+// trashed rows), ordered by their manual index, with tags and checklist items loaded.
+func (db *DB) taskRows(where string) ([]taskRow, error) {
+	var rows []taskRow
+	sql := fmt.Sprintf(`SELECT %s FROM TMTask WHERE (%s) AND trashed = 0 ORDER BY "index" ASC`, taskColumns(db.profile()), where)
+	if err := db.queryJSON(sql, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return rows, nil
+	}
+
+	byID := make(map[string]*taskRow, len(rows))
+	for i := range rows {
+		byID[rows[i].UUID] = &rows[i]
+	}
+	// Select the rows again rather than listing their IDs, which for a
+	// full snapshot would be every to-do and project.
+	in := fmt.Sprintf(`SELECT uuid FROM TMTask WHERE (%s) AND trashed = 0`, where)
+
+	var tags []struct {
+		Task  string `json:"task"`
+		Title string `json:"title"`
+	}
+	tagSQL := fmt.Sprintf(
+		`SELECT tt.tasks AS task, tg.title AS title FROM TMTaskTag tt
+		 JOIN TMTag tg ON tg.uuid = tt.tags
+		 WHERE tt.tasks IN (%s) ORDER BY tg."index" ASC`, in)
+	if err := db.queryJSON(tagSQL, &tags); err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	for _, t := range tags {
+		if row := byID[t.Task]; row != nil {
+			row.Tags = append(row.Tags, t.Title)
+		}
+	}
+
+	var items []checklistRow
+	itemSQL := fmt.Sprintf(
+		`SELECT uuid, title, status, task, COALESCE(userModificationDate, 0) AS userModificationDate FROM TMChecklistItem
+		 WHERE task IN (%s) ORDER BY "index" ASC`, in)
+	if err := db.queryJSON(itemSQL, &items); err != nil {
+		return nil, fmt.Errorf("failed to get checklist items: %w", err)
+	}
+	for _, item := range items {
+		if row := byID[item.Task]; row != nil {
+			row.Checklist = append(row.Checklist, item)
+		}
+	}
+	return rows, nil
+}
+
+// getTaskRow returns the single TMTask row with the given ID.
+func getTaskRow(id string) (*taskRow, error) {
+	rows, err := getTaskRows(fmt.Sprintf(`uuid = '%s'`, escapeSQLite(id)))
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("item %s not found", id)
+	}
+	return &rows[0], nil
+}
+
+// sqlInList formats ids as a quoted, comma-separated SQL list.
+func sqlInList(ids []string) string {
+	quoted := make([]string, len(ids))
+	for i, id := range ids {
+		quoted[i] = "'" + escapeSQLite(id) + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// decodeThingsDate decodes a packed Things date (year<<16 | month<<12 | day<<7),
+// as stored in TMTask.startDate and TMTask.deadline.
+func decodeThingsDate(v int64) time.Time {
+	year := int(v >> 16)
+	month := time.Month((v >> 12) & 0xF)
+	day := int((v >> 7) & 0x1F)
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+// str returns the value of a nullable text column.
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// GetTasks returns the to-dos of the Things database; see DB.Tasks.
+func GetTasks(includeClosed bool) ([]models.Task, error) {
+	return defaultDB.Tasks(includeClosed)
+}
+
+// Tasks returns all to-dos that are not in the trash as API tasks.
+// Completed and canceled to-dos are only included when includeClosed is set.
+// Due and scheduled dates are formatted as YYYY-MM-DD.
+func (db *DB) Tasks(includeClosed bool) ([]models.Task, error) {
+	where := fmt.Sprintf("type = %d", taskTypeToDo)
+	if !includeClosed {
+		where += fmt.Sprintf(" AND status = %d", statusOpen)
+	}
+	rows, err := db.taskRows(where)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tasks: %w", err)
+	}
+	return db.taskModels(rows)
+}
+
+// taskModels converts to-do rows of the Things database; see DB.taskModels.
+func taskModels(rows []taskRow) ([]models.Task, error) {
+	return defaultDB.taskModels(rows)
+}
+
+// taskModels converts to-do rows into API tasks, resolving project and area
+// names. To-dos under a heading report the heading's project.
+func (db *DB) taskModels(rows []taskRow) ([]models.Task, error) {
+	names, err := db.containerNames()
+	if err != nil {
+		return nil, err
+	}
+	var headings []struct {
+		UUID    string  `json:"uuid"`
+		Project *string `json:"project"`
+	}
+	if err := db.queryJSON(fmt.Sprintf(`SELECT uuid, project FROM TMTask WHERE type = %d`, taskTypeHeading), &headings); err != nil {
+		return nil, fmt.Errorf("failed to read headings: %w", err)
+	}
+	headingProject := make(map[string]string, len(headings))
+	for _, h := range headings {
+		headingProject[h.UUID] = str(h.Project)
+	}
+
+	tasks := make([]models.Task, 0, len(rows))
+	for _, row := range rows {
+		project := str(row.Project)
+		if project == "" && row.Heading != nil {
+			project = headingProject[*row.Heading]
+		}
+		task := models.Task{
+			ID:         row.UUID,
+			Title:      row.Title,
+			Notes:      str(row.Notes),
+			Status:     statusName(row.Status),
+			Project:    names[project],
+			Area:       names[str(row.Area)],
+			Tags:       row.Tags,
+			Due:        dateValue(row.Deadline, 0),
+			When:       whenValue(row, 0),
+			CreatedAt:  formatCoreDataTime(row.Created),
+			ModifiedAt: formatCoreDataTime(row.Modified),
+		}
+		for _, ci := range row.Checklist {
+			task.ChecklistItems = append(task.ChecklistItems, checklistModel(ci))
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// checklistModel converts a checklist row into an API checklist item.
+func checklistModel(row checklistRow) models.ChecklistItem {
+	return models.ChecklistItem{
+		ID:         row.UUID,
+		TaskID:     row.Task,
+		Title:      row.Title,
+		Completed:  row.Status == statusCompleted,
+		ModifiedAt: formatCoreDataTime(row.Modified),
+	}
+}
@@ -0,0 +1,37 @@
+-- Things database version 20 ("legacy" profile): startDate and dueDate
+-- hold Core Data timestamps (seconds since 2001-01-01 UTC), and there are
+-- no columns used only by direct writes. The rows match things-v26.sql.
+CREATE TABLE Meta (key TEXT PRIMARY KEY, value TEXT);
+CREATE TABLE TMArea (uuid TEXT PRIMARY KEY, title TEXT, visible INTEGER, "index" INTEGER);
+CREATE TABLE TMTask (
+	uuid TEXT PRIMARY KEY, creationDate REAL, userModificationDate REAL,
+	type INTEGER, status INTEGER, stopDate REAL, trashed INTEGER, title TEXT, notes TEXT,
+	start INTEGER, startDate REAL, startBucket INTEGER, dueDate REAL,
+	"index" INTEGER, todayIndex INTEGER, area TEXT, project TEXT, heading TEXT
+);
+CREATE TABLE TMChecklistItem (
+	uuid TEXT PRIMARY KEY, userModificationDate REAL, creationDate REAL, title TEXT,
+	status INTEGER, "index" INTEGER, task TEXT
+);
+CREATE TABLE TMTag (uuid TEXT PRIMARY KEY, title TEXT, shortcut TEXT, "index" INTEGER);
+CREATE TABLE TMTaskTag (tasks TEXT, tags TEXT);
+CREATE TABLE TMAreaTag (areas TEXT, tags TEXT);
+CREATE TABLE TMTombstone (uuid TEXT PRIMARY KEY, deletionDate REAL, deletedObjectUUID TEXT);
+
+INSERT INTO Meta VALUES ('databaseVersion', '<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<integer>20</integer>
+</plist>');
+
+INSERT INTO TMArea VALUES ('AreaHome00000000000001', 'Home', 1, 0);
+INSERT INTO TMTag VALUES ('TagErrand0000000000001', 'errand', NULL, 0);
+INSERT INTO TMTask VALUES
+	('ProjectGarden000000001', 812538000, 812538000, 1, 0, NULL, 0, 'Garden', NULL, 1, NULL, 0, NULL, 0, 0, 'AreaHome00000000000001', NULL, NULL),
+	('TaskSeeds0000000000001', 812538000, 812538000, 0, 0, NULL, 0, 'Buy seeds', 'Tomatoes', 1, 814190400, 0, 815140800, 1, 0, NULL, 'ProjectGarden000000001', NULL),
+	('TaskShed00000000000001', 812538000, 812538000, 0, 0, NULL, 0, 'Paint the shed', NULL, 2, NULL, 0, 815313600, 2, 0, NULL, 'ProjectGarden000000001', NULL),
+	('TaskOld000000000000001', 812538000, 812538000, 0, 3, 812538000, 0, 'Old to-do', NULL, 1, NULL, 0, NULL, 3, 0, 'AreaHome00000000000001', NULL, NULL);
+INSERT INTO TMTaskTag VALUES ('TaskSeeds0000000000001', 'TagErrand0000000000001');
+INSERT INTO TMChecklistItem VALUES
+	('ItemTomato000000000001', 812538000, 812538000, 'Tomatoes', 0, 0, 'TaskSeeds0000000000001'),
+	('ItemBasil0000000000001', 812538000, 812538000, 'Basil', 3, 1, 'TaskSeeds0000000000001');
@@ -0,0 +1,196 @@
+//go:build !readonly
+
+package database
+
+import (
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// ReadOnlyBuild reports whether the binary was built with the readonly tag,
+// which leaves out every direct write to the Things database.
+const ReadOnlyBuild = false
+
+// directWrites holds the settings of direct writes and serializes them.
+var directWrites struct {
+	sync.Mutex
+	enabled       bool
+	snapshotDir   string
+	thingsRunning func() bool
+	requireQuit   bool
+	snapshot      string // taken before the first write, then reused
+}
+
+// ConfigureDirectWrites enables or disables direct writes to the Things
+// database. Before the first write the database is copied into
+// snapshotDir. thingsRunning, if set, is used to warn about writes made
+// while Things is open, which it may not notice until it reloads, or with
+// requireQuit to refuse them.
+func ConfigureDirectWrites(enabled bool, snapshotDir string, thingsRunning func() bool, requireQuit bool) {
+	directWrites.Lock()
+	defer directWrites.Unlock()
+	directWrites.enabled = enabled
+	directWrites.snapshotDir = snapshotDir
+	directWrites.thingsRunning = thingsRunning
+	directWrites.requireQuit = requireQuit
+}
+
+// DirectWritesEnabled reports whether direct writes are enabled.
+func DirectWritesEnabled() bool {
+	directWrites.Lock()
+	defer directWrites.Unlock()
+	return directWrites.enabled
+}
+
+// execWrite runs statements in one transaction after checking the schema
+// and, on the first write, taking a snapshot. BEGIN IMMEDIATE takes the
+// write lock up front and .timeout waits while Things holds it; Things keeps
+// reading from the WAL meanwhile. -bail stops at the first failing
+// statement, and the open transaction is rolled back when sqlite3 exits.
+func execWrite(statements string) error {
+	directWrites.Lock()
+	defer directWrites.Unlock()
+
+	if !directWrites.enabled {
+		return ErrDirectWritesDisabled
+	}
+	if err := checkWriteSchema(); err != nil {
+		return err
+	}
+	running := directWrites.thingsRunning != nil && directWrites.thingsRunning()
+	if running && directWrites.requireQuit {
+		return ErrThingsRunning
+	}
+	if directWrites.snapshot == "" {
+		path, err := snapshot(directWrites.snapshotDir)
+		if err != nil {
+			return fmt.Errorf("cannot snapshot database before the first write: %w", err)
+		}
+		directWrites.snapshot = path
+		slog.Info("saved snapshot of the Things database before the first direct write", "path", path)
+	}
+	if running {
+		slog.Warn("writing to the Things database while Things is running; the change may not show until Things reloads")
+	}
+
+	dbPath, err := thingsDBPath()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sqlite3", "-bail", "-cmd", ".timeout 5000", dbPath)
+	cmd.Stdin = strings.NewReader("BEGIN IMMEDIATE;\n" + statements + ";\nCOMMIT;")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		errMsg := strings.TrimSpace(string(out))
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return fmt.Errorf("sqlite3 error: %s", errMsg)
+	}
+	return nil
+}
+
+// AddChecklistItemDirect adds a checklist item directly via SQLite.
+// Used when no auth token is available. Items appear in API reads
+// but may not immediately appear in Things UI.
+func AddChecklistItemDirect(taskID string, req models.CreateChecklistItemRequest) (*models.ChecklistItem, error) {
+	if err := models.ValidateThingsID(taskID); err != nil {
+		return nil, err
+	}
+
+	uuid := generateUUID()
+	now := coreDataTimestamp()
+
+	sql := addChecklistItemSQL(uuid, taskID, req.Title, now)
+	if err := execWrite(sql); err != nil {
+		return nil, fmt.Errorf("failed to add checklist item: %w", err)
+	}
+
+	return &models.ChecklistItem{
+		ID:         uuid,
+		Title:      req.Title,
+		Completed:  false,
+		ModifiedAt: formatCoreDataTime(now),
+	}, nil
+}
+
+// UpdateChecklistItem updates a checklist item via SQLite.
+func UpdateChecklistItem(taskID, itemID string, req models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+	sql, err := UpdateChecklistItemSQL(taskID, itemID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := execWrite(sql); err != nil {
+		return nil, fmt.Errorf("failed to update checklist item: %w", err)
+	}
+
+	readSQL := fmt.Sprintf(
+		`SELECT uuid, title, status, COALESCE(userModificationDate, 0) FROM TMChecklistItem WHERE uuid='%s' AND task='%s'`,
+		escapeSQLite(itemID),
+		escapeSQLite(taskID),
+	)
+	out, err := query(readSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read updated checklist item: %w", err)
+	}
+	items := parseChecklistItems(out)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("checklist item not found")
+	}
+	return &items[0], nil
+}
+
+// DeleteChecklistItem removes a checklist item via SQLite.
+func DeleteChecklistItem(taskID, itemID string) error {
+	sql, err := DeleteChecklistItemSQL(taskID, itemID)
+	if err != nil {
+		return err
+	}
+
+	if err := execWrite(sql); err != nil {
+		return fmt.Errorf("failed to delete checklist item: %w", err)
+	}
+	return nil
+}
+
+// MoveTask repositions a to-do directly before or after a sibling. In the
+// "today" list, todayIndex is changed and both to-dos must be in Today;
+// otherwise "index" is changed and both must share the same project,
+// heading or area. Siblings at or beyond the target position are shifted.
+func MoveTask(id string, req models.MoveTaskRequest) error {
+	sql, err := MoveTaskSQL(id, req)
+	if err != nil {
+		return err
+	}
+	if err := execWrite(sql); err != nil {
+		return fmt.Errorf("failed to move task: %w", err)
+	}
+	return nil
+}
+
+// RestoreFrom replaces the contents of the database with the backup at src
+// using the SQLite backup API. Things must not be running, or it will keep
+// working on (and may write back) what it had loaded.
+func RestoreFrom(src string) error {
+	dbPath, err := thingsDBPath()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("sqlite3", "-bail", "-cmd", ".timeout 5000", dbPath, fmt.Sprintf(".restore %q", src))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		errMsg := strings.TrimSpace(string(out))
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return fmt.Errorf("failed to restore database: %s", errMsg)
+	}
+	return nil
+}
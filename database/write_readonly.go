@@ -0,0 +1,51 @@
+//go:build readonly
+
+package database
+
+import (
+	"fmt"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// ReadOnlyBuild reports whether the binary was built with the readonly tag,
+// which leaves out every direct write to the Things database.
+const ReadOnlyBuild = true
+
+// errReadOnlyBuild is returned by the direct writes left out of a readonly
+// build.
+var errReadOnlyBuild = fmt.Errorf("%w in a read-only build", ErrDirectWritesDisabled)
+
+// AddChecklistItemDirect is not available in a readonly build.
+func AddChecklistItemDirect(taskID string, req models.CreateChecklistItemRequest) (*models.ChecklistItem, error) {
+	return nil, errReadOnlyBuild
+}
+
+// UpdateChecklistItem is not available in a readonly build.
+func UpdateChecklistItem(taskID, itemID string, req models.UpdateChecklistItemRequest) (*models.ChecklistItem, error) {
+	return nil, errReadOnlyBuild
+}
+
+// DeleteChecklistItem is not available in a readonly build.
+func DeleteChecklistItem(taskID, itemID string) error {
+	return errReadOnlyBuild
+}
+
+// MoveTask is not available in a readonly build.
+func MoveTask(id string, req models.MoveTaskRequest) error {
+	return errReadOnlyBuild
+}
+
+// ConfigureDirectWrites has no effect in a readonly build.
+func ConfigureDirectWrites(enabled bool, snapshotDir string, thingsRunning func() bool, requireQuit bool) {
+}
+
+// DirectWritesEnabled always reports false in a readonly build.
+func DirectWritesEnabled() bool {
+	return false
+}
+
+// RestoreFrom is not available in a readonly build.
+func RestoreFrom(src string) error {
+	return errReadOnlyBuild
+}
@@ -0,0 +1,184 @@
+// Package events turns changes in the Things database into a feed of
+// created, updated, completed and deleted events for SSE clients and
+// webhooks.
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// emittedTTL is how long an event emitted by an API write suppresses the
+// same change when the watcher later detects it in the database.
+const emittedTTL = time.Minute
+
+// subscriberBuffer is how many events may queue for a slow subscriber before
+// it is disconnected. Disconnected clients resume with Last-Event-ID.
+const subscriberBuffer = 256
+
+// Feed keeps the most recent events in memory and fans new ones out to
+// subscribers. Event IDs have the form "<boot>-<seq>", where boot identifies
+// this server run, so IDs from an earlier run are detected on resume.
+type Feed struct {
+	mu        sync.Mutex
+	boot      string
+	seq       uint64
+	size      int
+	recent    []models.Event
+	subs      map[chan models.Event]struct{}
+	listeners []func(models.Event)
+	emitted   map[string]time.Time // eventKey -> expiry
+}
+
+// NewFeed returns a feed that retains the last size events for resuming.
+func NewFeed(size int) *Feed {
+	return &Feed{
+		boot:    strconv.FormatInt(time.Now().Unix(), 36),
+		size:    size,
+		subs:    make(map[chan models.Event]struct{}),
+		emitted: make(map[string]time.Time),
+	}
+}
+
+// AddListener registers fn to be called with every published event, after
+// it has been assigned an ID. fn must not block.
+func (f *Feed) AddListener(fn func(models.Event)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners = append(f.listeners, fn)
+}
+
+// Emit publishes events for changes made through the API. The watcher will
+// later see the same changes in the database; those are not published again.
+func (f *Feed) Emit(events ...models.Event) {
+	f.mu.Lock()
+	expiry := time.Now().Add(emittedTTL)
+	for i := range events {
+		events[i].Source = models.SourceAPI
+		f.emitted[eventKey(events[i])] = expiry
+	}
+	f.mu.Unlock()
+
+	f.publish(events)
+}
+
+// Detected publishes events found by diffing database snapshots, skipping
+// changes already emitted by the API.
+func (f *Feed) Detected(events []models.Event) {
+	f.mu.Lock()
+	now := time.Now()
+	var fresh []models.Event
+	for _, e := range events {
+		key := eventKey(e)
+		if expiry, ok := f.emitted[key]; ok && now.Before(expiry) {
+			delete(f.emitted, key)
+			continue
+		}
+		e.Source = models.SourceThings
+		fresh = append(fresh, e)
+	}
+	for key, expiry := range f.emitted {
+		if !now.Before(expiry) {
+			delete(f.emitted, key)
+		}
+	}
+	f.mu.Unlock()
+
+	f.publish(fresh)
+}
+
+// publish assigns IDs and timestamps to events, retains them and sends them
+// to every subscriber and listener.
+func (f *Feed) publish(events []models.Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	f.mu.Lock()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for i := range events {
+		e := &events[i]
+		f.seq++
+		e.ID = fmt.Sprintf("%s-%d", f.boot, f.seq)
+		e.Time = now
+
+		f.recent = append(f.recent, *e)
+		if len(f.recent) > f.size {
+			f.recent = f.recent[len(f.recent)-f.size:]
+		}
+
+		for ch := range f.subs {
+			select {
+			case ch <- *e:
+			default:
+				delete(f.subs, ch)
+				close(ch)
+			}
+		}
+	}
+	listeners := f.listeners
+	f.mu.Unlock()
+
+	for _, e := range events {
+		for _, fn := range listeners {
+			fn(e)
+		}
+	}
+}
+
+// Subscribe registers a subscriber. If lastEventID is set, the retained
+// events after it are returned as backlog; resumed is false when that ID is
+// unknown (from an earlier run or too old), in which case the client should
+// refetch state. The returned channel is closed when the subscriber falls
+// behind; call cancel when done.
+func (f *Feed) Subscribe(lastEventID string) (backlog []models.Event, resumed bool, ch <-chan models.Event, cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	resumed = true
+	if lastEventID != "" {
+		backlog, resumed = f.since(lastEventID)
+	}
+
+	c := make(chan models.Event, subscriberBuffer)
+	f.subs[c] = struct{}{}
+	cancel = func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if _, ok := f.subs[c]; ok {
+			delete(f.subs, c)
+			close(c)
+		}
+	}
+	return backlog, resumed, c, cancel
+}
+
+// since returns retained events after id. Must be called with f.mu held.
+func (f *Feed) since(id string) ([]models.Event, bool) {
+	boot, seqStr, ok := strings.Cut(id, "-")
+	if !ok || boot != f.boot {
+		return nil, false
+	}
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil || seq > f.seq {
+		return nil, false
+	}
+
+	oldest := f.seq - uint64(len(f.recent)) // seq of the event before recent[0]
+	if seq < oldest {
+		return nil, false
+	}
+	backlog := make([]models.Event, len(f.recent)-int(seq-oldest))
+	copy(backlog, f.recent[seq-oldest:])
+	return backlog, true
+}
+
+// eventKey identifies a change for de-duplication between Emit and Detected.
+func eventKey(e models.Event) string {
+	return e.Action + "/" + e.Type + "/" + e.ItemID
+}
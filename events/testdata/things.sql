@@ -0,0 +1,21 @@
+-- Minimal Things database for the change feed tests: the columns
+-- database.Snapshot reads, with one area, one project and two to-dos.
+CREATE TABLE TMArea (uuid TEXT PRIMARY KEY, title TEXT, "index" INTEGER);
+CREATE TABLE TMTask (
+	uuid TEXT PRIMARY KEY, title TEXT, notes TEXT, type INTEGER, status INTEGER,
+	trashed INTEGER, project TEXT, area TEXT, heading TEXT, "index" INTEGER,
+	creationDate REAL, userModificationDate REAL
+);
+CREATE TABLE TMChecklistItem (
+	uuid TEXT PRIMARY KEY, title TEXT, status INTEGER, task TEXT, "index" INTEGER,
+	creationDate REAL, userModificationDate REAL
+);
+
+INSERT INTO TMArea VALUES ('AreaHome00000000000001', 'Home', 0);
+INSERT INTO TMTask VALUES
+	('ProjectGarden000000001', 'Garden', NULL, 1, 0, 0, NULL, 'AreaHome00000000000001', NULL, 0, 750000000, 750000000),
+	('TaskWater000000000001', 'Water plants', NULL, 0, 0, 0, 'ProjectGarden000000001', NULL, NULL, 1, 750000000, 750000000),
+	('TaskMow00000000000001', 'Mow the lawn', NULL, 0, 0, 0, 'ProjectGarden000000001', NULL, NULL, 2, 750000000, 750000000);
+INSERT INTO TMChecklistItem VALUES
+	('ItemHose00000000000001', 'Find the hose', 0, 'TaskWater000000000001', 0, 750000000, 750000000),
+	('ItemCan000000000000001', 'Fill the can', 0, 'TaskWater000000000001', 1, 750000000, 750000000);
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// Watcher polls the Things database and publishes the differences between
+// successive snapshots to a Feed. Snapshots are only taken when the database
+// version changes.
+type Watcher struct {
+	feed     *Feed
+	version  func() (string, error)
+	snapshot func() ([]models.ItemVersion, error)
+	interval time.Duration
+
+	lastVersion string
+	prev        map[string]models.ItemVersion
+	failing     bool
+}
+
+// NewWatcher returns a watcher that reads state through version and
+// snapshot (database.ChangeVersion and database.Snapshot in production).
+func NewWatcher(feed *Feed, version func() (string, error), snapshot func() ([]models.ItemVersion, error), interval time.Duration) *Watcher {
+	return &Watcher{feed: feed, version: version, snapshot: snapshot, interval: interval}
+}
+
+// Run polls until ctx is canceled.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Poll(); err != nil {
+			if !w.failing {
+				slog.Warn("change feed: cannot read Things database", "error", err)
+			}
+			w.failing = true
+		} else if w.failing {
+			slog.Info("change feed: reading Things database again")
+			w.failing = false
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks the database once and publishes any changes. The first
+// successful snapshot becomes the baseline and publishes nothing.
+func (w *Watcher) Poll() error {
+	version, err := w.version()
+	if err != nil {
+		return err
+	}
+	if w.prev != nil && version == w.lastVersion {
+		return nil
+	}
+
+	items, err := w.snapshot()
+	if err != nil {
+		return err
+	}
+	next := make(map[string]models.ItemVersion, len(items))
+	for _, item := range items {
+		next[item.Type+"/"+item.ID] = item
+	}
+
+	if w.prev != nil {
+		w.feed.Detected(Diff(w.prev, next))
+	}
+	w.prev = next
+	w.lastVersion = version
+	return nil
+}
+
+var actionOrder = map[string]int{
+	models.ActionCreated:   0,
+	models.ActionUpdated:   1,
+	models.ActionCompleted: 2,
+	models.ActionDeleted:   3,
+}
+
+var typeOrder = map[string]int{
+	models.ItemTypeArea:          0,
+	models.ItemTypeProject:       1,
+	models.ItemTypeTask:          2,
+	models.ItemTypeChecklistItem: 3,
+}
+
+// Diff returns the events that turn prev into next, keyed by "type/id".
+// Events are ordered by action (created, updated, completed, deleted),
+// then type (containers before their contents) and ID. A status change to completed is reported as completed;
+// any other change to title, status or modification date as updated.
+func Diff(prev, next map[string]models.ItemVersion) []models.Event {
+	var events []models.Event
+	for key, n := range next {
+		p, ok := prev[key]
+		switch {
+		case !ok:
+			events = append(events, event(models.ActionCreated, n))
+		case n.Status != p.Status && n.Status == "completed":
+			events = append(events, event(models.ActionCompleted, n))
+		case n.Title != p.Title || n.Status != p.Status || n.Modified != p.Modified:
+			events = append(events, event(models.ActionUpdated, n))
+		}
+	}
+	for key, p := range prev {
+		if _, ok := next[key]; !ok {
+			events = append(events, event(models.ActionDeleted, p))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if a.Action != b.Action {
+			return actionOrder[a.Action] < actionOrder[b.Action]
+		}
+		if a.Type != b.Type {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		return a.ItemID < b.ItemID
+	})
+	return events
+}
+
+func event(action string, item models.ItemVersion) models.Event {
+	return models.Event{
+		Action: action,
+		Type:   item.Type,
+		ItemID: item.ID,
+		Title:  item.Title,
+		Status: item.Status,
+		TaskID: item.TaskID,
+	}
+}
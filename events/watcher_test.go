@@ -0,0 +1,236 @@
+package events
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// fixtureDB creates a Things database from testdata/things.sql and points
+// the database package at it.
+func fixtureDB(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	schema, err := os.ReadFile(filepath.Join("testdata", "things.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.sqlite")
+	sqlite(t, path, string(schema))
+	database.SetPath(path)
+	t.Cleanup(func() { database.SetPath("") })
+	return path
+}
+
+// sqlite runs statements against the database at path.
+func sqlite(t *testing.T, path, statements string) {
+	t.Helper()
+	cmd := exec.Command("sqlite3", path)
+	cmd.Stdin = strings.NewReader(statements)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sqlite3: %v: %s", err, out)
+	}
+}
+
+type change struct {
+	Action, Type, ItemID string
+}
+
+func changes(events []models.Event) []change {
+	out := make([]change, len(events))
+	for i, e := range events {
+		out[i] = change{e.Action, e.Type, e.ItemID}
+	}
+	return out
+}
+
+func TestWatcherFixture(t *testing.T) {
+	path := fixtureDB(t)
+
+	feed := NewFeed(100)
+	w := NewWatcher(feed, database.ChangeVersion, database.Snapshot, time.Second)
+	if err := w.Poll(); err != nil {
+		t.Fatalf("baseline poll: %v", err)
+	}
+	_, _, ch, cancel := feed.Subscribe("")
+	defer cancel()
+
+	// Polling an unchanged database publishes nothing.
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ch) != 0 {
+		t.Fatalf("unchanged database published %d events", len(ch))
+	}
+
+	sqlite(t, path, `
+		INSERT INTO TMTask VALUES ('TaskRake00000000000001', 'Rake leaves', NULL, 0, 0, 0, 'ProjectGarden000000001', NULL, NULL, 3, 750000100, 750000100);
+		UPDATE TMTask SET status = 3, userModificationDate = 750000100 WHERE uuid = 'TaskWater000000000001';
+		UPDATE TMTask SET trashed = 1 WHERE uuid = 'TaskMow00000000000001';
+		UPDATE TMTask SET title = 'Backyard' WHERE uuid = 'ProjectGarden000000001';
+		UPDATE TMArea SET title = 'House' WHERE uuid = 'AreaHome00000000000001';
+		DELETE FROM TMChecklistItem WHERE uuid = 'ItemCan000000000000001';
+	`)
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []models.Event
+	for len(ch) > 0 {
+		got = append(got, <-ch)
+	}
+	want := []change{
+		{models.ActionCreated, models.ItemTypeTask, "TaskRake00000000000001"},
+		{models.ActionUpdated, models.ItemTypeArea, "AreaHome00000000000001"},
+		{models.ActionUpdated, models.ItemTypeProject, "ProjectGarden000000001"},
+		{models.ActionCompleted, models.ItemTypeTask, "TaskWater000000000001"},
+		{models.ActionDeleted, models.ItemTypeTask, "TaskMow00000000000001"},
+		{models.ActionDeleted, models.ItemTypeChecklistItem, "ItemCan000000000000001"},
+	}
+	if !reflect.DeepEqual(changes(got), want) {
+		t.Fatalf("events = %+v, want %+v", changes(got), want)
+	}
+	for _, e := range got {
+		if e.Source != models.SourceThings || e.ID == "" {
+			t.Errorf("event %+v: want source %q and an ID", e, models.SourceThings)
+		}
+	}
+	if got[0].Title != "Rake leaves" || got[3].Status != "completed" {
+		t.Errorf("events carry wrong details: %+v", got)
+	}
+}
+
+func TestWatcherSkipsEmitted(t *testing.T) {
+	path := fixtureDB(t)
+
+	feed := NewFeed(100)
+	w := NewWatcher(feed, database.ChangeVersion, database.Snapshot, time.Second)
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An API write emits its event before the watcher sees the change.
+	feed.Emit(models.Event{Action: models.ActionCompleted, Type: models.ItemTypeTask, ItemID: "TaskMow00000000000001"})
+	_, _, ch, cancel := feed.Subscribe("")
+	defer cancel()
+
+	sqlite(t, path, `
+		UPDATE TMTask SET status = 3 WHERE uuid = 'TaskMow00000000000001';
+		UPDATE TMChecklistItem SET status = 3 WHERE uuid = 'ItemHose00000000000001';
+	`)
+	if err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []models.Event
+	for len(ch) > 0 {
+		got = append(got, <-ch)
+	}
+	want := []change{{models.ActionCompleted, models.ItemTypeChecklistItem, "ItemHose00000000000001"}}
+	if !reflect.DeepEqual(changes(got), want) {
+		t.Fatalf("events = %+v, want %+v", changes(got), want)
+	}
+}
+
+func TestFeedResume(t *testing.T) {
+	feed := NewFeed(2)
+	feed.Detected([]models.Event{
+		{Action: models.ActionCreated, Type: models.ItemTypeTask, ItemID: "a"},
+		{Action: models.ActionCreated, Type: models.ItemTypeTask, ItemID: "b"},
+		{Action: models.ActionCreated, Type: models.ItemTypeTask, ItemID: "c"},
+	})
+
+	backlog, resumed, _, cancel := feed.Subscribe(feed.boot + "-2")
+	cancel()
+	if !resumed || len(backlog) != 1 || backlog[0].ItemID != "c" {
+		t.Errorf("resume after 2: backlog %+v, resumed %v", backlog, resumed)
+	}
+
+	// Event 1 has dropped out of the buffer.
+	if _, resumed, _, cancel := feed.Subscribe(feed.boot + "-0"); resumed {
+		t.Error("resume from an evicted event succeeded")
+	} else {
+		cancel()
+	}
+	if _, resumed, _, cancel := feed.Subscribe("earlier-run-1"); resumed {
+		t.Error("resume from another run succeeded")
+	} else {
+		cancel()
+	}
+}
+
+func TestDiff(t *testing.T) {
+	item := func(typ, id, title, status string, modified float64) models.ItemVersion {
+		return models.ItemVersion{Type: typ, ID: id, Title: title, Status: status, Modified: modified}
+	}
+	index := func(items ...models.ItemVersion) map[string]models.ItemVersion {
+		m := make(map[string]models.ItemVersion, len(items))
+		for _, it := range items {
+			m[it.Type+"/"+it.ID] = it
+		}
+		return m
+	}
+
+	tests := []struct {
+		name       string
+		prev, next map[string]models.ItemVersion
+		want       []change
+	}{
+		{
+			name: "no change",
+			prev: index(item("task", "t", "A", "open", 1)),
+			next: index(item("task", "t", "A", "open", 1)),
+		},
+		{
+			name: "modification date only",
+			prev: index(item("task", "t", "A", "open", 1)),
+			next: index(item("task", "t", "A", "open", 2)),
+			want: []change{{"updated", "task", "t"}},
+		},
+		{
+			name: "reopened is an update",
+			prev: index(item("task", "t", "A", "completed", 1)),
+			next: index(item("task", "t", "A", "open", 1)),
+			want: []change{{"updated", "task", "t"}},
+		},
+		{
+			name: "canceled is an update",
+			prev: index(item("task", "t", "A", "open", 1)),
+			next: index(item("task", "t", "A", "canceled", 1)),
+			want: []change{{"updated", "task", "t"}},
+		},
+		{
+			name: "containers first within an action",
+			prev: index(),
+			next: index(item("checklist_item", "c", "", "open", 0), item("task", "t", "", "open", 0),
+				item("project", "p", "", "open", 0), item("area", "a", "", "", 0)),
+			want: []change{{"created", "area", "a"}, {"created", "project", "p"}, {"created", "task", "t"}, {"created", "checklist_item", "c"}},
+		},
+		{
+			name: "same ID in two types",
+			prev: index(item("task", "x", "A", "open", 1)),
+			next: index(item("project", "x", "A", "open", 1)),
+			want: []change{{"created", "project", "x"}, {"deleted", "task", "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changes(Diff(tt.prev, tt.next))
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// access is what a restricted client may see: the areas on its token, and
+// the projects on its token or inside those areas. To-dos are matched by the
+// IDs of the project and area they are in, never by name, since names need
+// not be unique. A nil *access allows everything.
+type access struct {
+	areaIDs        map[string]bool
+	projectIDs     map[string]bool
+	listed         map[string]bool   // projects named on the token itself
+	projectArea    map[string]string // project ID to area ID
+	tasks          map[string]models.TaskContainer
+	projectsByName map[string][]string // project IDs by name
+	areasByName    map[string][]string // area IDs by name
+}
+
+// outOfScope is returned (as 403) for writes that would place an item outside
+// the client's areas and projects.
+const outOfScope = "target is outside the areas and projects this token can access"
+
+// accessFor returns the restrictions of the request's client, or nil if the
+// client is not restricted.
+func accessFor(r *http.Request) (*access, error) {
+	client, ok := auth.ClientFrom(r.Context())
+	if !ok || !client.Restricted() {
+		return nil, nil
+	}
+
+	containers, err := database.Containers()
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := database.TaskContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	a := &access{
+		areaIDs:        make(map[string]bool),
+		projectIDs:     make(map[string]bool),
+		listed:         make(map[string]bool),
+		projectArea:    make(map[string]string),
+		tasks:          tasks,
+		projectsByName: make(map[string][]string),
+		areasByName:    make(map[string][]string),
+	}
+	for _, id := range client.Areas {
+		a.areaIDs[id] = true
+	}
+	for _, id := range client.Projects {
+		a.listed[id] = true
+	}
+	for _, c := range containers {
+		// AppleScript matches names with trailing spaces trimmed.
+		name := strings.TrimRight(c.Name, " ")
+		switch c.Type {
+		case models.ItemTypeArea:
+			a.areasByName[name] = append(a.areasByName[name], c.ID)
+		case models.ItemTypeProject:
+			a.projectsByName[name] = append(a.projectsByName[name], c.ID)
+			a.projectArea[c.ID] = c.AreaID
+			if a.listed[c.ID] || a.areaIDs[c.AreaID] {
+				a.projectIDs[c.ID] = true
+			}
+		}
+	}
+	return a, nil
+}
+
+// allowsIn reports whether an item in the given container is visible. The
+// project takes precedence; items in neither (the Inbox) are not visible to
+// restricted clients.
+func (a *access) allowsIn(c models.TaskContainer) bool {
+	if a == nil {
+		return true
+	}
+	if c.ProjectID != "" {
+		return a.projectIDs[c.ProjectID]
+	}
+	return c.AreaID != "" && a.areaIDs[c.AreaID]
+}
+
+// allowsTaskID reports whether the to-do with the given ID is visible.
+func (a *access) allowsTaskID(id string) bool {
+	if a == nil {
+		return true
+	}
+	c, ok := a.tasks[id]
+	return ok && a.allowsIn(c)
+}
+
+func (a *access) allowsTask(t models.Task) bool {
+	return a.allowsTaskID(t.ID)
+}
+
+// lookup returns the ID of the project or area called name in byName, and
+// how many there are. An empty name matches nothing and counts as one.
+func lookup(byName map[string][]string, name string) (string, int) {
+	if name == "" {
+		return "", 1
+	}
+	ids := byName[name]
+	if len(ids) != 1 {
+		return "", len(ids)
+	}
+	return ids[0], 1
+}
+
+// resolve turns the project and area names of a write into IDs. It reports
+// false, writing 409 if a name is shared by several projects or areas (Things
+// would pick one of them) and 403 if it does not exist.
+func (a *access) resolve(w http.ResponseWriter, project, area string) (models.TaskContainer, bool) {
+	var c models.TaskContainer
+	for _, name := range []struct {
+		itemType string
+		value    string
+		byName   map[string][]string
+		id       *string
+	}{
+		{models.ItemTypeProject, project, a.projectsByName, &c.ProjectID},
+		{models.ItemTypeArea, area, a.areasByName, &c.AreaID},
+	} {
+		id, n := lookup(name.byName, name.value)
+		switch {
+		case n > 1:
+			writeError(w, http.StatusConflict, fmt.Sprintf("%d %ss are named %q; this token can only use unique names", n, name.itemType, name.value))
+			return c, false
+		case n == 0:
+			writeError(w, http.StatusForbidden, outOfScope)
+			return c, false
+		}
+		*name.id = id
+	}
+	return c, true
+}
+
+// guardNames checks that a to-do created in the project and area with the
+// given names is visible, writing 409 or 403 otherwise.
+func guardNames(w http.ResponseWriter, a *access, project, area string) bool {
+	if a == nil {
+		return true
+	}
+	c, ok := a.resolve(w, project, area)
+	return ok && guardTarget(w, a.allowsIn(c))
+}
+
+// guardTaskUpdate checks that to-do t stays visible after an update setting
+// its project and/or area by name, writing 409 or 403 otherwise.
+func guardTaskUpdate(w http.ResponseWriter, a *access, t models.Task, project, area *string) bool {
+	if a == nil {
+		return true
+	}
+	target, ok := a.resolve(w, deref(project), deref(area))
+	if !ok {
+		return false
+	}
+	c := a.tasks[t.ID]
+	if project != nil {
+		c.ProjectID = target.ProjectID
+	}
+	if area != nil {
+		c.AreaID = target.AreaID
+		if c.AreaID != "" && !a.areaIDs[c.AreaID] {
+			return guardTarget(w, false)
+		}
+	}
+	return guardTarget(w, a.allowsIn(c))
+}
+
+// guardAreaName checks that a project may be created in, or moved to, the
+// area with the given name, writing 409 or 403 otherwise.
+func guardAreaName(w http.ResponseWriter, a *access, name string) bool {
+	if a == nil {
+		return true
+	}
+	c, ok := a.resolve(w, "", name)
+	return ok && guardTarget(w, c.AreaID != "" && a.areaIDs[c.AreaID])
+}
+
+// allowsProjectIn reports whether the project with the given ID stays
+// visible in the area with the given ID (empty for none). Projects named on
+// the token are visible anywhere.
+func (a *access) allowsProjectIn(id, areaID string) bool {
+	return a == nil || a.listed[id] || (areaID != "" && a.areaIDs[areaID])
+}
+
+func (a *access) allowsProject(id string) bool {
+	return a == nil || a.projectIDs[id]
+}
+
+func (a *access) allowsArea(id string) bool {
+	return a == nil || a.areaIDs[id]
+}
+
+// allowsList reports whether a Things list ID (a project or area, as used by
+// duplicate's list_id and area_id) is visible.
+func (a *access) allowsList(id string) bool {
+	return a == nil || a.projectIDs[id] || a.areaIDs[id]
+}
+
+func (a *access) filterTasks(tasks []models.Task) []models.Task {
+	if a == nil {
+		return tasks
+	}
+	out := make([]models.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if a.allowsTask(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (a *access) filterProjects(projects []models.Project) []models.Project {
+	if a == nil {
+		return projects
+	}
+	out := make([]models.Project, 0, len(projects))
+	for _, p := range projects {
+		if a.allowsProject(p.ID) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (a *access) filterAreas(areas []models.Area) []models.Area {
+	if a == nil {
+		return areas
+	}
+	out := make([]models.Area, 0, len(areas))
+	for _, area := range areas {
+		if a.allowsArea(area.ID) {
+			out = append(out, area)
+		}
+	}
+	return out
+}
+
+func (a *access) filterSearchDocuments(docs []models.SearchDocument) []models.SearchDocument {
+	if a == nil {
+		return docs
+	}
+	out := make([]models.SearchDocument, 0, len(docs))
+	for _, d := range docs {
+		var ok bool
+		switch d.Type {
+		case models.ItemTypeProject:
+			ok = a.allowsProject(d.ID)
+		case models.ItemTypeArea:
+			ok = a.allowsArea(d.ID)
+		default:
+			ok = a.allowsTaskID(d.ID)
+		}
+		if ok {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// requestAccess returns the client's restrictions, writing a 500 response
+// and returning false if they cannot be loaded.
+func requestAccess(w http.ResponseWriter, r *http.Request) (*access, bool) {
+	a, err := accessFor(r)
+	if err != nil {
+		internalError(w, err)
+		return nil, false
+	}
+	return a, true
+}
+
+// guardTask checks that the to-do with the given ID is visible to the
+// client, writing 404 and returning false otherwise. For restricted clients
+// the current to-do is returned so writes can check where it would end up.
+func guardTask(w http.ResponseWriter, r *http.Request, id string) (*access, *models.Task, bool) {
+	a, ok := requestAccess(w, r)
+	if !ok || a == nil {
+		return a, nil, ok
+	}
+	task, err := database.GetTask(id)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "task not found")
+			return nil, nil, false
+		}
+		internalError(w, err)
+		return nil, nil, false
+	}
+	if !a.allowsTask(*task) {
+		writeError(w, http.StatusNotFound, "task not found")
+		return nil, nil, false
+	}
+	return a, task, true
+}
+
+// guardProject checks that the project is visible, writing 404 otherwise.
+func guardProject(w http.ResponseWriter, r *http.Request, id string) (*access, bool) {
+	a, ok := requestAccess(w, r)
+	if ok && !a.allowsProject(id) {
+		writeError(w, http.StatusNotFound, "project not found")
+		return nil, false
+	}
+	return a, ok
+}
+
+// guardArea checks that the area is visible, writing 404 otherwise.
+func guardArea(w http.ResponseWriter, r *http.Request, id string) (*access, bool) {
+	a, ok := requestAccess(w, r)
+	if ok && !a.allowsArea(id) {
+		writeError(w, http.StatusNotFound, "area not found")
+		return nil, false
+	}
+	return a, ok
+}
+
+// guardTarget rejects a write whose resulting container is not visible.
+func guardTarget(w http.ResponseWriter, allowed bool) bool {
+	if !allowed {
+		writeError(w, http.StatusForbidden, outOfScope)
+	}
+	return allowed
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/database"
+)
+
+// useFixtureDB points the database package at the database package's
+// version 26 fixture with the given statements applied.
+func useFixtureDB(t *testing.T, statements string) {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	schema, err := os.ReadFile(filepath.Join("..", "database", "testdata", "things-v26.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.sqlite")
+	cmd := exec.Command("sqlite3", path)
+	cmd.Stdin = strings.NewReader(string(schema) + statements)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sqlite3: %v: %s", err, out)
+	}
+	database.SetPath(path)
+	t.Cleanup(func() { database.SetPath("") })
+}
+
+func TestGuardTask(t *testing.T) {
+	// A second area with a project, a heading in the Garden project with a
+	// to-do under it, and a to-do in the Inbox.
+	useFixtureDB(t, `
+		INSERT INTO TMArea VALUES ('AreaWork00000000000001', 'Work', 1, 1);
+		INSERT INTO TMTask VALUES
+			('ProjectReport000000001', 0, 812538000, 812538000, 1, 0, NULL, 0, 'Report', NULL, 1, NULL, 0, NULL, 1, 0, 'AreaWork00000000000001', NULL, NULL),
+			('HeadingTools0000000001', 0, 812538000, 812538000, 2, 0, NULL, 0, 'Tools', NULL, 1, NULL, 0, NULL, 0, 0, NULL, 'ProjectGarden000000001', NULL),
+			('TaskRake00000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Rake', NULL, 1, NULL, 0, NULL, 0, 0, NULL, NULL, 'HeadingTools0000000001'),
+			('TaskInbox0000000000001', 0, 812538000, 812538000, 0, 0, NULL, 0, 'Call back', NULL, 0, NULL, 0, NULL, 4, 0, NULL, NULL, NULL);`)
+
+	tests := []struct {
+		name   string
+		client auth.Client
+		task   string
+		want   bool
+	}{
+		{"task in a disallowed project", auth.Client{Projects: []string{"ProjectReport000000001"}}, "TaskSeeds0000000000001", false},
+		{"task in an allowed project", auth.Client{Projects: []string{"ProjectGarden000000001"}}, "TaskSeeds0000000000001", true},
+		{"task under a heading in an allowed project", auth.Client{Projects: []string{"ProjectGarden000000001"}}, "TaskRake00000000000001", true},
+		{"task under a heading in a project of an allowed area", auth.Client{Areas: []string{"AreaHome00000000000001"}}, "TaskRake00000000000001", true},
+		{"task in an allowed area", auth.Client{Areas: []string{"AreaHome00000000000001"}}, "TaskOld000000000000001", true},
+		{"task in another area's project", auth.Client{Areas: []string{"AreaWork00000000000001"}}, "TaskSeeds0000000000001", false},
+		{"area-scoped token and an Inbox task", auth.Client{Areas: []string{"AreaHome00000000000001"}}, "TaskInbox0000000000001", false},
+		{"unrestricted token and an Inbox task", auth.Client{}, "TaskInbox0000000000001", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tt.client
+			client.Name = "scoped"
+			r := httptest.NewRequest(http.MethodGet, "/tasks/"+tt.task, nil)
+			r = r.WithContext(auth.WithClient(r.Context(), &client))
+			w := httptest.NewRecorder()
+
+			_, _, ok := guardTask(w, r, tt.task)
+			if ok != tt.want {
+				t.Fatalf("guardTask = %v, want %v", ok, tt.want)
+			}
+			if !ok && w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want 404", w.Code)
+			}
+		})
+	}
+}
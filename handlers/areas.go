@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/database"
 	"github.com/egorkaBurkenya/things3-api/models"
 )
 
@@ -31,28 +32,40 @@ func AreasRouter(w http.ResponseWriter, r *http.Request) {
 			getAreaByID(w, r, id)
 		case suffix == "" && r.Method == http.MethodPatch:
 			updateArea(w, r, id)
+		case suffix == "" && r.Method == http.MethodDelete:
+			deleteArea(w, r, id)
 		default:
 			writeError(w, http.StatusNotFound, "not found")
 		}
 	}
 }
 
-func getAllAreas(w http.ResponseWriter, _ *http.Request) {
+func getAllAreas(w http.ResponseWriter, r *http.Request) {
 	areas, err := applescript.GetAllAreas()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, areas)
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	areas = a.filterAreas(areas)
+	stampAreas(areas)
+	writeEntity(w, r, http.StatusOK, areas)
 }
 
-func getAreaByID(w http.ResponseWriter, _ *http.Request, id string) {
+func getAreaByID(w http.ResponseWriter, r *http.Request, id string) {
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid area id")
 		return
 	}
 
-	area, err := applescript.GetAreaByID(id)
+	if _, ok := guardArea(w, r, id); !ok {
+		return
+	}
+
+	area, err := loadArea(id)
 	if err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "area not found")
@@ -61,10 +74,17 @@ func getAreaByID(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, area)
+	writeEntity(w, r, http.StatusOK, area)
 }
 
 func createArea(w http.ResponseWriter, r *http.Request) {
+	rec := auditWrite(r, "area.create", models.ItemTypeArea)
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateAreaRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -76,20 +96,49 @@ func createArea(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a, ok := requestAccess(w, r)
+	if !ok || !guardTarget(w, a == nil) {
+		return
+	}
+
+	rec.Diff(nil, req)
+	if dry {
+		writeDryRun(w, rec, "area.create", models.Area{Name: req.Name}, nil, appleScriptPreview(applescript.CreateAreaScript(req)))
+		return
+	}
 	area, err := applescript.CreateArea(req)
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, area)
+	stampProjects(area.Projects)
+	rec.AddTarget(area.ID)
+	journal(w, r, "area.create", deleteStep(models.UndoDeleteArea, area.ID))
+	emit(models.ActionCreated, models.ItemTypeArea, area.ID, area.Name)
+	writeEntity(w, r, http.StatusCreated, area)
 }
 
 func updateArea(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "area.update", models.ItemTypeArea, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid area id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := guardArea(w, r, id); !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "area not found", func() (*models.Area, error) { return loadArea(id) }) {
+		return
+	}
+
 	var req models.UpdateAreaRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -101,6 +150,24 @@ func updateArea(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
+	auditDiff(rec, req, func() (*models.Area, error) { return database.GetArea(id) })
+	if dry {
+		area, ok := loadCurrent(w, "area not found", func() (*models.Area, error) { return loadArea(id) })
+		if !ok {
+			return
+		}
+		script, err := applescript.UpdateAreaScript(id, req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Name != nil {
+			area.Name = *req.Name
+		}
+		writeDryRun(w, rec, "area.update", area, nil, appleScriptPreview(script))
+		return
+	}
+	undo := revertAreaUpdate(id, req)
 	area, err := applescript.UpdateArea(id, req)
 	if err != nil {
 		if isNotFound(err) {
@@ -110,5 +177,123 @@ func updateArea(w http.ResponseWriter, r *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, area)
+	stampProjects(area.Projects)
+	journal(w, r, "area.update", undo)
+	emit(models.ActionUpdated, models.ItemTypeArea, id, area.Name)
+	writeEntity(w, r, http.StatusOK, area)
+}
+
+func deleteArea(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "area.delete", models.ItemTypeArea, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid area id")
+		return
+	}
+
+	req, err := deleteContainerParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, ok := guardArea(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "area not found", func() (*models.Area, error) { return loadArea(id) }) {
+		return
+	}
+
+	if req.Contents == models.ContentsArea && !guardTarget(w, a.allowsArea(req.AreaID)) {
+		return
+	}
+
+	area, err := applescript.GetAreaByID(id)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "area not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	if !checkTargetArea(w, req, id) {
+		return
+	}
+
+	tasks, err := applescript.GetAreaTasks(id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	result := models.DeleteResult{
+		DryRun:   req.DryRun,
+		Contents: req.Contents,
+		AreaID:   req.AreaID,
+		Tasks:    tasks,
+		Projects: area.Projects,
+	}
+	if req.DryRun {
+		script, err := applescript.DeleteAreaScript(id, req.Contents, req.AreaID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		result.Scripts = []models.ScriptPreview{appleScriptPreview(script)}
+		rec.MarkDryRun()
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	auditRemoved(rec, func() (*models.Area, error) { return database.GetArea(id) })
+	undo := revertAreaDelete(id, req.Contents)
+	if err := applescript.DeleteArea(id, req.Contents, req.AreaID); err != nil {
+		internalError(w, err)
+		return
+	}
+	journal(w, r, "area.delete", undo)
+	emit(models.ActionDeleted, models.ItemTypeArea, id, area.Name)
+	result.OK = true
+	writeJSON(w, http.StatusOK, result)
+}
+
+// deleteContainerParams parses the contents, area_id and dry_run query
+// parameters used when deleting a project or area. In dry-run mode every
+// deletion is a dry run.
+func deleteContainerParams(r *http.Request) (models.DeleteContainerRequest, error) {
+	q := r.URL.Query()
+	req := models.DeleteContainerRequest{
+		Contents: q.Get("contents"),
+		AreaID:   q.Get("area_id"),
+	}
+	dryRun, err := boolParam(r, "dry_run")
+	if err != nil {
+		return req, err
+	}
+	req.DryRun = dryRun || dryRunAll
+	return req, req.Validate()
+}
+
+// checkTargetArea verifies that the target area of a contents=area deletion
+// exists and is not the area being deleted. Writes an error response and
+// returns false otherwise.
+func checkTargetArea(w http.ResponseWriter, req models.DeleteContainerRequest, deletingAreaID string) bool {
+	if req.Contents != models.ContentsArea {
+		return true
+	}
+	if req.AreaID == deletingAreaID {
+		writeError(w, http.StatusBadRequest, "area_id must differ from the area being deleted")
+		return false
+	}
+	if _, err := applescript.GetAreaByID(req.AreaID); err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "target area not found")
+			return false
+		}
+		internalError(w, err)
+		return false
+	}
+	return true
 }
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// AuditHandler handles GET /audit.
+func AuditHandler(log *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+
+		f, err := models.ParseAuditFilter(r.URL.Query().Get)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		entries, err := log.Query(f)
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	}
+}
+
+// auditWrite describes the write being handled for the audit log and
+// returns its record for field changes. The record is nil (and ignores
+// changes) when the request is not audited.
+func auditWrite(r *http.Request, operation, itemType string, targets ...string) *audit.Record {
+	rec := audit.FromContext(r.Context())
+	rec.Set(operation, itemType, targets...)
+	return rec
+}
+
+// auditDiff records the fields set in req with their values in the item
+// returned by before. Nothing is loaded when the request is not audited.
+func auditDiff[T any](rec *audit.Record, req any, before func() (T, error)) {
+	if rec == nil {
+		return
+	}
+	v, err := before()
+	if err != nil {
+		rec.Diff(nil, req)
+		return
+	}
+	rec.Diff(v, req)
+}
+
+// auditRemoved records the fields of an item about to be deleted.
+func auditRemoved[T any](rec *audit.Record, before func() (T, error)) {
+	if rec == nil {
+		return
+	}
+	if v, err := before(); err == nil {
+		rec.Removed(v)
+	}
+}
+
+// auditStatus records a status change of a to-do or project.
+func auditStatus(rec *audit.Record, id, status string) {
+	if rec == nil {
+		return
+	}
+	before, _ := database.ItemStatus(id)
+	rec.Change("status", before, status)
+}
+
+// auditImported adds the IDs of items created or updated by an import.
+func auditImported(rec *audit.Record, items []models.ImportedItem) {
+	for _, item := range items {
+		rec.AddTarget(item.ID)
+	}
+}
+
+// statusOperation names the operation that sets a status.
+func statusOperation(status string) string {
+	switch status {
+	case "completed":
+		return "complete"
+	case "canceled":
+		return "cancel"
+	default:
+		return "reopen"
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/egorkaBurkenya/things3-api/backups"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// AdminRouter returns a handler for the /admin routes: POST /admin/backup
+// and GET /admin/backups, backed by m.
+func AdminRouter(m *backups.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/backup", "/admin/backup/":
+			if r.Method != http.MethodPost {
+				methodNotAllowed(w)
+				return
+			}
+			createBackup(w, r, m)
+		case "/admin/backups", "/admin/backups/":
+			if r.Method != http.MethodGet {
+				methodNotAllowed(w)
+				return
+			}
+			list, err := m.List()
+			if err != nil {
+				internalError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, list)
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	}
+}
+
+// createBackup writes a backup of the Things database. It does not change
+// Things, so it is never a dry run.
+func createBackup(w http.ResponseWriter, r *http.Request, m *backups.Manager) {
+	rec := auditWrite(r, "backup.create", "backup")
+
+	b, err := m.Create(models.BackupManual)
+	if err != nil {
+		if errors.Is(err, backups.ErrExists) {
+			writeError(w, http.StatusConflict, "a backup was already made this second; try again")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	rec.AddTarget(b.Name)
+	writeJSON(w, http.StatusCreated, b)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// dryRunAll turns every write into a dry run regardless of the dry_run
+// query parameter.
+var dryRunAll bool
+
+// SetDryRun sets whether every write is a dry run.
+func SetDryRun(enabled bool) {
+	dryRunAll = enabled
+}
+
+// dryRun reports whether the request is a dry run: dry_run=true was passed
+// or the server runs in dry-run mode. Writes 400 and returns ok=false if
+// dry_run is not a boolean.
+func dryRun(w http.ResponseWriter, r *http.Request) (dry, ok bool) {
+	v, err := boolParam(r, "dry_run")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false, false
+	}
+	return v || dryRunAll, true
+}
+
+// writeDryRun writes the preview of a write that was not made.
+func writeDryRun(w http.ResponseWriter, rec *audit.Record, operation string, result any, resolved map[string]string, scripts ...models.ScriptPreview) {
+	rec.MarkDryRun()
+	writeJSON(w, http.StatusOK, models.DryRunResult{
+		DryRun:    true,
+		Operation: operation,
+		Result:    result,
+		Resolved:  resolved,
+		Scripts:   scripts,
+	})
+}
+
+func appleScriptPreview(script string) models.ScriptPreview {
+	return models.ScriptPreview{Type: models.ScriptAppleScript, Script: script}
+}
+
+func urlPreview(u string) models.ScriptPreview {
+	return models.ScriptPreview{Type: models.ScriptURL, Script: u}
+}
+
+func sqlPreview(sql string) models.ScriptPreview {
+	return models.ScriptPreview{Type: models.ScriptSQL, Script: sql}
+}
+
+// resolveNames looks up the IDs of the project and area a write names, the
+// way Things will when it runs. Writes 400 for a name that matches nothing
+// and returns ok=false.
+func resolveNames(w http.ResponseWriter, project, area string) (map[string]string, bool) {
+	if project == "" && area == "" {
+		return nil, true
+	}
+	containers, err := database.Containers()
+	if err != nil {
+		internalError(w, err)
+		return nil, false
+	}
+
+	resolved := make(map[string]string)
+	for _, want := range []struct{ itemType, name string }{
+		{models.ItemTypeProject, project},
+		{models.ItemTypeArea, area},
+	} {
+		if want.name == "" {
+			continue
+		}
+		for _, c := range containers {
+			// AppleScript matches names with trailing spaces trimmed.
+			if c.Type == want.itemType && strings.TrimRight(c.Name, " ") == want.name {
+				resolved[want.itemType] = c.ID
+				break
+			}
+		}
+		if resolved[want.itemType] == "" {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s %q not found", want.itemType, want.name))
+			return nil, false
+		}
+	}
+	return resolved, true
+}
+
+// loadCurrent loads the item a dry run previews a change to, writing 404
+// with notFound or 500 if it cannot.
+func loadCurrent[T any](w http.ResponseWriter, notFound string, load func() (*T, error)) (*T, bool) {
+	v, err := load()
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, notFound)
+			return nil, false
+		}
+		internalError(w, err)
+		return nil, false
+	}
+	return v, true
+}
+
+// updatedTask returns task with the fields set in req applied.
+func updatedTask(task models.Task, req models.UpdateTaskRequest) models.Task {
+	if req.Title != nil {
+		task.Title = *req.Title
+	}
+	if req.Notes != nil {
+		task.Notes = *req.Notes
+	}
+	if req.Project != nil {
+		task.Project = *req.Project
+	}
+	if req.Area != nil {
+		task.Area = *req.Area
+	}
+	if req.Due != nil {
+		task.Due = *req.Due
+	}
+	if req.When != nil {
+		task.When = *req.When
+	}
+	if req.Tags != nil {
+		task.Tags = req.Tags
+	}
+	if req.Status != nil {
+		task.Status = *req.Status
+	}
+	return task
+}
+
+// updatedProject returns project with the fields set in req applied.
+func updatedProject(project models.Project, req models.UpdateProjectRequest) models.Project {
+	if req.Name != nil {
+		project.Name = *req.Name
+	}
+	if req.Area != nil {
+		project.Area = *req.Area
+	}
+	if req.Notes != nil {
+		project.Notes = *req.Notes
+	}
+	if req.Status != nil {
+		project.Status = *req.Status
+	}
+	return project
+}
+
+// updatedChecklistItem returns item with the fields set in req applied.
+func updatedChecklistItem(item models.ChecklistItem, req models.UpdateChecklistItemRequest) models.ChecklistItem {
+	if req.Title != nil {
+		item.Title = *req.Title
+	}
+	if req.Completed != nil {
+		item.Completed = *req.Completed
+	}
+	return item
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
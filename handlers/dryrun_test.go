@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func ptr(s string) *string { return &s }
+
+func TestUpdatedTask(t *testing.T) {
+	task := models.Task{ID: "TaskShed00000000000001", Title: "Paint shed", Notes: "Green", Status: "open", Tags: []string{"home"}}
+	tests := []struct {
+		name string
+		req  models.UpdateTaskRequest
+		want models.Task
+	}{
+		{
+			name: "no changes",
+			want: task,
+		},
+		{
+			name: "status",
+			req:  models.UpdateTaskRequest{Status: ptr("completed")},
+			want: models.Task{ID: task.ID, Title: task.Title, Notes: task.Notes, Status: "completed", Tags: task.Tags},
+		},
+		{
+			name: "fields",
+			req:  models.UpdateTaskRequest{Title: ptr("Paint fence"), Notes: ptr(""), Tags: []string{}},
+			want: models.Task{ID: task.ID, Title: "Paint fence", Status: "open", Tags: []string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updatedTask(task, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updatedTask = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdatedProject(t *testing.T) {
+	project := models.Project{ID: "ProjectGarden000000001", Name: "Garden", Area: "Home", Status: "open", TaskCount: 2}
+	tests := []struct {
+		name string
+		req  models.UpdateProjectRequest
+		want models.Project
+	}{
+		{
+			name: "no changes",
+			want: project,
+		},
+		{
+			name: "status",
+			req:  models.UpdateProjectRequest{Status: ptr("completed")},
+			want: models.Project{ID: project.ID, Name: "Garden", Area: "Home", Status: "completed", TaskCount: 2},
+		},
+		{
+			name: "fields",
+			req:  models.UpdateProjectRequest{Name: ptr("Vegetable garden"), Area: ptr(""), Notes: ptr("Raised beds")},
+			want: models.Project{ID: project.ID, Name: "Vegetable garden", Notes: "Raised beds", Status: "open", TaskCount: 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updatedProject(project, tt.req); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("updatedProject = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
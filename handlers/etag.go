@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// entityTag returns a strong ETag for the JSON representation of v. The
+// representation includes modified_at, so any change in Things changes it.
+func entityTag(v any) string {
+	data, _ := json.Marshal(v)
+	sum := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// writeEntity writes v as JSON with an ETag header. GET requests whose
+// If-None-Match matches get 304 Not Modified with no body.
+func writeEntity(w http.ResponseWriter, r *http.Request, status int, v any) {
+	etag := entityTag(v)
+	w.Header().Set("ETag", etag)
+	if r.Method == http.MethodGet && status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), etag, false) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, status, v)
+}
+
+// checkIfMatch enforces an If-Match precondition before a write. current
+// loads the entity as GET would return it. Returns false after writing the
+// response if the request must not proceed: 412 if the ETag does not match,
+// or 404/500 if the entity cannot be loaded. Requests without If-Match
+// always proceed.
+func checkIfMatch[T any](w http.ResponseWriter, r *http.Request, notFound string, current func() (T, error)) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	v, err := current()
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, notFound)
+			return false
+		}
+		internalError(w, err)
+		return false
+	}
+
+	etag := entityTag(v)
+	if !etagMatches(header, etag, true) {
+		w.Header().Set("ETag", etag)
+		writeError(w, http.StatusPreconditionFailed, "resource has been modified")
+		return false
+	}
+	return true
+}
+
+// etagMatches reports whether a comma-separated If-Match / If-None-Match
+// header value matches etag. "*" matches anything. Strong comparison
+// (for If-Match) rejects weak validators.
+func etagMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// loadTask returns a task as GET /tasks/{id} does.
+func loadTask(id string) (*models.Task, error) {
+	task, err := applescript.GetTaskByID(id)
+	if err != nil {
+		return nil, err
+	}
+	stampTask(task)
+	return task, nil
+}
+
+// loadProject returns a project as GET /projects/{id} does.
+func loadProject(id string) (*models.Project, error) {
+	project, err := applescript.GetProjectByID(id)
+	if err != nil {
+		return nil, err
+	}
+	stampProject(project)
+	return project, nil
+}
+
+// loadArea returns an area as GET /areas/{id} does.
+func loadArea(id string) (*models.Area, error) {
+	area, err := applescript.GetAreaByID(id)
+	if err != nil {
+		return nil, err
+	}
+	stampProjects(area.Projects)
+	return area, nil
+}
+
+// loadChecklistItem returns a single checklist item of a task.
+func loadChecklistItem(taskID, itemID string) (*models.ChecklistItem, error) {
+	items, err := database.GetChecklistItems(taskID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		if items[i].ID == itemID {
+			return &items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("checklist item not found")
+}
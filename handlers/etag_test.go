@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		header string
+		strong bool
+		want   bool
+	}{
+		{header: `"abc"`, want: true},
+		{header: `"abc"`, strong: true, want: true},
+		{header: `"xyz"`, want: false},
+		{header: `abc`, want: false},
+		{header: ``, want: false},
+		{header: `*`, want: true},
+		{header: `*`, strong: true, want: true},
+		{header: `W/"abc"`, want: true},
+		{header: `W/"abc"`, strong: true, want: false},
+		{header: `"xyz", "abc"`, want: true},
+		{header: `"xyz",W/"abc"`, want: true},
+		{header: `"xyz", W/"abc"`, strong: true, want: false},
+		{header: `W/"abc", "abc"`, strong: true, want: true},
+		{header: `"xyz", "uvw"`, want: false},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, etag, tt.strong); got != tt.want {
+			t.Errorf("etagMatches(%q, strong=%v) = %v, want %v", tt.header, tt.strong, got, tt.want)
+		}
+	}
+}
+
+func TestWriteEntityNotModified(t *testing.T) {
+	v := map[string]string{"id": "TaskSeeds0000000000001"}
+	etag := entityTag(v)
+	tests := []struct {
+		method      string
+		status      int
+		ifNoneMatch string
+		want        int
+	}{
+		{http.MethodGet, http.StatusOK, etag, http.StatusNotModified},
+		{http.MethodGet, http.StatusOK, "W/" + etag, http.StatusNotModified},
+		{http.MethodGet, http.StatusOK, `"stale"`, http.StatusOK},
+		{http.MethodGet, http.StatusOK, "", http.StatusOK},
+		{http.MethodPost, http.StatusCreated, etag, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/tasks/TaskSeeds0000000000001", nil)
+		if tt.ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		writeEntity(w, r, tt.status, v)
+		if w.Code != tt.want {
+			t.Errorf("%s with If-None-Match %q: status = %d, want %d", tt.method, tt.ifNoneMatch, w.Code, tt.want)
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Errorf("%s with If-None-Match %q: ETag = %q, want %q", tt.method, tt.ifNoneMatch, w.Header().Get("ETag"), etag)
+		}
+		if w.Code == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("304 with body %q", w.Body)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/events"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// eventsHeartbeat is how often a comment line is sent to keep idle
+// connections open through proxies.
+const eventsHeartbeat = 15 * time.Second
+
+// eventFeed receives events for writes made through the API. It is nil
+// until SetEventFeed is called.
+var eventFeed *events.Feed
+
+// SetEventFeed sets the feed that write handlers publish their changes to.
+func SetEventFeed(feed *events.Feed) {
+	eventFeed = feed
+}
+
+// emit publishes a change made through the API to the event feed.
+func emit(action, itemType, id, title string) {
+	if eventFeed == nil || id == "" {
+		return
+	}
+	eventFeed.Emit(models.Event{Action: action, Type: itemType, ItemID: id, Title: title})
+}
+
+// emitChecklist publishes a checklist item change made through the API.
+func emitChecklist(action, taskID, itemID, title string) {
+	if eventFeed == nil || itemID == "" {
+		return
+	}
+	eventFeed.Emit(models.Event{Action: action, Type: models.ItemTypeChecklistItem, ItemID: itemID, Title: title, TaskID: taskID})
+}
+
+// emitImported publishes events for to-dos and projects created or updated
+// by a Things JSON import.
+func emitImported(items []models.ImportedItem) {
+	for _, item := range items {
+		itemType := models.ItemTypeTask
+		switch item.Type {
+		case thingsurl.TypeProject:
+			itemType = models.ItemTypeProject
+		case thingsurl.TypeHeading:
+			continue
+		}
+		action := models.ActionCreated
+		if item.Operation == thingsurl.OperationUpdate {
+			action = models.ActionUpdated
+		}
+		emit(action, itemType, item.ID, item.Title)
+	}
+}
+
+// statusAction returns the event action for a write that may set status.
+func statusAction(status *string) string {
+	if status != nil && *status == "completed" {
+		return models.ActionCompleted
+	}
+	return models.ActionUpdated
+}
+
+// EventsHandler returns a handler for GET /events, streaming the change feed
+// as Server-Sent Events. Clients resume with the Last-Event-ID header (or
+// the last_event_id query parameter); if the ID can no longer be resumed a
+// "reset" event is sent first and the client should refetch its data.
+func EventsHandler(feed *events.Feed) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("last_event_id")
+		}
+
+		rc := http.NewResponseController(w)
+		backlog, resumed, ch, cancel := feed.Subscribe(lastID)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		if !resumed {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		for _, e := range backlog {
+			writeEvent(w, e)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(eventsHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-ch:
+				if !ok {
+					// Too far behind; the client reconnects and resumes.
+					return
+				}
+				writeEvent(w, e)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes e in SSE format, using its action as the event name.
+func writeEvent(w http.ResponseWriter, e models.Event) {
+	data, _ := json.Marshal(e)
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Action, data)
+}
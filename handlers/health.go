@@ -5,17 +5,32 @@ import (
 	"net/http"
 
 	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/database"
 )
 
+// readOnly is reported by /health; the ReadOnly middleware enforces it.
+var readOnly bool
+
+// SetReadOnly sets whether the server is in read-only mode.
+func SetReadOnly(enabled bool) {
+	readOnly = enabled
+}
+
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	status := "running"
 	if !applescript.IsThings3Running() {
 		status = "not_running"
 	}
+	mode := "read-write"
+	if readOnly {
+		mode = "read-only"
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "ok",
-		"things3": status,
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":   "ok",
+		"things3":  status,
+		"mode":     mode,
+		"database": database.Schema(),
 	})
 }
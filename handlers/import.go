@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// urlToken is the Things URL scheme auth token (THINGS_URL_TOKEN), needed
+// for commands that change existing items.
+var urlToken string
+
+// SetURLToken sets the Things URL scheme auth token.
+func SetURLToken(token string) {
+	urlToken = token
+}
+
+// ImportRouter handles all /import routes.
+func ImportRouter(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/import/things-json", "/import/things-json/":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		importThingsJSON(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func importThingsJSON(w http.ResponseWriter, r *http.Request) {
+	rec := auditWrite(r, "import.things_json", "")
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.ImportThingsJSONRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.HasUpdates() && urlToken == "" {
+		writeError(w, http.StatusBadRequest, "update operations require THINGS_URL_TOKEN to be configured")
+		return
+	}
+
+	if dry {
+		previewImport(w, rec, "import.things_json", nil, req)
+		return
+	}
+
+	updates := revertImportUpdates(req)
+	items, err := database.ImportThingsJSON(req, urlToken)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	auditImported(rec, items)
+	journal(w, r, "import.things_json", revertImported(items, updates))
+	emitImported(items)
+	writeJSON(w, http.StatusCreated, items)
+}
+
+// previewImport writes the dry run of a things:///json import of items.
+// The result is the items the import would report, unless result is given.
+func previewImport(w http.ResponseWriter, rec *audit.Record, operation string, result any, items []thingsurl.Item) {
+	u, planned, err := database.PlanImport(items)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if result == nil {
+		result = planned
+	}
+	writeDryRun(w, rec, operation, result, nil, urlPreview(u))
+}
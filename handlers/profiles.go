@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// ProfilesRouter returns a handler for the /profiles routes, which read the
+// databases in dbs by name. Profiles are never written to:
+//
+//	GET /profiles
+//	GET /profiles/{name}/tasks
+//	GET /profiles/{name}/tasks/{id}
+//	GET /profiles/{name}/projects
+//	GET /profiles/{name}/projects/{id}
+//	GET /profiles/{name}/areas
+//	GET /profiles/{name}/areas/{id}
+//	GET /profiles/{name}/search
+func ProfilesRouter(dbs map[string]*database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if path == "/profiles" {
+			listProfiles(w, dbs)
+			return
+		}
+
+		name := extractID(path, "/profiles/")
+		db, ok := dbs[name]
+		if !ok {
+			writeError(w, http.StatusNotFound, "profile not found")
+			return
+		}
+
+		rest := pathSuffix(path, "/profiles/")
+		switch {
+		case rest == "/tasks":
+			getProfileTasks(w, r, db)
+		case rest == "/projects":
+			includeClosed, ok := includeClosedParam(w, r)
+			if !ok {
+				return
+			}
+			projects, err := db.Projects(includeClosed)
+			if err != nil {
+				internalError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, projects)
+		case rest == "/areas":
+			areas, err := db.Areas()
+			if err != nil {
+				internalError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, areas)
+		case rest == "/search":
+			searchWith(w, r, db.SearchDocuments)
+		case strings.HasPrefix(rest, "/tasks/"):
+			getProfileItem(w, extractID(rest, "/tasks/"), "task", db.Task)
+		case strings.HasPrefix(rest, "/projects/"):
+			getProfileItem(w, extractID(rest, "/projects/"), "project", db.Project)
+		case strings.HasPrefix(rest, "/areas/"):
+			getProfileItem(w, extractID(rest, "/areas/"), "area", db.Area)
+		default:
+			writeError(w, http.StatusNotFound, "not found")
+		}
+	}
+}
+
+func listProfiles(w http.ResponseWriter, dbs map[string]*database.DB) {
+	list := make([]models.DatabaseProfile, 0, len(dbs))
+	for name, db := range dbs {
+		list = append(list, models.DatabaseProfile{Name: name, Database: db.Schema()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	writeJSON(w, http.StatusOK, list)
+}
+
+// getProfileTasks lists the to-dos of a profile, optionally only those in
+// the project or area with the given name or with the given tag.
+func getProfileTasks(w http.ResponseWriter, r *http.Request, db *database.DB) {
+	project := r.URL.Query().Get("project")
+	area := r.URL.Query().Get("area")
+	tag := r.URL.Query().Get("tag")
+	includeClosed, ok := includeClosedParam(w, r)
+	if !ok {
+		return
+	}
+
+	tasks, err := db.Tasks(includeClosed)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	filtered := make([]models.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if (project == "" || t.Project == project) &&
+			(area == "" || t.Area == area) &&
+			(tag == "" || slices.Contains(t.Tags, tag)) {
+			filtered = append(filtered, t)
+		}
+	}
+	writeJSON(w, http.StatusOK, filtered)
+}
+
+// getProfileItem writes the item load returns for id, or 404 if there is
+// none.
+func getProfileItem[T any](w http.ResponseWriter, id, itemType string, load func(string) (*T, error)) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid "+itemType+" id")
+		return
+	}
+	item, ok := loadCurrent(w, itemType+" not found", func() (*T, error) { return load(id) })
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, item)
+}
+
+// includeClosedParam parses include_closed, writing 400 and returning
+// ok=false if it is not a boolean.
+func includeClosedParam(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	v, err := boolParam(r, "include_closed")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false, false
+	}
+	return v, true
+}
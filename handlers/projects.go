@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/database"
 	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
 )
 
 // ProjectsRouter handles all /projects routes.
@@ -28,33 +32,51 @@ func ProjectsRouter(w http.ResponseWriter, r *http.Request) {
 
 		switch {
 		case suffix == "/complete" && r.Method == http.MethodPost:
-			completeProject(w, r, id)
+			setProjectStatus(w, r, id, "completed")
+		case suffix == "/cancel" && r.Method == http.MethodPost:
+			setProjectStatus(w, r, id, "canceled")
+		case suffix == "/reopen" && r.Method == http.MethodPost:
+			setProjectStatus(w, r, id, "open")
+		case suffix == "/duplicate" && r.Method == http.MethodPost:
+			duplicateProject(w, r, id)
 		case suffix == "" && r.Method == http.MethodGet:
 			getProjectByID(w, r, id)
 		case suffix == "" && r.Method == http.MethodPatch:
 			updateProject(w, r, id)
+		case suffix == "" && r.Method == http.MethodDelete:
+			deleteProject(w, r, id)
 		default:
 			writeError(w, http.StatusNotFound, "not found")
 		}
 	}
 }
 
-func getAllProjects(w http.ResponseWriter, _ *http.Request) {
+func getAllProjects(w http.ResponseWriter, r *http.Request) {
 	projects, err := applescript.GetAllProjects()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, projects)
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	projects = a.filterProjects(projects)
+	stampProjects(projects)
+	writeEntity(w, r, http.StatusOK, projects)
 }
 
-func getProjectByID(w http.ResponseWriter, _ *http.Request, id string) {
+func getProjectByID(w http.ResponseWriter, r *http.Request, id string) {
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid project id")
 		return
 	}
 
-	project, err := applescript.GetProjectByID(id)
+	if _, ok := guardProject(w, r, id); !ok {
+		return
+	}
+
+	project, err := loadProject(id)
 	if err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "project not found")
@@ -63,10 +85,17 @@ func getProjectByID(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, project)
+	writeEntity(w, r, http.StatusOK, project)
 }
 
 func createProject(w http.ResponseWriter, r *http.Request) {
+	rec := auditWrite(r, "project.create", models.ItemTypeProject)
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -78,20 +107,55 @@ func createProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a, ok := requestAccess(w, r)
+	if !ok || !guardAreaName(w, a, req.Area) {
+		return
+	}
+
+	rec.Diff(nil, req)
+	if dry {
+		resolved, ok := resolveNames(w, "", req.Area)
+		if !ok {
+			return
+		}
+		project := models.Project{Name: req.Name, Area: req.Area, Notes: req.Notes}
+		writeDryRun(w, rec, "project.create", project, resolved, appleScriptPreview(applescript.CreateProjectScript(req)))
+		return
+	}
 	project, err := applescript.CreateProject(req)
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, project)
+	stampProject(project)
+	rec.AddTarget(project.ID)
+	journal(w, r, "project.create", deleteStep(models.UndoDeleteProject, project.ID))
+	emit(models.ActionCreated, models.ItemTypeProject, project.ID, project.Name)
+	writeEntity(w, r, http.StatusCreated, project)
 }
 
 func updateProject(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "project.update", models.ItemTypeProject, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid project id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	a, ok := guardProject(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "project not found", func() (*models.Project, error) { return loadProject(id) }) {
+		return
+	}
+
 	var req models.UpdateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -103,7 +167,32 @@ func updateProject(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
-	project, err := applescript.UpdateProject(id, req)
+	if req.Area != nil && !a.allowsProjectIn(id, "") && !guardAreaName(w, a, *req.Area) {
+		return
+	}
+
+	cascade, err := boolParam(r, "cascade")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	auditDiff(rec, req, func() (*models.Project, error) { return database.GetProject(id) })
+	if dry {
+		previewProjectUpdate(w, rec, id, req, cascade)
+		return
+	}
+	// Name, notes and area are applied first and the status last, so that
+	// if the status change fails, the changes made so far are journaled.
+	fields := req
+	fields.Status = nil
+	undo := revertProjectUpdate(id, fields, cascade)
+	var statusUndo []models.UndoStep
+	if req.Status != nil {
+		statusUndo = revertProjectStatus(id, *req.Status, cascade)
+	}
+
+	project, err := applescript.UpdateProject(id, fields)
 	if err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "project not found")
@@ -112,16 +201,74 @@ func updateProject(w http.ResponseWriter, r *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, project)
+	if req.Status != nil {
+		auditStatus(rec, id, *req.Status)
+		if err := applescript.SetProjectStatus(id, *req.Status, cascade); err != nil {
+			journal(w, r, "project.update", undo)
+			if isNotFound(err) {
+				writeError(w, http.StatusNotFound, "project not found")
+				return
+			}
+			internalError(w, err)
+			return
+		}
+		if statusUndo == nil {
+			undo = nil // the write cannot be fully undone
+		} else {
+			undo = append(undo, statusUndo...)
+		}
+		if project, err = applescript.GetProjectByID(id); err != nil {
+			internalError(w, err)
+			return
+		}
+	}
+	stampProject(project)
+	journal(w, r, "project.update", undo)
+	emit(statusAction(req.Status), models.ItemTypeProject, id, project.Name)
+	writeEntity(w, r, http.StatusOK, project)
 }
 
-func completeProject(w http.ResponseWriter, _ *http.Request, id string) {
+// setProjectStatus handles /complete, /cancel and /reopen. The cascade query
+// parameter applies the change to the project's to-dos as well.
+func setProjectStatus(w http.ResponseWriter, r *http.Request, id, status string) {
+	rec := auditWrite(r, "project."+statusOperation(status), models.ItemTypeProject, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid project id")
 		return
 	}
 
-	if err := applescript.CompleteProject(id); err != nil {
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := guardProject(w, r, id); !ok {
+		return
+	}
+
+	cascade, err := boolParam(r, "cascade")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	auditStatus(rec, id, status)
+	if dry {
+		project, ok := loadCurrent(w, "project not found", func() (*models.Project, error) { return loadProject(id) })
+		if !ok {
+			return
+		}
+		script, err := applescript.ProjectStatusScript(id, status, cascade)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeDryRun(w, rec, "project."+statusOperation(status), project, nil, appleScriptPreview(script))
+		return
+	}
+	undo := revertProjectStatus(id, status, cascade)
+	if err := applescript.SetProjectStatus(id, status, cascade); err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "project not found")
 			return
@@ -129,5 +276,186 @@ func completeProject(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
+	journal(w, r, "project."+statusOperation(status), undo)
+	emit(statusAction(&status), models.ItemTypeProject, id, "")
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
+
+func deleteProject(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "project.delete", models.ItemTypeProject, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid project id")
+		return
+	}
+
+	req, err := deleteContainerParams(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	a, ok := guardProject(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "project not found", func() (*models.Project, error) { return loadProject(id) }) {
+		return
+	}
+
+	if req.Contents == models.ContentsArea && !guardTarget(w, a.allowsArea(req.AreaID)) {
+		return
+	}
+
+	if _, err := applescript.GetProjectByID(id); err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	if !checkTargetArea(w, req, "") {
+		return
+	}
+
+	tasks, err := applescript.GetProjectTasks(id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	result := models.DeleteResult{
+		DryRun:   req.DryRun,
+		Contents: req.Contents,
+		AreaID:   req.AreaID,
+		Tasks:    tasks,
+	}
+	if req.DryRun {
+		script, err := applescript.DeleteProjectScript(id, req.Contents, req.AreaID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		result.Scripts = []models.ScriptPreview{appleScriptPreview(script)}
+		rec.MarkDryRun()
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	auditRemoved(rec, func() (*models.Project, error) { return database.GetProject(id) })
+	undo := revertProjectDelete(id, req.Contents)
+	if err := applescript.DeleteProject(id, req.Contents, req.AreaID); err != nil {
+		internalError(w, err)
+		return
+	}
+	journal(w, r, "project.delete", undo)
+	emit(models.ActionDeleted, models.ItemTypeProject, id, "")
+	result.OK = true
+	writeJSON(w, http.StatusOK, result)
+}
+
+func duplicateProject(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "project.duplicate", models.ItemTypeProject, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid project id")
+		return
+	}
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	a, ok := guardProject(w, r, id)
+	if !ok {
+		return
+	}
+
+	// The body is optional; an empty body duplicates with default options.
+	var req models.DuplicateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if a != nil {
+		areaID := req.AreaID
+		if areaID == "" {
+			areaID = a.projectArea[id]
+		}
+		if !guardTarget(w, areaID != "" && a.allowsArea(areaID)) {
+			return
+		}
+	}
+
+	if dry {
+		item, err := database.DuplicateProjectItem(id, req)
+		if err != nil {
+			if isNotFound(err) {
+				writeError(w, http.StatusNotFound, "project not found")
+				return
+			}
+			internalError(w, err)
+			return
+		}
+		previewImport(w, rec, "project.duplicate", item, []thingsurl.Item{item})
+		return
+	}
+
+	newID, err := database.DuplicateProject(id, req)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "project not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+
+	project, err := applescript.GetProjectByID(newID)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	stampProject(project)
+	rec.AddTarget(project.ID)
+	journal(w, r, "project.duplicate", deleteStep(models.UndoDeleteProject, project.ID))
+	emit(models.ActionCreated, models.ItemTypeProject, project.ID, project.Name)
+	writeEntity(w, r, http.StatusCreated, project)
+}
+
+// previewProjectUpdate writes the dry run of PATCH /projects/{id}: the update
+// script, followed by the status script if the status changes.
+func previewProjectUpdate(w http.ResponseWriter, rec *audit.Record, id string, req models.UpdateProjectRequest, cascade bool) {
+	project, ok := loadCurrent(w, "project not found", func() (*models.Project, error) { return loadProject(id) })
+	if !ok {
+		return
+	}
+	resolved, ok := resolveNames(w, "", deref(req.Area))
+	if !ok {
+		return
+	}
+
+	script, err := applescript.UpdateProjectScript(id, req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scripts := []models.ScriptPreview{appleScriptPreview(script)}
+	if req.Status != nil {
+		script, err := applescript.ProjectStatusScript(id, *req.Status, cascade)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		scripts = append(scripts, appleScriptPreview(script))
+	}
+	writeDryRun(w, rec, "project.update", updatedProject(*project, req), resolved, scripts...)
+}
@@ -0,0 +1,477 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// The revert* functions read the state a write is about to change and
+// return the undo steps that restore it. They run before the write; if the
+// state cannot be read they return nil and the write is not journaled.
+
+// noUndo logs why a write could not be made undoable.
+func noUndo(id string, err error) []models.UndoStep {
+	slog.Warn("cannot record undo state", "id", id, "error", err)
+	return nil
+}
+
+// deleteStep reverts the creation of an item.
+func deleteStep(action, id string) []models.UndoStep {
+	if id == "" {
+		return nil
+	}
+	return []models.UndoStep{{Action: action, ID: id}}
+}
+
+// revertTaskUpdate restores the fields of to-do id that req sets.
+func revertTaskUpdate(id string, req models.UpdateTaskRequest) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	before, err := database.GetTask(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+
+	var revert models.UpdateTaskRequest
+	changed := false
+	if req.Title != nil {
+		revert.Title, changed = &before.Title, true
+	}
+	if req.Notes != nil {
+		revert.Notes, changed = &before.Notes, true
+	}
+	if req.Due != nil {
+		revert.Due, changed = &before.Due, true
+	}
+	if req.When != nil {
+		revert.When, changed = &before.When, true
+	}
+	if req.Tags != nil {
+		revert.Tags, changed = nonNil(before.Tags), true
+	}
+	if req.Status != nil {
+		revert.Status, changed = &before.Status, true
+	}
+
+	step := models.UndoStep{Action: models.UndoUpdateTask, ID: id}
+	if req.Project != nil || req.Area != nil {
+		projectID, areaID, err := database.Placement(id)
+		if err != nil {
+			return noUndo(id, err)
+		}
+		step = models.UndoStep{Action: models.UndoPlaceTask, ID: id, ProjectID: projectID, AreaID: areaID}
+	} else if !changed {
+		return nil
+	}
+	if changed {
+		step.Task = &revert
+	}
+	return []models.UndoStep{step}
+}
+
+// revertTaskStatus restores the status of to-do id.
+func revertTaskStatus(id string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	status, err := database.ItemStatus(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	return []models.UndoStep{{Action: models.UndoUpdateTask, ID: id, Task: &models.UpdateTaskRequest{Status: &status}}}
+}
+
+// revertTaskDelete takes to-do id back out of the Trash into its project,
+// area or the Inbox, with its schedule and status.
+func revertTaskDelete(id string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	task, err := database.GetTask(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	step, err := restoreTaskStep(task, true)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	return []models.UndoStep{step}
+}
+
+// restoreTaskStep returns a step that puts task back where it is now with
+// its current fields.
+func restoreTaskStep(task *models.Task, restore bool) (models.UndoStep, error) {
+	projectID, areaID, err := database.Placement(task.ID)
+	if err != nil {
+		return models.UndoStep{}, err
+	}
+	return models.UndoStep{
+		Action:    models.UndoPlaceTask,
+		ID:        task.ID,
+		ProjectID: projectID,
+		AreaID:    areaID,
+		Restore:   restore,
+		Task: &models.UpdateTaskRequest{
+			Title:  &task.Title,
+			Notes:  &task.Notes,
+			Due:    &task.Due,
+			When:   &task.When,
+			Tags:   nonNil(task.Tags),
+			Status: &task.Status,
+		},
+	}, nil
+}
+
+// revertTaskMove moves to-do id back next to its current neighbor.
+func revertTaskMove(id, list string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	before, after, err := database.Neighbors(id, list)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	move := &models.MoveTaskRequest{Before: after, List: list}
+	if after == "" {
+		if before == "" {
+			return nil
+		}
+		move = &models.MoveTaskRequest{After: before, List: list}
+	}
+	return []models.UndoStep{{Action: models.UndoMoveTask, ID: id, Move: move}}
+}
+
+// revertChecklistUpdate restores the fields of a checklist item that req sets.
+func revertChecklistUpdate(taskID, itemID string, req models.UpdateChecklistItemRequest) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	before, err := loadChecklistItem(taskID, itemID)
+	if err != nil {
+		return noUndo(itemID, err)
+	}
+	var revert models.UpdateChecklistItemRequest
+	if req.Title != nil {
+		revert.Title = &before.Title
+	}
+	if req.Completed != nil {
+		revert.Completed = &before.Completed
+	}
+	return []models.UndoStep{{Action: models.UndoUpdateChecklistItem, TaskID: taskID, ID: itemID, ChecklistItem: &revert}}
+}
+
+// revertChecklistDelete recreates a checklist item. The new item gets a new
+// ID and is added at the end of the checklist.
+func revertChecklistDelete(taskID, itemID string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	item, err := loadChecklistItem(taskID, itemID)
+	if err != nil {
+		return noUndo(itemID, err)
+	}
+	return []models.UndoStep{{Action: models.UndoCreateChecklistItem, TaskID: taskID, ID: itemID, Title: item.Title, Completed: item.Completed}}
+}
+
+// revertProjectUpdate restores the fields of project id that req sets,
+// including the status of its to-dos when cascade applies a status change
+// to them.
+func revertProjectUpdate(id string, req models.UpdateProjectRequest, cascade bool) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	before, err := database.GetProject(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+
+	var steps []models.UndoStep
+	if req.Area != nil {
+		_, areaID, err := database.Placement(id)
+		if err != nil {
+			return noUndo(id, err)
+		}
+		steps = append(steps, models.UndoStep{Action: models.UndoPlaceProject, ID: id, AreaID: areaID})
+	}
+	if req.Name != nil || req.Notes != nil {
+		var revert models.UpdateProjectRequest
+		if req.Name != nil {
+			revert.Name = &before.Name
+		}
+		if req.Notes != nil {
+			revert.Notes = &before.Notes
+		}
+		steps = append(steps, models.UndoStep{Action: models.UndoUpdateProject, ID: id, Project: &revert})
+	}
+	if req.Status != nil {
+		statusSteps := revertProjectStatus(id, *req.Status, cascade)
+		if statusSteps == nil {
+			return nil
+		}
+		steps = append(steps, statusSteps...)
+	}
+	return steps
+}
+
+// revertProjectStatus restores the status of project id, and with cascade
+// the status of the to-dos that SetProjectStatus will change.
+func revertProjectStatus(id, status string, cascade bool) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	before, err := database.ItemStatus(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	steps := []models.UndoStep{{Action: models.UndoUpdateProject, ID: id, Project: &models.UpdateProjectRequest{Status: &before}}}
+	if !cascade {
+		return steps
+	}
+
+	// Closing cascades to open to-dos; reopening to to-dos that share the
+	// project's previous status.
+	affected, restoreTo := "open", "open"
+	if status == "open" {
+		if before == "open" {
+			return steps
+		}
+		affected, restoreTo = before, before
+	}
+	tasks, err := database.ContainedTasks(id, affected)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	for _, taskID := range tasks {
+		s := restoreTo
+		steps = append(steps, models.UndoStep{Action: models.UndoUpdateTask, ID: taskID, Task: &models.UpdateTaskRequest{Status: &s}})
+	}
+	return steps
+}
+
+// revertProjectDelete takes project id back out of the Trash into its area
+// and moves its to-dos back into it (out of the Trash too if contents is
+// trash). To-dos under headings come back without their heading.
+func revertProjectDelete(id, contents string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	_, areaID, err := database.Placement(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	tasks, err := database.ContainedTasks(id, "")
+	if err != nil {
+		return noUndo(id, err)
+	}
+	steps := []models.UndoStep{{Action: models.UndoPlaceProject, ID: id, AreaID: areaID, Restore: true}}
+	for _, taskID := range tasks {
+		steps = append(steps, models.UndoStep{
+			Action:    models.UndoPlaceTask,
+			ID:        taskID,
+			ProjectID: id,
+			Restore:   contents == models.ContentsTrash,
+		})
+	}
+	return steps
+}
+
+// revertAreaUpdate restores the name of area id.
+func revertAreaUpdate(id string, req models.UpdateAreaRequest) []models.UndoStep {
+	if journalStore == nil || req.Name == nil {
+		return nil
+	}
+	before, err := database.GetArea(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	return []models.UndoStep{{Action: models.UndoUpdateArea, ID: id, Area: &models.UpdateAreaRequest{Name: &before.Name}}}
+}
+
+// revertAreaDelete recreates area id and moves its projects and to-dos
+// into it. Things deletes areas permanently, so the new area gets a new ID.
+func revertAreaDelete(id, contents string) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	area, err := database.GetArea(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	projects, err := database.AreaProjects(id)
+	if err != nil {
+		return noUndo(id, err)
+	}
+	tasks, err := database.ContainedTasks(id, "")
+	if err != nil {
+		return noUndo(id, err)
+	}
+	return []models.UndoStep{{
+		Action:   models.UndoCreateArea,
+		ID:       id,
+		Title:    area.Name,
+		Projects: projects,
+		Tasks:    tasks,
+		Restore:  contents == models.ContentsTrash,
+	}}
+}
+
+// revertImportUpdates restores the to-dos and projects that an import
+// updates. Created items are reverted separately with revertImported.
+func revertImportUpdates(items []thingsurl.Item) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	var steps []models.UndoStep
+	for _, item := range items {
+		if item.Operation != thingsurl.OperationUpdate {
+			continue
+		}
+		switch item.Type {
+		case thingsurl.TypeToDo:
+			task, err := database.GetTask(item.ID)
+			if err != nil {
+				return noUndo(item.ID, err)
+			}
+			step, err := restoreTaskStep(task, false)
+			if err != nil {
+				return noUndo(item.ID, err)
+			}
+			steps = append(steps, step)
+		case thingsurl.TypeProject:
+			project, err := database.GetProject(item.ID)
+			if err != nil {
+				return noUndo(item.ID, err)
+			}
+			status, err := database.ItemStatus(item.ID)
+			if err != nil {
+				return noUndo(item.ID, err)
+			}
+			_, areaID, err := database.Placement(item.ID)
+			if err != nil {
+				return noUndo(item.ID, err)
+			}
+			steps = append(steps,
+				models.UndoStep{Action: models.UndoPlaceProject, ID: item.ID, AreaID: areaID},
+				models.UndoStep{Action: models.UndoUpdateProject, ID: item.ID, Project: &models.UpdateProjectRequest{
+					Name:   &project.Name,
+					Notes:  &project.Notes,
+					Status: &status,
+				}},
+			)
+		}
+	}
+	return steps
+}
+
+// revertImported deletes the to-dos and projects an import created, to-dos
+// first. Updated items are left to the steps from revertImportUpdates.
+func revertImported(imported []models.ImportedItem, updates []models.UndoStep) []models.UndoStep {
+	if journalStore == nil {
+		return nil
+	}
+	var tasks, projects []models.UndoStep
+	for _, item := range imported {
+		if item.Operation != thingsurl.OperationCreate || item.ID == "" {
+			continue
+		}
+		switch item.Type {
+		case thingsurl.TypeToDo:
+			tasks = append(tasks, models.UndoStep{Action: models.UndoDeleteTask, ID: item.ID})
+		case thingsurl.TypeProject:
+			projects = append(projects, models.UndoStep{Action: models.UndoDeleteProject, ID: item.ID})
+		}
+	}
+	return append(append(tasks, projects...), updates...)
+}
+
+// runUndoStep performs one undo step.
+func runUndoStep(s models.UndoStep) error {
+	switch s.Action {
+	case models.UndoDeleteTask:
+		return applescript.DeleteTask(s.ID)
+	case models.UndoUpdateTask:
+		_, err := applescript.UpdateTask(s.ID, *s.Task)
+		return err
+	case models.UndoPlaceTask:
+		if err := applescript.PlaceTask(s.ID, s.ProjectID, s.AreaID, s.Restore); err != nil {
+			return err
+		}
+		if s.Task != nil {
+			_, err := applescript.UpdateTask(s.ID, *s.Task)
+			return err
+		}
+		return nil
+	case models.UndoMoveTask:
+		return database.MoveTask(s.ID, *s.Move)
+	case models.UndoCreateChecklistItem:
+		item, err := database.AddChecklistItemDirect(s.TaskID, models.CreateChecklistItemRequest{Title: s.Title})
+		if err != nil || !s.Completed {
+			return err
+		}
+		_, err = database.UpdateChecklistItem(s.TaskID, item.ID, models.UpdateChecklistItemRequest{Completed: &s.Completed})
+		return err
+	case models.UndoUpdateChecklistItem:
+		_, err := database.UpdateChecklistItem(s.TaskID, s.ID, *s.ChecklistItem)
+		return err
+	case models.UndoDeleteChecklistItem:
+		return database.DeleteChecklistItem(s.TaskID, s.ID)
+	case models.UndoSetChecklist:
+		if urlToken == "" {
+			return fmt.Errorf("restoring a checklist needs THINGS_URL_TOKEN")
+		}
+		unlock := lockChecklist(s.TaskID)
+		defer unlock()
+		return database.SetChecklist(s.TaskID, s.Checklist, urlToken)
+	case models.UndoDeleteProject:
+		return applescript.DeleteProject(s.ID, models.ContentsTrash, "")
+	case models.UndoUpdateProject:
+		if s.Project.Name != nil || s.Project.Notes != nil {
+			if _, err := applescript.UpdateProject(s.ID, *s.Project); err != nil {
+				return err
+			}
+		}
+		if s.Project.Status != nil {
+			return applescript.SetProjectStatus(s.ID, *s.Project.Status, false)
+		}
+		return nil
+	case models.UndoPlaceProject:
+		return applescript.PlaceProject(s.ID, s.AreaID, s.Restore)
+	case models.UndoCreateArea:
+		area, err := applescript.CreateArea(models.CreateAreaRequest{Name: s.Title})
+		if err != nil {
+			return err
+		}
+		for _, id := range s.Projects {
+			if err := applescript.PlaceProject(id, area.ID, s.Restore); err != nil {
+				return err
+			}
+		}
+		for _, id := range s.Tasks {
+			if err := applescript.PlaceTask(id, "", area.ID, s.Restore); err != nil {
+				return err
+			}
+		}
+		return nil
+	case models.UndoUpdateArea:
+		_, err := applescript.UpdateArea(s.ID, *s.Area)
+		return err
+	case models.UndoDeleteArea:
+		return applescript.DeleteArea(s.ID, models.ContentsInbox, "")
+	default:
+		return fmt.Errorf("unknown undo action %q", s.Action)
+	}
+}
+
+// nonNil returns tags, or an empty list so that reverting clears tags.
+func nonNil(tags []string) []string {
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/search"
+)
+
+// SearchHandler handles GET /search.
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	searchWith(w, r, database.SearchDocuments)
+}
+
+// searchWith runs the search in r's query over the documents load returns.
+func searchWith(w http.ResponseWriter, r *http.Request, load func(words []string, status string) ([]models.SearchDocument, error)) {
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	if len(q) > 500 {
+		writeError(w, http.StatusBadRequest, "q must be under 500 characters")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "open"
+	case "open", "completed", "canceled", "any":
+	default:
+		writeError(w, http.StatusBadRequest, "status must be one of: open, completed, canceled, any")
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+		limit = n
+	}
+
+	terms, err := search.Parse(q)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	words := make([]string, len(terms))
+	for i, t := range terms {
+		words[i] = t.Text
+	}
+	docs, err := load(words, status)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	docs = a.filterSearchDocuments(docs)
+	writeJSON(w, http.StatusOK, search.Rank(docs, terms, limit))
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/smartlist"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// SmartListsRouter returns a handler for all /smart-lists routes backed by s.
+func SmartListsRouter(s *store.Collection[models.SmartList]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		switch {
+		case path == "/smart-lists" || path == "/smart-lists/":
+			switch r.Method {
+			case http.MethodGet:
+				writeJSON(w, http.StatusOK, s.List())
+			case http.MethodPost:
+				createSmartList(w, r, s)
+			default:
+				methodNotAllowed(w)
+			}
+		default:
+			id := extractID(path, "/smart-lists/")
+			suffix := pathSuffix(path, "/smart-lists/")
+
+			switch {
+			case suffix == "/tasks" && r.Method == http.MethodGet:
+				getSmartListTasks(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodGet:
+				getSmartListByID(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodPatch:
+				updateSmartList(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodDelete:
+				deleteSmartList(w, r, s, id)
+			default:
+				writeError(w, http.StatusNotFound, "not found")
+			}
+		}
+	}
+}
+
+func getSmartListByID(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.SmartList], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	list, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "smart list not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func createSmartList(w http.ResponseWriter, r *http.Request, s *store.Collection[models.SmartList]) {
+	var req models.CreateSmartListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := smartlist.Parse(req.Query); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid query: "+err.Error())
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	list := models.SmartList{
+		ID:        store.NewID(),
+		Name:      req.Name,
+		Query:     req.Query,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.Put(list.ID, list); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, list)
+}
+
+func updateSmartList(w http.ResponseWriter, r *http.Request, s *store.Collection[models.SmartList], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	var req models.UpdateSmartListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Query != nil {
+		if _, err := smartlist.Parse(*req.Query); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid query: "+err.Error())
+			return
+		}
+	}
+
+	list, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "smart list not found")
+		return
+	}
+	if req.Name != nil {
+		list.Name = *req.Name
+	}
+	if req.Query != nil {
+		list.Query = *req.Query
+	}
+	list.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := s.Put(id, list); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func deleteSmartList(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.SmartList], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	ok, err := s.Delete(id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "smart list not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func getSmartListTasks(w http.ResponseWriter, r *http.Request, s *store.Collection[models.SmartList], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid smart list id")
+		return
+	}
+
+	list, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "smart list not found")
+		return
+	}
+	q, err := smartlist.Parse(list.Query)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+
+	tasks, err := database.GetTasks(q.UsesStatus())
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	tasks = q.Filter(a.filterTasks(tasks), time.Now())
+	for i := range tasks {
+		tasks[i].Position = i + 1
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
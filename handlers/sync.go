@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// syncMaxAge is how old a sync token may be before a full snapshot is
+// returned instead of changes.
+const syncMaxAge = 30 * 24 * time.Hour
+
+// SyncHandler handles GET /sync?since=<token>.
+func SyncHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+
+	var tok *database.SyncToken
+	if since := r.URL.Query().Get("since"); since != "" {
+		t, err := database.ParseSyncToken(since)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		tok = t
+	}
+
+	resp, err := database.Sync(tok, syncMaxAge)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// stampTasks sets ModifiedAt on tasks read through AppleScript. If the
+// database cannot be read, the tasks are left without it.
+func stampTasks(tasks []models.Task) {
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	dates := modificationDates(ids)
+	for i := range tasks {
+		tasks[i].ModifiedAt = dates[tasks[i].ID]
+	}
+}
+
+func stampTask(task *models.Task) {
+	task.ModifiedAt = modificationDates([]string{task.ID})[task.ID]
+}
+
+// stampProjects sets ModifiedAt on projects read through AppleScript.
+func stampProjects(projects []models.Project) {
+	ids := make([]string, len(projects))
+	for i, p := range projects {
+		ids[i] = p.ID
+	}
+	dates := modificationDates(ids)
+	for i := range projects {
+		projects[i].ModifiedAt = dates[projects[i].ID]
+	}
+}
+
+func stampProject(project *models.Project) {
+	project.ModifiedAt = modificationDates([]string{project.ID})[project.ID]
+}
+
+// stampAreas sets ModifiedAt on the projects listed in each area.
+func stampAreas(areas []models.Area) {
+	var ids []string
+	for _, a := range areas {
+		for _, p := range a.Projects {
+			ids = append(ids, p.ID)
+		}
+	}
+	dates := modificationDates(ids)
+	for i := range areas {
+		for j := range areas[i].Projects {
+			areas[i].Projects[j].ModifiedAt = dates[areas[i].Projects[j].ID]
+		}
+	}
+}
+
+func modificationDates(ids []string) map[string]string {
+	dates, err := database.ModificationDates(ids)
+	if err != nil {
+		slog.Warn("failed to read modification dates", "error", err)
+	}
+	return dates
+}
@@ -2,14 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/audit"
 	"github.com/egorkaBurkenya/things3-api/database"
 	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
 )
 
 // TasksRouter handles all /tasks routes.
@@ -57,7 +62,7 @@ func TasksRouter(w http.ResponseWriter, r *http.Request) {
 		}
 		getSomedayTasks(w, r)
 	default:
-		// /tasks/{id} or /tasks/{id}/complete or /tasks/{id}/cancel or /tasks/{id}/checklist/...
+		// /tasks/{id} or /tasks/{id}/complete, /cancel, /reopen, /duplicate or /tasks/{id}/checklist/...
 		id := extractID(path, "/tasks/")
 		suffix := pathSuffix(path, "/tasks/")
 
@@ -66,6 +71,12 @@ func TasksRouter(w http.ResponseWriter, r *http.Request) {
 			completeTask(w, r, id)
 		case suffix == "/cancel" && r.Method == http.MethodPost:
 			cancelTask(w, r, id)
+		case suffix == "/reopen" && r.Method == http.MethodPost:
+			reopenTask(w, r, id)
+		case suffix == "/duplicate" && r.Method == http.MethodPost:
+			duplicateTask(w, r, id)
+		case suffix == "/move" && r.Method == http.MethodPost:
+			moveTask(w, r, id)
 		case suffix == "/checklist" || suffix == "/checklist/":
 			switch r.Method {
 			case http.MethodGet:
@@ -79,6 +90,8 @@ func TasksRouter(w http.ResponseWriter, r *http.Request) {
 			itemID := strings.TrimPrefix(suffix, "/checklist/")
 			itemID = strings.TrimSuffix(itemID, "/")
 			switch r.Method {
+			case http.MethodGet:
+				getChecklistItem(w, r, id, itemID)
 			case http.MethodPatch:
 				updateChecklistItem(w, r, id, itemID)
 			case http.MethodDelete:
@@ -98,49 +111,54 @@ func TasksRouter(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func getInboxTasks(w http.ResponseWriter, _ *http.Request) {
+func getInboxTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := applescript.GetInboxTasks()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "inbox")
+	writeTaskList(w, r, tasks)
 }
 
-func getTodayTasks(w http.ResponseWriter, _ *http.Request) {
+func getTodayTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := applescript.GetTodayTasks()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "today")
+	writeTaskList(w, r, tasks)
 }
 
-func getUpcomingTasks(w http.ResponseWriter, _ *http.Request) {
+func getUpcomingTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := applescript.GetUpcomingTasks()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "upcoming")
+	writeTaskList(w, r, tasks)
 }
 
-func getAnytimeTasks(w http.ResponseWriter, _ *http.Request) {
+func getAnytimeTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := applescript.GetAnytimeTasks()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "anytime")
+	writeTaskList(w, r, tasks)
 }
 
-func getSomedayTasks(w http.ResponseWriter, _ *http.Request) {
+func getSomedayTasks(w http.ResponseWriter, r *http.Request) {
 	tasks, err := applescript.GetSomedayTasks()
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "someday")
+	writeTaskList(w, r, tasks)
 }
 
 func getFilteredTasks(w http.ResponseWriter, r *http.Request) {
@@ -164,16 +182,33 @@ func getFilteredTasks(w http.ResponseWriter, r *http.Request) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, tasks)
+	sortTasks(tasks, "")
+	writeTaskList(w, r, tasks)
+}
+
+// writeTaskList writes the tasks of a list that the client may see.
+func writeTaskList(w http.ResponseWriter, r *http.Request, tasks []models.Task) {
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+	tasks = a.filterTasks(tasks)
+	stampTasks(tasks)
+	writeEntity(w, r, http.StatusOK, tasks)
 }
 
-func getTaskByID(w http.ResponseWriter, _ *http.Request, id string) {
+func getTaskByID(w http.ResponseWriter, r *http.Request, id string) {
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
-	task, err := applescript.GetTaskByID(id)
+	a, ok := requestAccess(w, r)
+	if !ok {
+		return
+	}
+
+	task, err := loadTask(id)
 	if err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "task not found")
@@ -182,10 +217,21 @@ func getTaskByID(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, task)
+	if !a.allowsTask(*task) {
+		writeError(w, http.StatusNotFound, "task not found")
+		return
+	}
+	writeEntity(w, r, http.StatusOK, task)
 }
 
 func createTask(w http.ResponseWriter, r *http.Request) {
+	rec := auditWrite(r, "task.create", models.ItemTypeTask)
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
 	var req models.CreateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -197,6 +243,47 @@ func createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	a, ok := requestAccess(w, r)
+	if !ok || !guardNames(w, a, req.Project, req.Area) {
+		return
+	}
+
+	rec.Diff(nil, req)
+
+	if dry {
+		resolved, ok := resolveNames(w, req.Project, req.Area)
+		if !ok {
+			return
+		}
+		task := models.Task{
+			Title:   req.Title,
+			Notes:   req.Notes,
+			Status:  "open",
+			Project: req.Project,
+			Area:    req.Area,
+			Tags:    req.Tags,
+			Due:     req.Due,
+			When:    req.When,
+		}
+		for _, title := range req.ChecklistItems {
+			task.ChecklistItems = append(task.ChecklistItems, models.ChecklistItem{Title: title})
+		}
+		script := appleScriptPreview(applescript.CreateTaskScript(req))
+		if len(req.ChecklistItems) > 0 {
+			u, err := database.CreateTaskWithChecklistURL(
+				req.Title, req.ChecklistItems,
+				req.Notes, req.Project, req.Area, req.Due, req.When, req.Tags,
+			)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			script = urlPreview(u)
+		}
+		writeDryRun(w, rec, "task.create", task, resolved, script)
+		return
+	}
+
 	if len(req.ChecklistItems) > 0 {
 		// Use URL scheme to create task with checklist items (AppleScript can't do checklists).
 		taskID, err := database.CreateTaskWithChecklist(
@@ -214,7 +301,11 @@ func createTask(w http.ResponseWriter, r *http.Request) {
 		}
 		items, _ := database.GetChecklistItems(taskID)
 		task.ChecklistItems = items
-		writeJSON(w, http.StatusCreated, task)
+		stampTask(task)
+		rec.AddTarget(task.ID)
+		journal(w, r, "task.create", deleteStep(models.UndoDeleteTask, task.ID))
+		emit(models.ActionCreated, models.ItemTypeTask, task.ID, task.Title)
+		writeEntity(w, r, http.StatusCreated, task)
 		return
 	}
 
@@ -223,15 +314,35 @@ func createTask(w http.ResponseWriter, r *http.Request) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, task)
+	stampTask(task)
+	rec.AddTarget(task.ID)
+	journal(w, r, "task.create", deleteStep(models.UndoDeleteTask, task.ID))
+	emit(models.ActionCreated, models.ItemTypeTask, task.ID, task.Title)
+	writeEntity(w, r, http.StatusCreated, task)
 }
 
 func updateTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.update", models.ItemTypeTask, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	a, current, ok := guardTask(w, r, id)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "task not found", func() (*models.Task, error) { return loadTask(id) }) {
+		return
+	}
+
 	var req models.UpdateTaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -243,6 +354,29 @@ func updateTask(w http.ResponseWriter, r *http.Request, id string) {
 		return
 	}
 
+	if !guardTaskUpdate(w, a, *current, req.Project, req.Area) {
+		return
+	}
+
+	auditDiff(rec, req, func() (*models.Task, error) { return database.GetTask(id) })
+	if dry {
+		task, ok := loadCurrent(w, "task not found", func() (*models.Task, error) { return loadTask(id) })
+		if !ok {
+			return
+		}
+		resolved, ok := resolveNames(w, deref(req.Project), deref(req.Area))
+		if !ok {
+			return
+		}
+		script, err := applescript.UpdateTaskScript(id, req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeDryRun(w, rec, "task.update", updatedTask(*task, req), resolved, appleScriptPreview(script))
+		return
+	}
+	undo := revertTaskUpdate(id, req)
 	task, err := applescript.UpdateTask(id, req)
 	if err != nil {
 		if isNotFound(err) {
@@ -252,15 +386,35 @@ func updateTask(w http.ResponseWriter, r *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, task)
+	stampTask(task)
+	journal(w, r, "task.update", undo)
+	emit(statusAction(req.Status), models.ItemTypeTask, id, task.Title)
+	writeEntity(w, r, http.StatusOK, task)
 }
 
-func completeTask(w http.ResponseWriter, _ *http.Request, id string) {
+func completeTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.complete", models.ItemTypeTask, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, id); !ok {
+		return
+	}
+
+	auditStatus(rec, id, "completed")
+	if dry {
+		previewTaskStatus(w, rec, id, "completed")
+		return
+	}
+	undo := revertTaskStatus(id)
 	if err := applescript.CompleteTask(id); err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "task not found")
@@ -269,15 +423,34 @@ func completeTask(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
+	journal(w, r, "task.complete", undo)
+	emit(models.ActionCompleted, models.ItemTypeTask, id, "")
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func cancelTask(w http.ResponseWriter, _ *http.Request, id string) {
+func cancelTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.cancel", models.ItemTypeTask, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, id); !ok {
+		return
+	}
+
+	auditStatus(rec, id, "canceled")
+	if dry {
+		previewTaskStatus(w, rec, id, "canceled")
+		return
+	}
+	undo := revertTaskStatus(id)
 	if err := applescript.CancelTask(id); err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "task not found")
@@ -286,15 +459,83 @@ func cancelTask(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
+	journal(w, r, "task.cancel", undo)
+	emit(models.ActionUpdated, models.ItemTypeTask, id, "")
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func deleteTask(w http.ResponseWriter, _ *http.Request, id string) {
+func reopenTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.reopen", models.ItemTypeTask, id)
+
 	if err := models.ValidateThingsID(id); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, id); !ok {
+		return
+	}
+
+	auditStatus(rec, id, "open")
+	if dry {
+		previewTaskStatus(w, rec, id, "open")
+		return
+	}
+	undo := revertTaskStatus(id)
+	if err := applescript.ReopenTask(id); err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "task not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	journal(w, r, "task.reopen", undo)
+	emit(models.ActionUpdated, models.ItemTypeTask, id, "")
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func deleteTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.delete", models.ItemTypeTask, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, id); !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, "task not found", func() (*models.Task, error) { return loadTask(id) }) {
+		return
+	}
+
+	auditRemoved(rec, func() (*models.Task, error) { return database.GetTask(id) })
+	if dry {
+		task, ok := loadCurrent(w, "task not found", func() (*models.Task, error) { return loadTask(id) })
+		if !ok {
+			return
+		}
+		script, err := applescript.DeleteTaskScript(id)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeDryRun(w, rec, "task.delete", task, nil, appleScriptPreview(script))
+		return
+	}
+	undo := revertTaskDelete(id)
 	if err := applescript.DeleteTask(id); err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "task not found")
@@ -303,29 +544,229 @@ func deleteTask(w http.ResponseWriter, _ *http.Request, id string) {
 		internalError(w, err)
 		return
 	}
+	journal(w, r, "task.delete", undo)
+	emit(models.ActionDeleted, models.ItemTypeTask, id, "")
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
-func getChecklistItems(w http.ResponseWriter, _ *http.Request, taskID string) {
+func duplicateTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.duplicate", models.ItemTypeTask, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	a, _, ok := guardTask(w, r, id)
+	if !ok {
+		return
+	}
+
+	// The body is optional; an empty body duplicates with default options.
+	var req models.DuplicateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.ListID != "" && !guardTarget(w, a.allowsList(req.ListID)) {
+		return
+	}
+
+	if dry {
+		item, err := database.DuplicateTaskItem(id, req)
+		if err != nil {
+			if isNotFound(err) {
+				writeError(w, http.StatusNotFound, "task not found")
+				return
+			}
+			internalError(w, err)
+			return
+		}
+		previewImport(w, rec, "task.duplicate", item, []thingsurl.Item{item})
+		return
+	}
+
+	newID, err := database.DuplicateTask(id, req)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "task not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+
+	task, err := applescript.GetTaskByID(newID)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	items, _ := database.GetChecklistItems(newID)
+	task.ChecklistItems = items
+	stampTask(task)
+	rec.AddTarget(task.ID)
+	journal(w, r, "task.duplicate", deleteStep(models.UndoDeleteTask, task.ID))
+	emit(models.ActionCreated, models.ItemTypeTask, task.ID, task.Title)
+	writeEntity(w, r, http.StatusCreated, task)
+}
+
+func moveTask(w http.ResponseWriter, r *http.Request, id string) {
+	rec := auditWrite(r, "task.move", models.ItemTypeTask, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.MoveTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Before == id || req.After == id {
+		writeError(w, http.StatusBadRequest, "cannot move a task relative to itself")
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, id); !ok {
+		return
+	}
+	sibling := req.Before
+	if sibling == "" {
+		sibling = req.After
+	}
+	if _, _, ok := guardTask(w, r, sibling); !ok {
+		return
+	}
+	if !requireDirectWrites(w) {
+		return
+	}
+
+	rec.Diff(nil, req)
+	if dry {
+		previewMove(w, rec, id, req)
+		return
+	}
+	undo := revertTaskMove(id, req.List)
+	if err := database.MoveTask(id, req); err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, database.ErrNotInToday) || errors.Is(err, database.ErrNotSiblings) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		directWriteError(w, err)
+		return
+	}
+	journal(w, r, "task.move", undo)
+	emit(models.ActionUpdated, models.ItemTypeTask, id, "")
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// previewTaskStatus writes the dry run of a status change of a to-do.
+func previewTaskStatus(w http.ResponseWriter, rec *audit.Record, id, status string) {
+	task, ok := loadCurrent(w, "task not found", func() (*models.Task, error) { return loadTask(id) })
+	if !ok {
+		return
+	}
+	script, err := applescript.TaskStatusScript(id, status)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	task.Status = status
+	writeDryRun(w, rec, "task."+statusOperation(status), task, nil, appleScriptPreview(script))
+}
+
+// previewMove writes the dry run of a reorder. The statements are built from
+// the current order, so a move that is not possible fails as it would for real.
+func previewMove(w http.ResponseWriter, rec *audit.Record, id string, req models.MoveTaskRequest) {
+	sql, err := database.MoveTaskSQL(id, req)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, database.ErrNotInToday) || errors.Is(err, database.ErrNotSiblings) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	task, ok := loadCurrent(w, "task not found", func() (*models.Task, error) { return loadTask(id) })
+	if !ok {
+		return
+	}
+	writeDryRun(w, rec, "task.move", task, nil, sqlPreview(sql))
+}
+
+// sortTasks applies Things' display order and positions. If the database
+// cannot be read, the AppleScript order is kept.
+func sortTasks(tasks []models.Task, list string) {
+	if err := database.SortTasks(tasks, list); err != nil {
+		slog.Warn("failed to read task order, keeping AppleScript order", "error", err)
+	}
+}
+
+func getChecklistItems(w http.ResponseWriter, r *http.Request, taskID string) {
 	if err := models.ValidateThingsID(taskID); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	if _, _, ok := guardTask(w, r, taskID); !ok {
+		return
+	}
+
 	items, err := database.GetChecklistItems(taskID)
 	if err != nil {
 		internalError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, items)
+	writeEntity(w, r, http.StatusOK, items)
 }
 
 func addChecklistItem(w http.ResponseWriter, r *http.Request, taskID string) {
+	rec := auditWrite(r, "checklist_item.create", models.ItemTypeChecklistItem, taskID)
+
 	if err := models.ValidateThingsID(taskID); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, taskID); !ok {
+		return
+	}
+
 	var req models.CreateChecklistItemRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -337,34 +778,109 @@ func addChecklistItem(w http.ResponseWriter, r *http.Request, taskID string) {
 		return
 	}
 
-	// Try URL scheme (shows in Things UI immediately) if auth token is available.
-	authToken := os.Getenv("THINGS_URL_TOKEN")
-	if authToken != "" {
-		if err := database.AddChecklistItem(taskID, req.Title, authToken); err != nil {
+	// Prefer the URL scheme (shows in Things UI immediately) when an auth
+	// token is configured; otherwise fall back to a direct insert.
+	if urlToken == "" && !requireDirectWrites(w) {
+		return
+	}
+
+	rec.Diff(nil, req)
+	if dry {
+		if _, ok := loadCurrent(w, "task not found", func() (*models.Task, error) { return loadTask(taskID) }); !ok {
+			return
+		}
+		var script models.ScriptPreview
+		if urlToken != "" {
+			u, err := database.AddChecklistItemURL(taskID, req.Title)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			script = urlPreview(u)
+		} else {
+			sql, err := database.AddChecklistItemSQL(taskID, req)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			script = sqlPreview(sql)
+		}
+		writeDryRun(w, rec, "checklist_item.create", models.ChecklistItem{TaskID: taskID, Title: req.Title}, nil, script)
+		return
+	}
+	if urlToken != "" {
+		unlock := lockChecklist(taskID)
+		defer unlock()
+		existing, err := database.GetChecklistItems(taskID)
+		if err != nil {
 			internalError(w, err)
 			return
 		}
-		// Wait briefly for Things to process, then read back from DB.
-		time.Sleep(500 * time.Millisecond)
-		items, _ := database.GetChecklistItems(taskID)
-		if len(items) > 0 {
-			writeJSON(w, http.StatusCreated, items[len(items)-1])
+		if err := database.AddChecklistItem(taskID, req.Title, urlToken); err != nil {
+			internalError(w, err)
+			return
+		}
+		// Things adds the item asynchronously; read it back as the item
+		// with the requested title that was not there before.
+		var item *models.ChecklistItem
+		awaitChecklist(taskID, func(items []models.ChecklistItem) bool {
+			item = newChecklistItem(existing, items, req.Title)
+			return item != nil
+		})
+		if item == nil {
+			// Things has not added the item yet, so there is no ID to
+			// journal or report; the event watcher reports it once it is.
+			writeJSON(w, http.StatusAccepted, map[string]string{"status": "pending", "task_id": taskID, "title": req.Title})
 			return
 		}
-		writeJSON(w, http.StatusCreated, models.ChecklistItem{Title: req.Title})
+		rec.AddTarget(item.ID)
+		journal(w, r, "checklist_item.create", []models.UndoStep{{Action: models.UndoDeleteChecklistItem, TaskID: taskID, ID: item.ID}})
+		emitChecklist(models.ActionCreated, taskID, item.ID, item.Title)
+		writeJSON(w, http.StatusCreated, item)
 		return
 	}
 
 	// Fallback: direct SQLite insert (readable via API but may not show in Things UI).
 	item, err := database.AddChecklistItemDirect(taskID, req)
 	if err != nil {
-		internalError(w, err)
+		directWriteError(w, err)
 		return
 	}
+	rec.AddTarget(item.ID)
+	journal(w, r, "checklist_item.create", []models.UndoStep{{Action: models.UndoDeleteChecklistItem, TaskID: taskID, ID: item.ID}})
+	emitChecklist(models.ActionCreated, taskID, item.ID, item.Title)
 	writeJSON(w, http.StatusCreated, item)
 }
 
+func getChecklistItem(w http.ResponseWriter, r *http.Request, taskID, itemID string) {
+	if err := models.ValidateThingsID(taskID); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid task id")
+		return
+	}
+	if err := models.ValidateThingsID(itemID); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid checklist item id")
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, taskID); !ok {
+		return
+	}
+
+	item, err := loadChecklistItem(taskID, itemID)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "checklist item not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	writeEntity(w, r, http.StatusOK, item)
+}
+
 func updateChecklistItem(w http.ResponseWriter, r *http.Request, taskID, itemID string) {
+	rec := auditWrite(r, "checklist_item.update", models.ItemTypeChecklistItem, taskID, itemID)
+
 	if err := models.ValidateThingsID(taskID); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
@@ -374,6 +890,24 @@ func updateChecklistItem(w http.ResponseWriter, r *http.Request, taskID, itemID
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, taskID); !ok {
+		return
+	}
+	// Prefer a direct update, which keeps item IDs; without direct writes
+	// fall back to the URL scheme when an auth token is configured.
+	if urlToken == "" && !requireDirectWrites(w) {
+		return
+	}
+
+	if !checkIfMatch(w, r, "checklist item not found", func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) }) {
+		return
+	}
+
 	var req models.UpdateChecklistItemRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
@@ -385,19 +919,42 @@ func updateChecklistItem(w http.ResponseWriter, r *http.Request, taskID, itemID
 		return
 	}
 
+	auditDiff(rec, req, func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) })
+	if !database.DirectWritesEnabled() {
+		setChecklistItem(w, r, rec, dry, taskID, itemID, &req)
+		return
+	}
+	if dry {
+		item, ok := loadCurrent(w, "checklist item not found", func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) })
+		if !ok {
+			return
+		}
+		sql, err := database.UpdateChecklistItemSQL(taskID, itemID, req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeDryRun(w, rec, "checklist_item.update", updatedChecklistItem(*item, req), nil, sqlPreview(sql))
+		return
+	}
+	undo := revertChecklistUpdate(taskID, itemID, req)
 	item, err := database.UpdateChecklistItem(taskID, itemID, req)
 	if err != nil {
 		if isNotFound(err) {
 			writeError(w, http.StatusNotFound, "checklist item not found")
 			return
 		}
-		internalError(w, err)
+		directWriteError(w, err)
 		return
 	}
-	writeJSON(w, http.StatusOK, item)
+	journal(w, r, "checklist_item.update", undo)
+	emitChecklist(models.ActionUpdated, taskID, itemID, item.Title)
+	writeEntity(w, r, http.StatusOK, item)
 }
 
-func deleteChecklistItem(w http.ResponseWriter, _ *http.Request, taskID, itemID string) {
+func deleteChecklistItem(w http.ResponseWriter, r *http.Request, taskID, itemID string) {
+	rec := auditWrite(r, "checklist_item.delete", models.ItemTypeChecklistItem, taskID, itemID)
+
 	if err := models.ValidateThingsID(taskID); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid task id")
 		return
@@ -407,13 +964,239 @@ func deleteChecklistItem(w http.ResponseWriter, _ *http.Request, taskID, itemID
 		return
 	}
 
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	if _, _, ok := guardTask(w, r, taskID); !ok {
+		return
+	}
+	// Prefer a direct delete, which keeps the other items' IDs; without
+	// direct writes fall back to the URL scheme when an auth token is
+	// configured.
+	if urlToken == "" && !requireDirectWrites(w) {
+		return
+	}
+
+	if !checkIfMatch(w, r, "checklist item not found", func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) }) {
+		return
+	}
+
+	auditRemoved(rec, func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) })
+	if !database.DirectWritesEnabled() {
+		setChecklistItem(w, r, rec, dry, taskID, itemID, nil)
+		return
+	}
+	if dry {
+		item, ok := loadCurrent(w, "checklist item not found", func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) })
+		if !ok {
+			return
+		}
+		sql, err := database.DeleteChecklistItemSQL(taskID, itemID)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeDryRun(w, rec, "checklist_item.delete", item, nil, sqlPreview(sql))
+		return
+	}
+	undo := revertChecklistDelete(taskID, itemID)
 	if err := database.DeleteChecklistItem(taskID, itemID); err != nil {
-		internalError(w, err)
+		directWriteError(w, err)
 		return
 	}
+	journal(w, r, "checklist_item.delete", undo)
+	emitChecklist(models.ActionDeleted, taskID, itemID, "")
 	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
 }
 
+// setChecklistItem updates checklist item itemID with req, or deletes it
+// when req is nil, through the URL scheme. Things can only replace a
+// checklist as a whole, so every item of the to-do gets a new ID; the
+// response carries the updated item's new ID once Things has applied the
+// change, and journaled operations are moved over to the new IDs. Undoing
+// restores the whole checklist.
+func setChecklistItem(w http.ResponseWriter, r *http.Request, rec *audit.Record, dry bool, taskID, itemID string, req *models.UpdateChecklistItemRequest) {
+	name := "checklist_item.update"
+	if req == nil {
+		name = "checklist_item.delete"
+	}
+
+	// Hold the to-do's checklist from reading it until Things has applied
+	// the new one, so that concurrent changes are not lost.
+	unlock := lockChecklist(taskID)
+	defer unlock()
+
+	before, after, index, err := database.EditChecklist(taskID, itemID, req)
+	if err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusNotFound, "checklist item not found")
+			return
+		}
+		internalError(w, err)
+		return
+	}
+	if dry {
+		item, ok := loadCurrent(w, "checklist item not found", func() (*models.ChecklistItem, error) { return loadChecklistItem(taskID, itemID) })
+		if !ok {
+			return
+		}
+		u, err := database.SetChecklistURL(taskID, after)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		result := *item
+		if req != nil {
+			result = updatedChecklistItem(result, *req)
+		}
+		writeDryRun(w, rec, name, result, nil, urlPreview(u))
+		return
+	}
+
+	if err := database.SetChecklist(taskID, after, urlToken); err != nil {
+		internalError(w, err)
+		return
+	}
+	journal(w, r, name, []models.UndoStep{{Action: models.UndoSetChecklist, TaskID: taskID, ID: itemID, Checklist: before}})
+
+	items := awaitChecklist(taskID, func(items []models.ChecklistItem) bool {
+		return checklistMatches(items, after)
+	})
+	if items != nil {
+		renamed := make(map[string]string, len(items))
+		for i, item := range items {
+			renamed[after[i].ID] = item.ID
+		}
+		renameJournalTargets(renamed)
+	}
+	if req == nil {
+		emitChecklist(models.ActionDeleted, taskID, itemID, "")
+		writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		return
+	}
+	item := models.ChecklistItem{Title: after[index].Title, Completed: after[index].Status == "completed"}
+	if items != nil {
+		item = items[index]
+		rec.AddTarget(item.ID)
+	}
+	emitChecklist(models.ActionUpdated, taskID, item.ID, item.Title)
+	writeEntity(w, r, http.StatusOK, item)
+}
+
+// awaitChecklist waits up to two seconds for done to accept the checklist
+// of a to-do, as Things applies URL scheme commands asynchronously. It
+// returns the checklist, or nil if it was not accepted in time.
+func awaitChecklist(taskID string, done func([]models.ChecklistItem) bool) []models.ChecklistItem {
+	for deadline := time.Now().Add(2 * time.Second); ; {
+		items, err := database.GetChecklistItems(taskID)
+		if err == nil && done(items) {
+			return items
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// checklistLocks holds a lock per to-do whose checklist is being replaced
+// through the URL scheme, which reads the checklist and then writes all of
+// it back.
+var checklistLocks = struct {
+	sync.Mutex
+	tasks map[string]*checklistLock
+}{tasks: make(map[string]*checklistLock)}
+
+type checklistLock struct {
+	sync.Mutex
+	waiters int
+}
+
+// lockChecklist locks the checklist of a to-do and returns the function that
+// unlocks it.
+func lockChecklist(taskID string) func() {
+	checklistLocks.Lock()
+	l := checklistLocks.tasks[taskID]
+	if l == nil {
+		l = &checklistLock{}
+		checklistLocks.tasks[taskID] = l
+	}
+	l.waiters++
+	checklistLocks.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		checklistLocks.Lock()
+		if l.waiters--; l.waiters == 0 {
+			delete(checklistLocks.tasks, taskID)
+		}
+		checklistLocks.Unlock()
+	}
+}
+
+// newChecklistItem returns the last item of items with the given title that
+// is not in existing, or nil.
+func newChecklistItem(existing, items []models.ChecklistItem, title string) *models.ChecklistItem {
+	seen := make(map[string]bool, len(existing))
+	for _, it := range existing {
+		seen[it.ID] = true
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].Title == title && !seen[items[i].ID] {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+func checklistMatches(items []models.ChecklistItem, want []models.ChecklistEntry) bool {
+	if len(items) != len(want) {
+		return false
+	}
+	for i, e := range want {
+		if items[i].Title != e.Title || items[i].Completed != (e.Status == "completed") {
+			return false
+		}
+	}
+	return true
+}
+
+// requireDirectWrites writes 501 and returns false when a change that can
+// only be made directly in the Things database is not allowed.
+func requireDirectWrites(w http.ResponseWriter) bool {
+	if database.DirectWritesEnabled() {
+		return true
+	}
+	writeError(w, http.StatusNotImplemented, directWritesDisabled("this change"))
+	return false
+}
+
+// directWritesDisabled returns the message for a change that needs a direct
+// database write while they are not allowed.
+func directWritesDisabled(change string) string {
+	if database.ReadOnlyBuild {
+		return change + " needs a direct database write, which this build leaves out"
+	}
+	return change + " needs a direct database write; set THINGS_API_DIRECT_WRITES=true to allow it"
+}
+
+// directWriteError writes the response for a failed direct database write.
+func directWriteError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, database.ErrDirectWritesDisabled):
+		writeError(w, http.StatusNotImplemented, directWritesDisabled("this change"))
+	case errors.Is(err, database.ErrUnsupportedSchema):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	case errors.Is(err, database.ErrThingsRunning):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		internalError(w, err)
+	}
+}
+
 func isNotFound(err error) bool {
 	msg := strings.ToLower(err.Error())
 	return strings.Contains(msg, "not found") ||
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+	"github.com/egorkaBurkenya/things3-api/templates"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// TemplatesRouter returns a handler for all /templates routes backed by s.
+func TemplatesRouter(s *store.Collection[models.Template]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		switch {
+		case path == "/templates" || path == "/templates/":
+			switch r.Method {
+			case http.MethodGet:
+				writeJSON(w, http.StatusOK, s.List())
+			case http.MethodPost:
+				createTemplate(w, r, s)
+			default:
+				methodNotAllowed(w)
+			}
+		default:
+			id := extractID(path, "/templates/")
+			suffix := pathSuffix(path, "/templates/")
+
+			switch {
+			case suffix == "/instantiate" && r.Method == http.MethodPost:
+				instantiateTemplate(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodGet:
+				getTemplateByID(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodPatch:
+				updateTemplate(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodDelete:
+				deleteTemplate(w, r, s, id)
+			default:
+				writeError(w, http.StatusNotFound, "not found")
+			}
+		}
+	}
+}
+
+func getTemplateByID(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.Template], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	tmpl, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
+func createTemplate(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Template]) {
+	var req models.CreateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	tmpl := models.Template{
+		ID:          store.NewID(),
+		Name:        req.Name,
+		Description: req.Description,
+		Project:     req.Project,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.Put(tmpl.ID, tmpl); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, tmpl)
+}
+
+func updateTemplate(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Template], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	var req models.UpdateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tmpl, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	if req.Name != nil {
+		tmpl.Name = *req.Name
+	}
+	if req.Description != nil {
+		tmpl.Description = *req.Description
+	}
+	if req.Project != nil {
+		tmpl.Project = *req.Project
+	}
+	tmpl.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := s.Put(id, tmpl); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tmpl)
+}
+
+func deleteTemplate(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.Template], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	ok, err := s.Delete(id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// instantiateTemplate renders the template and creates the project tree in
+// one things:///json call, since headings cannot be created via AppleScript.
+func instantiateTemplate(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Template], id string) {
+	rec := auditWrite(r, "template.instantiate", models.ItemTypeProject, id)
+
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid template id")
+		return
+	}
+
+	dry, ok := dryRun(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.InstantiateTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tmpl, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "template not found")
+		return
+	}
+
+	if req.AreaID != "" {
+		if _, err := applescript.GetAreaByID(req.AreaID); err != nil {
+			if isNotFound(err) {
+				writeError(w, http.StatusNotFound, "area not found")
+				return
+			}
+			internalError(w, err)
+			return
+		}
+	}
+
+	start := time.Now()
+	if req.StartDate != "" {
+		start, _ = time.ParseInLocation("2006-01-02", req.StartDate, time.Local)
+	}
+
+	project, err := templates.Render(tmpl, templates.Options{
+		AreaID:    req.AreaID,
+		Variables: req.Variables,
+		Start:     start,
+		Deadline:  req.Deadline,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if dry {
+		previewImport(w, rec, "template.instantiate", nil, []thingsurl.Item{project})
+		return
+	}
+
+	items, err := database.ImportThingsJSON([]thingsurl.Item{project}, urlToken)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	auditImported(rec, items)
+	journal(w, r, "template.instantiate", revertImported(items, nil))
+	emitImported(items)
+	writeJSON(w, http.StatusCreated, items)
+}
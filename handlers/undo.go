@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// maxOperations caps the undo journal; the oldest operations are dropped
+// first.
+const maxOperations = 1000
+
+// journalPruneInterval is how often the undo journal is pruned, so the cap
+// and retention may be exceeded by the operations of one interval.
+const journalPruneInterval = time.Minute
+
+var (
+	// journalStore receives undoable writes made through the API. It is nil
+	// until SetJournal is called, in which case nothing is journaled.
+	journalStore     *store.Collection[models.Operation]
+	journalRetention time.Duration
+
+	// undoMu serializes undos so an operation cannot be undone twice.
+	undoMu sync.Mutex
+
+	pruneMu   sync.Mutex
+	lastPrune time.Time
+)
+
+// SetJournal sets the undo journal that write handlers record to.
+// Operations older than retention are dropped.
+func SetJournal(ops *store.Collection[models.Operation], retention time.Duration) {
+	journalStore = ops
+	journalRetention = retention
+}
+
+// journal records a successful write with the steps that revert it and sets
+// the Operation-Id response header. Writes without steps (because their
+// prior state could not be read) are not journaled.
+func journal(w http.ResponseWriter, r *http.Request, name string, steps []models.UndoStep) {
+	if journalStore == nil || len(steps) == 0 {
+		return
+	}
+	client, _ := auth.ClientFrom(r.Context())
+	op := models.Operation{
+		ID:        store.NewID(),
+		Name:      name,
+		Steps:     steps,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if client != nil {
+		op.Client = client.Name
+	}
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		for _, id := range step.Targets() {
+			if !seen[id] {
+				seen[id] = true
+				op.Targets = append(op.Targets, id)
+			}
+		}
+	}
+	if err := journalStore.Put(op.ID, op); err != nil {
+		slog.Error("failed to record operation", "operation", name, "error", err)
+		return
+	}
+	w.Header().Set("Operation-Id", op.ID)
+
+	pruneMu.Lock()
+	prune := time.Since(lastPrune) >= journalPruneInterval
+	if prune {
+		lastPrune = time.Now()
+	}
+	pruneMu.Unlock()
+	if prune {
+		pruneJournal(journalStore)
+	}
+}
+
+// renameJournalTargets replaces the item IDs in renamed, old to new, in the
+// targets and steps of journaled operations, after Things gave items new
+// IDs.
+func renameJournalTargets(renamed map[string]string) {
+	if journalStore == nil {
+		return
+	}
+	rename := func(id string) (string, bool) {
+		if to, ok := renamed[id]; ok && to != id {
+			return to, true
+		}
+		return id, false
+	}
+	for _, op := range journalStore.List() {
+		changed := false
+		targets := make([]string, len(op.Targets))
+		for i, id := range op.Targets {
+			var ok bool
+			targets[i], ok = rename(id)
+			changed = changed || ok
+		}
+		steps := make([]models.UndoStep, len(op.Steps))
+		for i, step := range op.Steps {
+			var ok bool
+			step.ID, ok = rename(step.ID)
+			changed = changed || ok
+			steps[i] = step
+		}
+		if !changed {
+			continue
+		}
+		op.Targets, op.Steps = targets, steps
+		if err := journalStore.Put(op.ID, op); err != nil {
+			slog.Warn("failed to rename operation targets", "id", op.ID, "error", err)
+		}
+	}
+}
+
+// pruneJournal drops operations past the retention period and beyond
+// maxOperations in one write.
+func pruneJournal(ops *store.Collection[models.Operation]) {
+	drop := make(map[string]bool)
+	cutoff := time.Now().Add(-journalRetention)
+	for i, op := range sortedOperations(ops) {
+		if i >= maxOperations || (journalRetention > 0 && operationTime(op).Before(cutoff)) {
+			drop[op.ID] = true
+		}
+	}
+	if len(drop) == 0 {
+		return
+	}
+	if _, err := ops.DeleteWhere(func(op models.Operation) bool { return drop[op.ID] }); err != nil {
+		slog.Warn("failed to prune operations", "error", err)
+	}
+}
+
+// sortedOperations returns all journaled operations, newest first.
+func sortedOperations(ops *store.Collection[models.Operation]) []models.Operation {
+	all := ops.List()
+	sort.SliceStable(all, func(i, j int) bool {
+		return operationTime(all[i]).After(operationTime(all[j]))
+	})
+	return all
+}
+
+func operationTime(op models.Operation) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, op.CreatedAt)
+	return t
+}
+
+// OperationsRouter handles GET /operations and GET /operations/{id}. Clients
+// see their own operations; admin tokens see everyone's.
+func OperationsRouter(ops *store.Collection[models.Operation]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+
+		if r.URL.Path == "/operations" || r.URL.Path == "/operations/" {
+			f, err := models.ParseOperationsFilter(r.URL.Query().Get)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			out := []models.Operation{}
+			for _, op := range sortedOperations(ops) {
+				if len(out) == f.Limit {
+					break
+				}
+				if ownsOperation(r, op) && f.Matches(op) {
+					out = append(out, op)
+				}
+			}
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+
+		id := extractID(r.URL.Path, "/operations/")
+		if err := models.ValidateThingsID(id); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid operation id")
+			return
+		}
+		op, ok := ops.Get(id)
+		if !ok || !ownsOperation(r, op) {
+			writeError(w, http.StatusNotFound, "operation not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, op)
+	}
+}
+
+// UndoHandler handles POST /undo/{id}. The operation's steps run in order;
+// it is refused if a later operation still in effect changed the same items.
+func UndoHandler(ops *store.Collection[models.Operation]) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+
+		id := extractID(r.URL.Path, "/undo/")
+		rec := auditWrite(r, "operation.undo", "", id)
+		if err := models.ValidateThingsID(id); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid operation id")
+			return
+		}
+		dry, ok := dryRun(w, r)
+		if !ok {
+			return
+		}
+
+		undoMu.Lock()
+		defer undoMu.Unlock()
+
+		op, ok := ops.Get(id)
+		if !ok || !ownsOperation(r, op) {
+			writeError(w, http.StatusNotFound, "operation not found")
+			return
+		}
+		if op.UndoneAt != "" {
+			writeError(w, http.StatusConflict, "operation has already been undone")
+			return
+		}
+		client, _ := auth.ClientFrom(r.Context())
+		if scope := operationScope(op.Name); client != nil && !client.HasScope(scope) {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("token does not have the %s scope", scope))
+			return
+		}
+		if later := laterOperation(ops, op); later != nil {
+			writeError(w, http.StatusConflict,
+				fmt.Sprintf("operation %s (%s) changed the same items later; undo it first", later.ID, later.Name))
+			return
+		}
+
+		rec.AddTarget(op.Targets...)
+		if dry {
+			writeDryRun(w, rec, "operation.undo", op, nil)
+			return
+		}
+		for i, step := range op.Steps {
+			if err := runUndoStep(step); err != nil {
+				slog.Error("undo failed", "operation", op.ID, "step", i+1, "action", step.Action, "error", err)
+				status, msg := http.StatusInternalServerError, "undo failed; nothing was changed"
+				if i == 0 && errors.Is(err, database.ErrDirectWritesDisabled) {
+					status, msg = http.StatusNotImplemented, directWritesDisabled("undoing this operation")
+				}
+				if i > 0 {
+					msg = fmt.Sprintf("undo failed after %d of %d steps; the operation is partially undone", i, len(op.Steps))
+				}
+				writeError(w, status, msg)
+				return
+			}
+		}
+
+		op.UndoneAt = time.Now().UTC().Format(time.RFC3339Nano)
+		if client != nil {
+			op.UndoneBy = client.Name
+		}
+		if err := ops.Put(op.ID, op); err != nil {
+			internalError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, op)
+	}
+}
+
+// ownsOperation reports whether the request's client may see op: its own
+// operations, or any with the admin scope.
+func ownsOperation(r *http.Request, op models.Operation) bool {
+	client, ok := auth.ClientFrom(r.Context())
+	if !ok {
+		return true
+	}
+	return client.HasScope(models.ScopeAdmin) || client.Name == op.Client
+}
+
+// operationScope returns the scope needed to undo an operation: the write
+// scope of the kind of item it changed.
+func operationScope(name string) string {
+	if strings.HasPrefix(name, "task.") || strings.HasPrefix(name, "checklist_item.") {
+		return models.ScopeTasksWrite
+	}
+	return models.ScopeProjectsWrite
+}
+
+// laterOperation returns the newest operation made after op that has not
+// been undone and shares a target with it, or nil.
+func laterOperation(ops *store.Collection[models.Operation], op models.Operation) *models.Operation {
+	targets := make(map[string]bool, len(op.Targets))
+	for _, id := range op.Targets {
+		targets[id] = true
+	}
+	created := operationTime(op)
+	for _, other := range sortedOperations(ops) {
+		if !operationTime(other).After(created) {
+			break
+		}
+		if other.UndoneAt != "" {
+			continue
+		}
+		for _, id := range other.Targets {
+			if targets[id] {
+				return &other
+			}
+		}
+	}
+	return nil
+}
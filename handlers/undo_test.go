@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// useJournal points the handlers at a journal holding ops for the duration
+// of a test. The operations are written in one go rather than saved one by
+// one.
+func useJournal(t *testing.T, retention time.Duration, ops ...models.Operation) *store.Collection[models.Operation] {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "operations.json")
+	if len(ops) > 0 {
+		byID := make(map[string]models.Operation, len(ops))
+		for _, op := range ops {
+			byID[op.ID] = op
+		}
+		data, err := json.Marshal(byID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	journal, err := store.Open[models.Operation](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevStore, prevRetention := journalStore, journalRetention
+	SetJournal(journal, retention)
+	t.Cleanup(func() { journalStore, journalRetention = prevStore, prevRetention })
+	return journal
+}
+
+func TestRenameJournalTargets(t *testing.T) {
+	ops := useJournal(t, 0)
+	put := func(op models.Operation) {
+		if err := ops.Put(op.ID, op); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put(models.Operation{
+		ID:      "op1",
+		Targets: []string{"ItemOld1", "TaskA"},
+		Steps:   []models.UndoStep{{Action: models.UndoUpdateChecklistItem, TaskID: "TaskA", ID: "ItemOld1"}},
+	})
+	put(models.Operation{
+		ID:      "op2",
+		Targets: []string{"ItemOther"},
+		Steps:   []models.UndoStep{{Action: models.UndoDeleteChecklistItem, TaskID: "TaskB", ID: "ItemOther"}},
+	})
+
+	renameJournalTargets(map[string]string{"ItemOld1": "ItemNew1", "ItemOld2": "ItemNew2"})
+
+	op1, _ := ops.Get("op1")
+	if op1.Targets[0] != "ItemNew1" || op1.Targets[1] != "TaskA" || op1.Steps[0].ID != "ItemNew1" || op1.Steps[0].TaskID != "TaskA" {
+		t.Errorf("op1 = %+v, want ItemOld1 renamed to ItemNew1", op1)
+	}
+	op2, _ := ops.Get("op2")
+	if op2.Targets[0] != "ItemOther" || op2.Steps[0].ID != "ItemOther" {
+		t.Errorf("op2 = %+v, want it unchanged", op2)
+	}
+}
+
+// operationAt returns an operation on targets created at t.
+func operationAt(id string, t time.Time, targets ...string) models.Operation {
+	return models.Operation{ID: id, Targets: targets, CreatedAt: t.Format(time.RFC3339Nano)}
+}
+
+func TestPruneJournal(t *testing.T) {
+	now := time.Now()
+	many := make([]models.Operation, maxOperations+2)
+	for i := range many {
+		many[i] = operationAt(fmt.Sprintf("op%04d", i), now.Add(-time.Duration(i)*time.Second))
+	}
+	tests := []struct {
+		name      string
+		retention time.Duration
+		ops       []models.Operation
+		wantLen   int
+		dropped   []string
+	}{
+		{
+			name:      "past retention",
+			retention: 24 * time.Hour,
+			ops:       []models.Operation{operationAt("recent", now.Add(-time.Hour)), operationAt("old", now.Add(-48*time.Hour))},
+			wantLen:   1,
+			dropped:   []string{"old"},
+		},
+		{
+			name:    "kept forever without retention",
+			ops:     []models.Operation{operationAt("recent", now.Add(-time.Hour)), operationAt("old", now.Add(-48*time.Hour))},
+			wantLen: 2,
+		},
+		{
+			name:      "over the cap",
+			retention: 24 * time.Hour,
+			ops:       many,
+			wantLen:   maxOperations,
+			dropped:   []string{many[maxOperations].ID, many[maxOperations+1].ID},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := useJournal(t, tt.retention, tt.ops...)
+			pruneJournal(journal)
+			if n := len(journal.List()); n != tt.wantLen {
+				t.Errorf("%d operations kept, want %d", n, tt.wantLen)
+			}
+			for _, id := range tt.dropped {
+				if _, ok := journal.Get(id); ok {
+					t.Errorf("%s was kept", id)
+				}
+			}
+		})
+	}
+}
+
+func TestLaterOperation(t *testing.T) {
+	now := time.Now()
+	op := operationAt("op", now.Add(-time.Hour), "TaskA", "ItemA")
+	undone := operationAt("undone", now, "TaskA")
+	undone.UndoneAt = now.Format(time.RFC3339Nano)
+	tests := []struct {
+		name   string
+		others []models.Operation
+		want   string
+	}{
+		{name: "none", want: ""},
+		{name: "earlier on the same target", others: []models.Operation{operationAt("earlier", now.Add(-2*time.Hour), "TaskA")}, want: ""},
+		{name: "later on another target", others: []models.Operation{operationAt("other", now, "TaskB")}, want: ""},
+		{name: "later but undone", others: []models.Operation{undone}, want: ""},
+		{name: "later on a shared target", others: []models.Operation{operationAt("later", now, "ItemA")}, want: "later"},
+		{
+			name:   "newest of several",
+			others: []models.Operation{operationAt("later", now.Add(-time.Minute), "TaskA"), operationAt("latest", now, "TaskA"), undone},
+			want:   "latest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journal := useJournal(t, 0, append([]models.Operation{op}, tt.others...)...)
+			got := ""
+			if later := laterOperation(journal, op); later != nil {
+				got = later.ID
+			}
+			if got != tt.want {
+				t.Errorf("laterOperation = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
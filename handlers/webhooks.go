@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+	"github.com/egorkaBurkenya/things3-api/webhooks"
+)
+
+// WebhooksRouter returns a handler for all /webhooks routes backed by s,
+// with deliveries managed by d.
+func WebhooksRouter(s *store.Collection[models.Webhook], d *webhooks.Dispatcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		switch {
+		case path == "/webhooks" || path == "/webhooks/":
+			switch r.Method {
+			case http.MethodGet:
+				hooks := s.List()
+				for i := range hooks {
+					hooks[i].Secret = ""
+				}
+				writeJSON(w, http.StatusOK, hooks)
+			case http.MethodPost:
+				createWebhook(w, r, s)
+			default:
+				methodNotAllowed(w)
+			}
+		default:
+			// /webhooks/{id}, /webhooks/{id}/deliveries or
+			// /webhooks/{id}/deliveries/{deliveryID}/replay
+			id := extractID(path, "/webhooks/")
+			suffix := strings.TrimSuffix(pathSuffix(path, "/webhooks/"), "/")
+
+			switch {
+			case suffix == "/deliveries" && r.Method == http.MethodGet:
+				getWebhookDeliveries(w, r, s, d, id)
+			case strings.HasPrefix(suffix, "/deliveries/") && strings.HasSuffix(suffix, "/replay") && r.Method == http.MethodPost:
+				deliveryID := strings.TrimSuffix(strings.TrimPrefix(suffix, "/deliveries/"), "/replay")
+				replayWebhookDelivery(w, r, s, d, id, deliveryID)
+			case suffix == "" && r.Method == http.MethodGet:
+				getWebhookByID(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodPatch:
+				updateWebhook(w, r, s, id)
+			case suffix == "" && r.Method == http.MethodDelete:
+				deleteWebhook(w, r, s, d, id)
+			default:
+				writeError(w, http.StatusNotFound, "not found")
+			}
+		}
+	}
+}
+
+func getWebhookByID(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.Webhook], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	hook, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+// createWebhook registers a webhook. The secret is only returned here; if
+// none is given, one is generated.
+func createWebhook(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Webhook]) {
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	hook := models.Webhook{
+		ID:        store.NewID(),
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    req.Events,
+		Resources: req.Resources,
+		Active:    req.Active == nil || *req.Active,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if hook.Secret == "" {
+		hook.Secret = webhooks.NewSecret()
+	}
+	if err := s.Put(hook.ID, hook); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, hook)
+}
+
+func updateWebhook(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Webhook], id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	hook, ok := s.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	if req.URL != nil {
+		hook.URL = *req.URL
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.Events != nil {
+		hook.Events = *req.Events
+	}
+	if req.Resources != nil {
+		hook.Resources = *req.Resources
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	hook.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+
+	if err := s.Put(id, hook); err != nil {
+		internalError(w, err)
+		return
+	}
+	hook.Secret = ""
+	writeJSON(w, http.StatusOK, hook)
+}
+
+func deleteWebhook(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.Webhook], d *webhooks.Dispatcher, id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	ok, err := s.Delete(id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+	if err := d.DeleteDeliveries(id); err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request, s *store.Collection[models.Webhook], d *webhooks.Dispatcher, id string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	if _, ok := s.Get(id); !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		writeError(w, http.StatusBadRequest, "status must be one of: pending, succeeded, failed")
+		return
+	}
+
+	deliveries := d.Deliveries(id)
+	if status != "" {
+		filtered := []models.WebhookDelivery{}
+		for _, del := range deliveries {
+			if del.Status == status {
+				filtered = append(filtered, del)
+			}
+		}
+		deliveries = filtered
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func replayWebhookDelivery(w http.ResponseWriter, _ *http.Request, s *store.Collection[models.Webhook], d *webhooks.Dispatcher, id, deliveryID string) {
+	if err := models.ValidateThingsID(id); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+	if err := models.ValidateThingsID(deliveryID); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid delivery id")
+		return
+	}
+	if _, ok := s.Get(id); !ok {
+		writeError(w, http.StatusNotFound, "webhook not found")
+		return
+	}
+
+	var original *models.WebhookDelivery
+	for _, del := range d.Deliveries(id) {
+		if del.ID == deliveryID {
+			original = &del
+			break
+		}
+	}
+	if original == nil {
+		writeError(w, http.StatusNotFound, "delivery not found")
+		return
+	}
+
+	replay, err := d.Replay(*original)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, replay)
+}
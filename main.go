@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 
+	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/backups"
 	"github.com/egorkaBurkenya/things3-api/config"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/events"
 	"github.com/egorkaBurkenya/things3-api/handlers"
 	"github.com/egorkaBurkenya/things3-api/middleware"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+	"github.com/egorkaBurkenya/things3-api/webhooks"
 )
 
 func main() {
@@ -16,11 +27,105 @@ func main() {
 		slog.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
+	database.SetPath(cfg.DBPath)
+
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(cfg, os.Args[1:]))
+	}
 
 	if cfg.LogLevel == "debug" {
 		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 	}
 
+	// With several Things accounts on this Mac, guessing could serve or
+	// change the wrong one.
+	if _, err := database.DatabasePath(); errors.Is(err, database.ErrMultipleDatabases) {
+		slog.Error("cannot choose a Things database", "error", err)
+		os.Exit(1)
+	}
+
+	profiles := make(map[string]*database.DB, len(cfg.Profiles))
+	for name, path := range cfg.Profiles {
+		if _, err := os.Stat(path); err != nil {
+			slog.Error("failed to open database profile", "profile", name, "error", err)
+			os.Exit(1)
+		}
+		profiles[name] = database.Open(path)
+	}
+
+	tokens, err := openTokens(cfg)
+	if err != nil {
+		slog.Error("failed to open token store", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Token == "" && len(tokens.List()) == 0 {
+		slog.Error("no API tokens: set THINGS_API_TOKEN or create one with \"things3-api token create\"")
+		os.Exit(1)
+	}
+
+	templateStore, err := store.Open[models.Template](filepath.Join(cfg.DataDir, "templates.json"))
+	if err != nil {
+		slog.Error("failed to open template store", "error", err)
+		os.Exit(1)
+	}
+	smartListStore, err := store.Open[models.SmartList](filepath.Join(cfg.DataDir, "smart-lists.json"))
+	if err != nil {
+		slog.Error("failed to open smart list store", "error", err)
+		os.Exit(1)
+	}
+	webhookStore, err := store.Open[models.Webhook](filepath.Join(cfg.DataDir, "webhooks.json"))
+	if err != nil {
+		slog.Error("failed to open webhook store", "error", err)
+		os.Exit(1)
+	}
+	deliveryStore, err := webhooks.OpenQueue(filepath.Join(cfg.DataDir, "webhook-deliveries.jsonl"), filepath.Join(cfg.DataDir, "webhook-deliveries.json"))
+	if err != nil {
+		slog.Error("failed to open webhook delivery store", "error", err)
+		os.Exit(1)
+	}
+	idempotencyStore, err := store.Open[middleware.IdempotencyRecord](filepath.Join(cfg.DataDir, "idempotency.json"))
+	if err != nil {
+		slog.Error("failed to open idempotency store", "error", err)
+		os.Exit(1)
+	}
+
+	operationStore, err := store.Open[models.Operation](filepath.Join(cfg.DataDir, "operations.json"))
+	if err != nil {
+		slog.Error("failed to open operation store", "error", err)
+		os.Exit(1)
+	}
+
+	auditLog, err := audit.Open(filepath.Join(cfg.DataDir, "audit", "audit.log"), 10<<20, 5)
+	if err != nil {
+		slog.Error("failed to open audit log", "error", err)
+		os.Exit(1)
+	}
+
+	feed := events.NewFeed(1000)
+	watcher := events.NewWatcher(feed, database.ChangeVersion, database.Snapshot, cfg.EventsInterval)
+	go watcher.Run(context.Background())
+	handlers.SetEventFeed(feed)
+	handlers.SetJournal(operationStore, cfg.UndoRetention)
+	handlers.SetDryRun(cfg.DryRun)
+	handlers.SetURLToken(cfg.ThingsURLToken)
+	database.ConfigureDirectWrites(cfg.DirectWrites, filepath.Join(cfg.DataDir, "snapshots"), applescript.IsThings3Running, cfg.DirectWritesRequireQuit)
+
+	// A readonly build leaves out direct database writes only; writes
+	// through AppleScript and the URL scheme still work.
+	if database.ReadOnlyBuild && cfg.DirectWrites {
+		slog.Warn("THINGS_API_DIRECT_WRITES has no effect in a read-only build")
+	}
+	handlers.SetReadOnly(cfg.ReadOnly)
+
+	backupManager := backups.NewManager(cfg.BackupDir, cfg.BackupKeep)
+	if cfg.BackupAt != "" {
+		go backupManager.Run(context.Background(), cfg.BackupAt)
+	}
+
+	dispatcher := webhooks.NewDispatcher(webhookStore, deliveryStore)
+	feed.AddListener(dispatcher.Enqueue)
+	go dispatcher.Run(context.Background())
+
 	mux := http.NewServeMux()
 
 	// Health (no auth required, handled by middleware exemption)
@@ -44,15 +149,66 @@ func main() {
 	mux.HandleFunc("/areas/", handlers.AreasRouter)
 	mux.HandleFunc("/areas", handlers.AreasRouter)
 
+	// Search
+	mux.HandleFunc("/search", handlers.SearchHandler)
+
+	// Import
+	mux.HandleFunc("/import/", handlers.ImportRouter)
+
+	// Templates
+	templatesRouter := handlers.TemplatesRouter(templateStore)
+	mux.HandleFunc("/templates/", templatesRouter)
+	mux.HandleFunc("/templates", templatesRouter)
+
+	// Smart lists
+	smartListsRouter := handlers.SmartListsRouter(smartListStore)
+	mux.HandleFunc("/smart-lists/", smartListsRouter)
+	mux.HandleFunc("/smart-lists", smartListsRouter)
+
+	// Sync
+	mux.HandleFunc("/sync", handlers.SyncHandler)
+
+	// Change feed
+	mux.HandleFunc("/events", handlers.EventsHandler(feed))
+
+	// Webhooks
+	webhooksRouter := handlers.WebhooksRouter(webhookStore, dispatcher)
+	mux.HandleFunc("/webhooks/", webhooksRouter)
+	mux.HandleFunc("/webhooks", webhooksRouter)
+
+	// Undo journal
+	operationsRouter := handlers.OperationsRouter(operationStore)
+	mux.HandleFunc("/operations/", operationsRouter)
+	mux.HandleFunc("/operations", operationsRouter)
+	mux.HandleFunc("/undo/", handlers.UndoHandler(operationStore))
+
+	// Audit log
+	mux.HandleFunc("/audit", handlers.AuditHandler(auditLog))
+
+	// Backups
+	mux.HandleFunc("/admin/", handlers.AdminRouter(backupManager))
+
+	// Database profiles (read-only)
+	mux.HandleFunc("/profiles/", handlers.ProfilesRouter(profiles))
+	mux.HandleFunc("/profiles", handlers.ProfilesRouter(profiles))
+
 	handler := middleware.Chain(mux,
 		middleware.Recovery(),
 		middleware.Logger(),
 		middleware.MaxBody(1<<20), // 1MB
-		middleware.Auth(cfg.Token),
-		middleware.Things3Check(),
+		middleware.Auth(cfg.Token, tokens, middleware.NewLockout(cfg.AuthMaxFailures, cfg.AuthLockout, cfg.AuthFailuresPerMinute)),
+		middleware.RateLimit(middleware.RateLimits{
+			ReadPerMinute:  cfg.RateLimitRead,
+			WritePerMinute: cfg.RateLimitWrite,
+			MaxConcurrent:  cfg.MaxConcurrent,
+		}),
+		middleware.Audit(auditLog),
+		middleware.ReadOnly(cfg.ReadOnly),
+		middleware.Idempotency(idempotencyStore, cfg.IdempotencyTTL),
+		middleware.Things3Check(cfg.DBPath != ""),
 	)
 
-	slog.Info("starting things3-api", "addr", cfg.Addr())
+	slog.Info("starting things3-api", "addr", cfg.Addr(), "read_only", cfg.ReadOnly)
 	if err := http.ListenAndServe(cfg.Addr(), handler); err != nil {
 		slog.Error("server error", "error", err)
 		os.Exit(1)
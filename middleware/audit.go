@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// Audit returns middleware that appends an entry to the audit log for every
+// write request (anything but GET and HEAD) once it completes, successful or
+// not. Handlers describe the operation through audit.FromContext; responses
+// replayed by Idempotency are marked as such. Must run after Auth and before
+// Idempotency.
+func Audit(log *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			client, ok := auth.ClientFrom(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			rec := &audit.Record{}
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r.WithContext(audit.WithRecord(r.Context(), rec)))
+
+			entry := models.AuditEntry{
+				ID:        store.NewID(),
+				Time:      time.Now().UTC().Format(time.RFC3339Nano),
+				Client:    client.Name,
+				Method:    r.Method,
+				Path:      r.URL.RequestURI(),
+				Operation: rec.Operation,
+				Type:      rec.Type,
+				Targets:   rec.Targets,
+				DryRun:    rec.DryRun,
+				Replayed:  rec.Replayed,
+				Outcome:   models.OutcomeSuccess,
+				Status:    rw.status,
+			}
+			if rw.status >= 400 {
+				entry.Outcome = models.OutcomeFailure
+				var body struct {
+					Error string `json:"error"`
+				}
+				json.Unmarshal(rw.body.Bytes(), &body)
+				entry.Error = body.Error
+			} else if !rec.Replayed {
+				entry.Changes = rec.Changes
+			}
+			if err := log.Append(entry); err != nil {
+				slog.Error("cannot write audit log", "error", err)
+			}
+		})
+	}
+}
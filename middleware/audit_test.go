@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func TestAuditMarksReplays(t *testing.T) {
+	dir := t.TempDir()
+	log, err := audit.Open(filepath.Join(dir, "audit.jsonl"), 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := openIdempotencyRecords(t)
+
+	calls := 0
+	h := Chain(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			rec := audit.FromContext(r.Context())
+			rec.Set("create", "task", "TaskNew000000000000001")
+			rec.Change("title", nil, "Buy milk")
+			w.WriteHeader(http.StatusCreated)
+		}),
+		asClient,
+		Audit(log),
+		Idempotency(records, time.Hour),
+	)
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"Buy milk"}`))
+		req.Header.Set("X-Client", "app")
+		req.Header.Set("Idempotency-Key", "k1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+
+	entries, err := log.Query(models.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d audit entries, want 2", len(entries))
+	}
+	replay, first := entries[0], entries[1]
+	if first.Replayed || len(first.Changes) == 0 {
+		t.Errorf("first entry = %+v, want an unreplayed write with changes", first)
+	}
+	if !replay.Replayed || replay.Operation != "create" || len(replay.Targets) != 1 || len(replay.Changes) != 0 {
+		t.Errorf("replayed entry = %+v, want the original operation and targets and no changes", replay)
+	}
+}
@@ -1,15 +1,19 @@
 package middleware
 
 import (
+	"context"
 	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/egorkaBurkenya/things3-api/applescript"
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/models"
 )
 
 // statusWriter wraps http.ResponseWriter to capture the status code.
@@ -23,6 +27,12 @@ func (sw *statusWriter) WriteHeader(code int) {
 	sw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController, so
+// streaming handlers can flush.
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
+
 // jsonError writes a JSON error response with the given status code.
 func jsonError(w http.ResponseWriter, status int, msg string) {
 	w.Header().Set("Content-Type", "application/json")
@@ -40,9 +50,15 @@ func Chain(handler http.Handler, middlewares ...func(http.Handler) http.Handler)
 }
 
 // Auth returns middleware that validates a Bearer token from the Authorization
-// header. The /health endpoint is exempt from authentication. Token comparison
-// uses constant-time comparison to prevent timing attacks.
-func Auth(token string) func(http.Handler) http.Handler {
+// header against the legacy THINGS_API_TOKEN (if set), which acts as an admin
+// client named "default", and the token store. The authenticated client is
+// stored in the request context and checked against the scope the route
+// requires; area and project restrictions are applied by the handlers.
+// Failed attempts are limited per credential and per client IP (see
+// Lockout) with 429 Too Many Requests. The /health endpoint is exempt from
+// authentication. Token comparison uses constant-time comparison to prevent
+// timing attacks.
+func Auth(token string, tokens *auth.Tokens, lockout *Lockout) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			if r.URL.Path == "/health" {
@@ -51,37 +67,99 @@ func Auth(token string) func(http.Handler) http.Handler {
 			}
 
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
+			ip, cred := clientIP(r), credentialHash(authHeader)
+			if wait := lockout.locked(ip, cred, time.Now()); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+				jsonError(w, http.StatusTooManyRequests, "too many failed authentication attempts")
+				return
+			}
+			unauthorized := func() {
+				lockout.fail(ip, cred, time.Now())
 				jsonError(w, http.StatusUnauthorized, "unauthorized")
+			}
+
+			if authHeader == "" {
+				unauthorized()
 				return
 			}
 
 			const prefix = "Bearer "
 			if !strings.HasPrefix(authHeader, prefix) {
-				jsonError(w, http.StatusUnauthorized, "unauthorized")
+				unauthorized()
 				return
 			}
 
 			provided := authHeader[len(prefix):]
-			if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
-				jsonError(w, http.StatusUnauthorized, "unauthorized")
+			var client *auth.Client
+			if token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1 {
+				client = &auth.Client{Name: auth.DefaultClient, Scopes: []string{models.ScopeAdmin}}
+			} else if tok, ok := tokens.Authenticate(provided); ok {
+				client = auth.ClientFor(tok)
+			} else {
+				unauthorized()
 				return
 			}
+			lockout.succeed(ip, cred, time.Now())
+
+			if l, ok := r.Context().Value(requestLogKey{}).(*requestLog); ok {
+				l.client = client.Name
+			}
+
+			scope := auth.RequiredScope(r.Method, r.URL.Path)
+			if !client.HasScope(scope) {
+				jsonError(w, http.StatusForbidden, "token does not have the "+scope+" scope")
+				return
+			}
+			if client.Restricted() && auth.RequiresFullAccess(r.Method, r.URL.Path) {
+				jsonError(w, http.StatusForbidden, "not available to tokens limited to areas or projects")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithClient(r.Context(), client)))
+		})
+	}
+}
 
+// ReadOnly returns middleware that rejects every request other than GET and
+// HEAD with 403 Forbidden, before it reaches a handler, when readOnly is set
+// or the client's token is read-only. Must run after Auth.
+func ReadOnly(readOnly bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if readOnly {
+				jsonError(w, http.StatusForbidden, "server is in read-only mode")
+				return
+			}
+			if client, ok := auth.ClientFrom(r.Context()); ok && client.ReadOnly {
+				jsonError(w, http.StatusForbidden, "token is read-only")
+				return
+			}
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// requestLog collects request details set by inner middleware for Logger.
+type requestLog struct {
+	client string
+}
+
+type requestLogKey struct{}
+
 // Logger returns middleware that logs every request with method, path, status
-// code, and duration using slog structured logging.
+// code, duration and authenticated client using slog structured logging.
 func Logger() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
 			sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			l := &requestLog{}
 
-			next.ServeHTTP(sw, r)
+			next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), requestLogKey{}, l)))
 
 			duration := time.Since(start)
 			slog.Info("request",
@@ -89,18 +167,29 @@ func Logger() func(http.Handler) http.Handler {
 				"path", r.URL.Path,
 				"status", sw.statusCode,
 				"duration", duration,
+				"client", l.client,
 			)
 		})
 	}
 }
 
 // Things3Check returns middleware that verifies Things 3 is running before
-// processing a request. The /health endpoint is exempt from this check.
+// processing a request. The /health endpoint, backups (/admin) and database
+// profiles (/profiles), which read database files directly, are exempt from
+// this check. With dbReads set (THINGS_DB_PATH names the database), so are
+// GET and HEAD requests served from the database or the server's own stores
+// (see readsWithoutThings), so they work without Things, e.g. against a
+// fixture database on Linux.
 // Returns 503 Service Unavailable if Things 3 is not running.
-func Things3Check() func(http.Handler) http.Handler {
+func Things3Check(dbReads bool) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/health" {
+			if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/admin/") ||
+				r.URL.Path == "/profiles" || strings.HasPrefix(r.URL.Path, "/profiles/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if dbReads && (r.Method == http.MethodGet || r.Method == http.MethodHead) && readsWithoutThings(r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -120,6 +209,24 @@ func Things3Check() func(http.Handler) http.Handler {
 	}
 }
 
+// readsWithoutThings reports whether reads of path never go through
+// AppleScript: search, sync, the change feed, checklists, smart lists,
+// templates, webhooks, the undo journal and the audit log.
+func readsWithoutThings(path string) bool {
+	for _, prefix := range []string{"/search", "/sync", "/events", "/smart-lists", "/templates", "/webhooks", "/operations", "/audit"} {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	// /tasks/{id}/checklist and /tasks/{id}/checklist/{item}
+	rest, ok := strings.CutPrefix(path, "/tasks/")
+	if !ok {
+		return false
+	}
+	_, suffix, _ := strings.Cut(rest, "/")
+	return suffix == "checklist" || strings.HasPrefix(suffix, "checklist/")
+}
+
 // MaxBody returns middleware that limits the request body size to the given
 // number of bytes. Requests exceeding the limit will receive an error when
 // the handler attempts to read beyond the allowed size.
@@ -0,0 +1,123 @@
+package middleware_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/database"
+	"github.com/egorkaBurkenya/things3-api/handlers"
+	"github.com/egorkaBurkenya/things3-api/middleware"
+)
+
+// fixtureDB creates a Things database from the database package's version 26
+// fixture and returns its path.
+func fixtureDB(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	schema, err := os.ReadFile(filepath.Join("..", "database", "testdata", "things-v26.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "main.sqlite")
+	cmd := exec.Command("sqlite3", path)
+	cmd.Stdin = strings.NewReader(string(schema))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sqlite3: %v: %s", err, out)
+	}
+	return path
+}
+
+// TestThings3CheckDBReads serves reads from a fixture database, as with
+// THINGS_DB_PATH, while Things is not running.
+func TestThings3CheckDBReads(t *testing.T) {
+	if _, err := exec.LookPath("osascript"); err == nil {
+		t.Skip("Things may be running on this Mac")
+	}
+	path := fixtureDB(t)
+	database.SetPath(path)
+	t.Cleanup(func() { database.SetPath("") })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", handlers.SearchHandler)
+	mux.HandleFunc("/tasks/", handlers.TasksRouter)
+	mux.HandleFunc("/profiles/", handlers.ProfilesRouter(map[string]*database.DB{"copy": database.Open(path)}))
+
+	tests := []struct {
+		name    string
+		dbReads bool
+		method  string
+		target  string
+		status  int
+		want    string // substring of the body
+	}{
+		{"search", true, http.MethodGet, "/search?q=seeds", http.StatusOK, `"id":"TaskSeeds0000000000001"`},
+		{"checklist", true, http.MethodGet, "/tasks/TaskSeeds0000000000001/checklist", http.StatusOK, `"title":"Basil"`},
+		{"checklist item", true, http.MethodGet, "/tasks/TaskSeeds0000000000001/checklist/ItemTomato000000000001", http.StatusOK, `"title":"Tomatoes"`},
+		{"profile", false, http.MethodGet, "/profiles/copy/tasks/TaskShed00000000000001", http.StatusOK, `"title":"Paint the shed"`},
+		{"without THINGS_DB_PATH", false, http.MethodGet, "/search?q=seeds", http.StatusServiceUnavailable, "Things 3 is not running"},
+		{"AppleScript read", true, http.MethodGet, "/tasks/inbox", http.StatusServiceUnavailable, "Things 3 is not running"},
+		{"write", true, http.MethodPost, "/tasks/TaskSeeds0000000000001/checklist", http.StatusServiceUnavailable, "Things 3 is not running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := middleware.Things3Check(tt.dbReads)(mux)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{"title":"x"}`)))
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if !json.Valid(rec.Body.Bytes()) || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body = %s, want %s", rec.Body, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverRO      bool
+		tokenRO       bool
+		authenticated bool
+		method        string
+		want          int
+	}{
+		{"GET", true, true, true, http.MethodGet, http.StatusOK},
+		{"HEAD", true, true, true, http.MethodHead, http.StatusOK},
+		{"POST", false, false, true, http.MethodPost, http.StatusOK},
+		{"POST, read-only server", true, false, true, http.MethodPost, http.StatusForbidden},
+		{"PUT, read-only server", true, false, true, http.MethodPut, http.StatusForbidden},
+		{"PATCH, read-only token", false, true, true, http.MethodPatch, http.StatusForbidden},
+		{"DELETE, read-only token", false, true, true, http.MethodDelete, http.StatusForbidden},
+		{"OPTIONS, read-only token", false, true, true, http.MethodOptions, http.StatusForbidden},
+		{"POST without a client", false, false, false, http.MethodPost, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			h := middleware.ReadOnly(tt.serverRO)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+			}))
+			r := httptest.NewRequest(tt.method, "/tasks", nil)
+			if tt.authenticated {
+				r = r.WithContext(auth.WithClient(r.Context(), &auth.Client{Name: "app", ReadOnly: tt.tokenRO}))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.want || called != (tt.want == http.StatusOK) {
+				t.Errorf("status = %d, handler called %v; want %d", rec.Code, called, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// IdempotencyRecord is a stored response to a POST request made with an
+// Idempotency-Key header. ID is the key scoped to the client that sent it.
+// Operation, Type and Targets are from the request's audit record, so that
+// replays are audited as the same operation.
+type IdempotencyRecord struct {
+	ID          string      `json:"id"`
+	Client      string      `json:"client"`
+	Key         string      `json:"key"`
+	Fingerprint string      `json:"fingerprint"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        string      `json:"body"`
+	Operation   string      `json:"operation,omitempty"`
+	Type        string      `json:"type,omitempty"`
+	Targets     []string    `json:"targets,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
+
+// maxIdempotencyKeyLen bounds the Idempotency-Key header value.
+const maxIdempotencyKeyLen = 255
+
+// Idempotency returns middleware that honors the Idempotency-Key header on
+// POST requests. The first response for a key (unless it is a 5xx) is stored
+// for ttl and replayed for later requests with the same key, marked with an
+// Idempotent-Replayed header. Reusing a key for a different method, path or
+// body returns 409 Conflict, as does a retry while the first request is
+// still being processed. Keys are scoped to the authenticated client, so one
+// client can never replay another's response. Must run after Auth.
+func Idempotency(records *store.Collection[IdempotencyRecord], ttl time.Duration) func(http.Handler) http.Handler {
+	var (
+		mu        sync.Mutex
+		inFlight  = make(map[string]bool)
+		lastPrune time.Time
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !validIdempotencyKey(key) {
+				jsonError(w, http.StatusBadRequest, "Idempotency-Key must be 1-255 printable ASCII characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				jsonError(w, http.StatusBadRequest, "invalid request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			var client string
+			if c, ok := auth.ClientFrom(r.Context()); ok {
+				client = c.Name
+			}
+			// Keys are printable ASCII, so the NUL separator keeps IDs unique.
+			id := client + "\x00" + key
+
+			if replayed(w, r, records, id, fingerprint, ttl) {
+				return
+			}
+
+			mu.Lock()
+			if inFlight[id] {
+				mu.Unlock()
+				jsonError(w, http.StatusConflict, "a request with this Idempotency-Key is still being processed")
+				return
+			}
+			inFlight[id] = true
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				delete(inFlight, id)
+				mu.Unlock()
+			}()
+
+			// The first request may have finished between the check above
+			// and taking the key.
+			if replayed(w, r, records, id, fingerprint, ttl) {
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rw, r)
+			if rw.status >= 500 {
+				return
+			}
+
+			rec := IdempotencyRecord{
+				ID:          id,
+				Client:      client,
+				Key:         key,
+				Fingerprint: fingerprint,
+				Status:      rw.status,
+				Header:      w.Header().Clone(),
+				Body:        rw.body.String(),
+				CreatedAt:   time.Now().UTC(),
+			}
+			if a := audit.FromContext(r.Context()); a != nil {
+				rec.Operation, rec.Type, rec.Targets = a.Operation, a.Type, a.Targets
+			}
+			if err := records.Put(id, rec); err != nil {
+				slog.Error("cannot store idempotency record", "error", err)
+			}
+
+			mu.Lock()
+			prune := time.Since(lastPrune) > time.Hour
+			if prune {
+				lastPrune = time.Now()
+			}
+			mu.Unlock()
+			if prune {
+				pruneIdempotencyRecords(records, ttl)
+			}
+		})
+	}
+}
+
+// replayed writes the stored response for id, or 409 if it was for a
+// different request, and reports whether there was one. A replay is marked
+// in the request's audit record.
+func replayed(w http.ResponseWriter, r *http.Request, records *store.Collection[IdempotencyRecord], id, fingerprint string, ttl time.Duration) bool {
+	rec, ok := records.Get(id)
+	if !ok || time.Since(rec.CreatedAt) >= ttl {
+		return false
+	}
+	if rec.Fingerprint != fingerprint {
+		jsonError(w, http.StatusConflict, "Idempotency-Key was already used for a different request")
+		return true
+	}
+	audit.FromContext(r.Context()).MarkReplay(rec.Operation, rec.Type, rec.Targets)
+	replay(w, rec)
+	return true
+}
+
+// replay writes a stored response.
+func replay(w http.ResponseWriter, rec IdempotencyRecord) {
+	for k, v := range rec.Header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(rec.Status)
+	io.WriteString(w, rec.Body)
+}
+
+// pruneIdempotencyRecords deletes records older than ttl. Records stored
+// before keys were scoped to clients have no ID and are keyed by Key.
+func pruneIdempotencyRecords(records *store.Collection[IdempotencyRecord], ttl time.Duration) {
+	for _, rec := range records.List() {
+		if time.Since(rec.CreatedAt) < ttl {
+			continue
+		}
+		id := rec.ID
+		if id == "" {
+			id = rec.Key
+		}
+		if _, err := records.Delete(id); err != nil {
+			slog.Error("cannot prune idempotency record", "error", err)
+			return
+		}
+	}
+}
+
+// requestFingerprint identifies a request by method, path, query and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLen {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// recordingWriter passes a response through while keeping a copy of its
+// status and body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
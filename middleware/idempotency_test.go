@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/audit"
+	"github.com/egorkaBurkenya/things3-api/auth"
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+// asClient stands in for Auth, authenticating every request as the client
+// named in the X-Client header, read-only if X-Read-Only is set.
+func asClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := &auth.Client{Name: r.Header.Get("X-Client"), ReadOnly: r.Header.Get("X-Read-Only") != ""}
+		next.ServeHTTP(w, r.WithContext(auth.WithClient(r.Context(), c)))
+	})
+}
+
+func openIdempotencyRecords(t *testing.T) *store.Collection[IdempotencyRecord] {
+	t.Helper()
+	records, err := store.Open[IdempotencyRecord](filepath.Join(t.TempDir(), "idempotency.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+// countingHandler creates a task per call, or fails with status when set.
+type countingHandler struct {
+	calls  int
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, `{"id":"TaskNew000000000000001"}`)
+}
+
+type idempotentRequest struct {
+	client, key, body string
+}
+
+func (req idempotentRequest) send(h http.Handler) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(req.body))
+	r.Header.Set("X-Client", req.client)
+	if req.key != "" {
+		r.Header.Set("Idempotency-Key", req.key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotency(t *testing.T) {
+	first := idempotentRequest{client: "app", key: "k1", body: `{"title":"Buy milk"}`}
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		status     int // of the handler
+		retry      idempotentRequest
+		wantCalls  int
+		wantStatus int
+		replayed   bool
+	}{
+		{name: "same body is replayed", retry: first, wantCalls: 1, wantStatus: http.StatusCreated, replayed: true},
+		{name: "different body conflicts", retry: idempotentRequest{client: "app", key: "k1", body: `{"title":"Buy eggs"}`}, wantCalls: 1, wantStatus: http.StatusConflict},
+		{name: "keys are scoped to the client", retry: idempotentRequest{client: "bot", key: "k1", body: first.body}, wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "other key", retry: idempotentRequest{client: "app", key: "k2", body: first.body}, wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "no key", retry: idempotentRequest{client: "app", body: first.body}, wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "expired", ttl: time.Nanosecond, retry: first, wantCalls: 2, wantStatus: http.StatusCreated},
+		{name: "server errors are not stored", status: http.StatusInternalServerError, retry: first, wantCalls: 2, wantStatus: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ttl := tt.ttl
+			if ttl == 0 {
+				ttl = time.Hour
+			}
+			next := &countingHandler{status: tt.status}
+			h := Chain(next, asClient, Idempotency(openIdempotencyRecords(t), ttl))
+
+			w1 := first.send(h)
+			w2 := tt.retry.send(h)
+			if next.calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", next.calls, tt.wantCalls)
+			}
+			if w2.Code != tt.wantStatus {
+				t.Errorf("retry status = %d, want %d", w2.Code, tt.wantStatus)
+			}
+			if got := w2.Header().Get("Idempotent-Replayed") == "true"; got != tt.replayed {
+				t.Errorf("retry replayed = %v, want %v", got, tt.replayed)
+			}
+			if tt.replayed && w2.Body.String() != w1.Body.String() {
+				t.Errorf("replayed body = %q, want %q", w2.Body, w1.Body)
+			}
+		})
+	}
+}
+
+func TestIdempotencyInvalidKey(t *testing.T) {
+	next := &countingHandler{}
+	h := Chain(next, asClient, Idempotency(openIdempotencyRecords(t), time.Hour))
+	w := idempotentRequest{client: "app", key: strings.Repeat("k", maxIdempotencyKeyLen+1)}.send(h)
+	if w.Code != http.StatusBadRequest || next.calls != 0 {
+		t.Errorf("status = %d after %d calls, want 400 without calling the handler", w.Code, next.calls)
+	}
+}
+
+// TestIdempotencyReadOnly runs the middleware in the order main uses: a
+// write rejected by ReadOnly is audited as a failure and never stored.
+func TestIdempotencyReadOnly(t *testing.T) {
+	log, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := openIdempotencyRecords(t)
+	next := &countingHandler{}
+	h := Chain(next, asClient, Audit(log), ReadOnly(false), Idempotency(records, time.Hour))
+
+	r := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(`{"title":"Buy milk"}`))
+	r.Header.Set("X-Client", "dashboard")
+	r.Header.Set("X-Read-Only", "1")
+	r.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusForbidden || next.calls != 0 {
+		t.Errorf("status = %d after %d calls, want 403 without calling the handler", w.Code, next.calls)
+	}
+	if n := len(records.List()); n != 0 {
+		t.Errorf("%d idempotency records stored, want none", n)
+	}
+	entries, err := log.Query(models.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Outcome != models.OutcomeFailure || entries[0].Client != "dashboard" {
+		t.Errorf("audit entries = %+v, want one failure by dashboard", entries)
+	}
+}
@@ -0,0 +1,311 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/auth"
+)
+
+// RateLimits configures RateLimit. Budgets are requests per minute per
+// client; zero disables a limit.
+type RateLimits struct {
+	ReadPerMinute  int
+	WritePerMinute int
+	MaxConcurrent  int
+}
+
+// bucket is a token bucket holding up to limit tokens, refilled at
+// limit per minute.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and removes one token if available. It returns
+// whether a token was taken, the tokens left and how long until the next
+// token.
+func (b *bucket) take(limit int, now time.Time) (bool, int, time.Duration) {
+	perSecond := float64(limit) / 60
+	if b.last.IsZero() {
+		b.tokens = float64(limit)
+	} else {
+		b.tokens = math.Min(float64(limit), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+		return false, 0, wait
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// wait returns how long until the bucket has a token, without taking one.
+func (b *bucket) wait(limit int, now time.Time) time.Duration {
+	if b.last.IsZero() || limit == 0 {
+		return 0
+	}
+	perSecond := float64(limit) / 60
+	tokens := b.tokens + now.Sub(b.last).Seconds()*perSecond
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / perSecond * float64(time.Second))
+}
+
+// untilFull returns how long until the bucket is full again.
+func (b *bucket) untilFull(limit int) time.Duration {
+	missing := float64(limit) - b.tokens
+	return time.Duration(missing / (float64(limit) / 60) * float64(time.Second))
+}
+
+// RateLimit returns middleware that limits each authenticated client to a
+// token bucket of reads (GET, HEAD) and of writes per minute, and to
+// MaxConcurrent requests in progress at once. Every limited response carries
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers; requests
+// over a limit get 429 Too Many Requests with Retry-After. /events streams
+// are not counted towards the concurrency cap. Must run after Auth.
+func RateLimit(limits RateLimits) func(http.Handler) http.Handler {
+	var (
+		mu       sync.Mutex
+		buckets  = make(map[string]*bucket)
+		inFlight = make(map[string]int)
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, ok := auth.ClientFrom(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			kind, limit := "write", limits.WritePerMinute
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				kind, limit = "read", limits.ReadPerMinute
+			}
+			concurrent := limits.MaxConcurrent > 0 && r.URL.Path != "/events"
+
+			mu.Lock()
+			if limit > 0 {
+				key := client.Name + "\x00" + kind
+				b := buckets[key]
+				if b == nil {
+					b = &bucket{}
+					buckets[key] = b
+				}
+				allowed, remaining, wait := b.take(limit, time.Now())
+				w.Header().Set("RateLimit-Limit", strconv.Itoa(limit))
+				w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+				w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(b.untilFull(limit))))
+				if !allowed {
+					mu.Unlock()
+					w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+					jsonError(w, http.StatusTooManyRequests, "rate limit exceeded")
+					return
+				}
+			}
+			if concurrent {
+				if inFlight[client.Name] >= limits.MaxConcurrent {
+					mu.Unlock()
+					w.Header().Set("Retry-After", "1")
+					jsonError(w, http.StatusTooManyRequests, "too many concurrent requests")
+					return
+				}
+				inFlight[client.Name]++
+			}
+			mu.Unlock()
+
+			if concurrent {
+				defer func() {
+					mu.Lock()
+					inFlight[client.Name]--
+					mu.Unlock()
+				}()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// maxLockoutEntries bounds the credentials and IPs a Lockout tracks before
+// stale entries are pruned.
+const maxLockoutEntries = 10000
+
+// maxKnownCredentials bounds the credentials remembered per IP.
+const maxKnownCredentials = 32
+
+// Lockout limits failed authentication. A credential that fails maxFailures
+// times from one IP within duration is locked out there for duration; other
+// credentials from the same IP are not affected. Each IP may also fail at
+// most failuresPerMinute times a minute; past that, its requests are
+// refused without being checked, except with credentials that already
+// authenticated from it, so a client with a valid token keeps working while
+// another process on the same host sends bad ones.
+type Lockout struct {
+	mu                sync.Mutex
+	maxFailures       int
+	duration          time.Duration
+	failuresPerMinute int
+	credentials       map[string]*lockoutEntry // IP + "\x00" + credential hash
+	ips               map[string]*ipFailures
+}
+
+type lockoutEntry struct {
+	failures    int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// ipFailures is the failed-attempt budget of one IP and the credentials
+// that authenticated from it, with the time they last did.
+type ipFailures struct {
+	budget bucket
+	known  map[string]time.Time
+}
+
+// NewLockout returns a Lockout. A maxFailures of zero disables locking out
+// credentials; a failuresPerMinute of zero disables the per-IP limit.
+func NewLockout(maxFailures int, duration time.Duration, failuresPerMinute int) *Lockout {
+	return &Lockout{
+		maxFailures:       maxFailures,
+		duration:          duration,
+		failuresPerMinute: failuresPerMinute,
+		credentials:       make(map[string]*lockoutEntry),
+		ips:               make(map[string]*ipFailures),
+	}
+}
+
+// credentialHash identifies a presented credential without keeping it.
+func credentialHash(credential string) string {
+	sum := sha256.Sum256([]byte(credential))
+	return hex.EncodeToString(sum[:])
+}
+
+// locked returns how long requests with the credential hash cred from ip
+// are refused, or zero.
+func (l *Lockout) locked(ip, cred string, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e := l.credentials[ip+"\x00"+cred]; e != nil && now.Before(e.lockedUntil) {
+		return e.lockedUntil.Sub(now)
+	}
+	if f := l.ips[ip]; f != nil && l.failuresPerMinute > 0 {
+		if _, ok := f.known[cred]; !ok {
+			return f.budget.wait(l.failuresPerMinute, now)
+		}
+	}
+	return 0
+}
+
+// fail records a failed attempt with the credential hash cred from ip.
+func (l *Lockout) fail(ip, cred string, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.credentials) > maxLockoutEntries || len(l.ips) > maxLockoutEntries {
+		l.prune(now)
+	}
+
+	if l.failuresPerMinute > 0 {
+		f := l.ipFailures(ip)
+		f.budget.take(l.failuresPerMinute, now)
+	}
+	if l.maxFailures == 0 {
+		return
+	}
+	key := ip + "\x00" + cred
+	e := l.credentials[key]
+	if e == nil || now.Sub(e.first) > l.duration {
+		e = &lockoutEntry{first: now}
+		l.credentials[key] = e
+	}
+	e.failures++
+	if e.failures >= l.maxFailures {
+		e.lockedUntil = now.Add(l.duration)
+	}
+}
+
+// succeed clears failed attempts with the credential hash cred from ip and
+// remembers that it authenticated from there.
+func (l *Lockout) succeed(ip, cred string, now time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.credentials, ip+"\x00"+cred)
+	if l.failuresPerMinute == 0 {
+		return
+	}
+	f := l.ipFailures(ip)
+	if _, ok := f.known[cred]; !ok && len(f.known) >= maxKnownCredentials {
+		var oldest string
+		for c, t := range f.known {
+			if oldest == "" || t.Before(f.known[oldest]) {
+				oldest = c
+			}
+		}
+		delete(f.known, oldest)
+	}
+	f.known[cred] = now
+}
+
+// ipFailures returns the entry for ip, creating it. Caller holds mu.
+func (l *Lockout) ipFailures(ip string) *ipFailures {
+	f := l.ips[ip]
+	if f == nil {
+		f = &ipFailures{known: make(map[string]time.Time)}
+		l.ips[ip] = f
+	}
+	return f
+}
+
+// prune drops credentials that are neither counting failures nor locked, and
+// IPs whose failure budget is full and with no recently used credentials.
+// Caller holds mu.
+func (l *Lockout) prune(now time.Time) {
+	for key, e := range l.credentials {
+		if now.Sub(e.first) > l.duration && now.After(e.lockedUntil) {
+			delete(l.credentials, key)
+		}
+	}
+	for ip, f := range l.ips {
+		for c, t := range f.known {
+			if now.Sub(t) > l.duration {
+				delete(f.known, c)
+			}
+		}
+		if len(f.known) == 0 && now.Sub(f.budget.last) >= f.budget.untilFull(l.failuresPerMinute) {
+			delete(l.ips, ip)
+		}
+	}
+}
+
+// clientIP returns the IP address of the request's peer.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
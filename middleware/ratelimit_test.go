@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockoutPerCredential(t *testing.T) {
+	l := NewLockout(3, time.Minute, 0)
+	now := time.Now()
+	bad, good := credentialHash("Bearer wrong"), credentialHash("Bearer right")
+
+	for i := 0; i < 3; i++ {
+		if wait := l.locked("127.0.0.1", bad, now); wait != 0 {
+			t.Fatalf("locked after %d failures", i)
+		}
+		l.fail("127.0.0.1", bad, now)
+	}
+	if wait := l.locked("127.0.0.1", bad, now); wait != time.Minute {
+		t.Fatalf("failing credential: wait %v, want 1m", wait)
+	}
+	if wait := l.locked("127.0.0.1", good, now); wait != 0 {
+		t.Fatalf("another credential from the same IP is locked out for %v", wait)
+	}
+	if wait := l.locked("10.0.0.2", bad, now); wait != 0 {
+		t.Fatalf("the failing credential is locked out from another IP for %v", wait)
+	}
+	if wait := l.locked("127.0.0.1", bad, now.Add(time.Minute)); wait != 0 {
+		t.Fatalf("still locked out after the lockout: %v", wait)
+	}
+}
+
+func TestLockoutPerIP(t *testing.T) {
+	l := NewLockout(0, time.Minute, 2)
+	now := time.Now()
+	known, other := credentialHash("Bearer known"), credentialHash("Bearer other")
+	l.succeed("127.0.0.1", known, now)
+
+	for i := 0; i < 2; i++ {
+		l.fail("127.0.0.1", credentialHash("Bearer guess"+string(rune('a'+i))), now)
+	}
+	if wait := l.locked("127.0.0.1", other, now); wait <= 0 {
+		t.Fatal("an untried credential is accepted after the IP's failure budget is spent")
+	}
+	if wait := l.locked("127.0.0.1", known, now); wait != 0 {
+		t.Fatalf("a credential that authenticated from the IP is refused for %v", wait)
+	}
+	if wait := l.locked("10.0.0.2", other, now); wait != 0 {
+		t.Fatalf("another IP is refused for %v", wait)
+	}
+	if wait := l.locked("127.0.0.1", other, now.Add(30*time.Second)); wait != 0 {
+		t.Fatalf("the budget has not refilled after 30s: %v", wait)
+	}
+}
+
+func TestLockoutDisabled(t *testing.T) {
+	l := NewLockout(0, time.Minute, 0)
+	now := time.Now()
+	cred := credentialHash("Bearer wrong")
+	for i := 0; i < 100; i++ {
+		l.fail("127.0.0.1", cred, now)
+	}
+	if wait := l.locked("127.0.0.1", cred, now); wait != 0 {
+		t.Fatalf("disabled lockout refuses for %v", wait)
+	}
+	var nilLockout *Lockout
+	if wait := nilLockout.locked("127.0.0.1", cred, now); wait != 0 {
+		t.Fatalf("nil lockout refuses for %v", wait)
+	}
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Audit outcomes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// AuditEntry records one write request: who made it, what it targeted, which
+// fields it changed and how it ended.
+type AuditEntry struct {
+	ID        string                 `json:"id"`
+	Time      string                 `json:"time"`
+	Client    string                 `json:"client"`
+	Method    string                 `json:"method"`
+	Path      string                 `json:"path"`
+	Operation string                 `json:"operation,omitempty"`
+	Type      string                 `json:"type,omitempty"`
+	Targets   []string               `json:"targets,omitempty"`
+	Changes   map[string]AuditChange `json:"changes,omitempty"`
+	DryRun    bool                   `json:"dry_run,omitempty"`
+	Replayed  bool                   `json:"replayed,omitempty"`
+	Outcome   string                 `json:"outcome"`
+	Status    int                    `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// AuditChange is the value of a field before and after a write.
+type AuditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditFilter selects audit entries. Empty fields match everything.
+type AuditFilter struct {
+	Client    string
+	Operation string
+	Type      string
+	Target    string
+	Outcome   string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// ParseAuditFilter reads an AuditFilter from query parameters.
+func ParseAuditFilter(get func(string) string) (AuditFilter, error) {
+	f := AuditFilter{
+		Client:    get("client"),
+		Operation: get("operation"),
+		Type:      get("type"),
+		Target:    get("target"),
+		Outcome:   get("outcome"),
+		Limit:     100,
+	}
+	if f.Outcome != "" && f.Outcome != OutcomeSuccess && f.Outcome != OutcomeFailure {
+		return f, fmt.Errorf("outcome must be one of: success, failure")
+	}
+	for _, p := range []struct {
+		name string
+		dest *time.Time
+	}{{"since", &f.Since}, {"until", &f.Until}} {
+		if v := get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+			}
+			*p.dest = t
+		}
+	}
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			return f, fmt.Errorf("limit must be between 1 and 1000")
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
+
+// Matches reports whether e passes the filter (ignoring Limit).
+func (f AuditFilter) Matches(e AuditEntry) bool {
+	if f.Client != "" && e.Client != f.Client ||
+		f.Operation != "" && e.Operation != f.Operation ||
+		f.Type != "" && e.Type != f.Type ||
+		f.Outcome != "" && e.Outcome != f.Outcome {
+		return false
+	}
+	if f.Target != "" && !containsString(e.Targets, f.Target) {
+		return false
+	}
+	if !f.Since.IsZero() || !f.Until.IsZero() {
+		t, err := time.Parse(time.RFC3339, e.Time)
+		if err != nil {
+			return false
+		}
+		if !f.Since.IsZero() && t.Before(f.Since) || !f.Until.IsZero() && !t.Before(f.Until) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+package models
+
+// What caused a backup to be made.
+const (
+	BackupManual     = "manual"
+	BackupScheduled  = "scheduled"
+	BackupPreRestore = "pre-restore"
+)
+
+// Backup is a copy of the Things database in the backup directory.
+type Backup struct {
+	Name      string `json:"name"`
+	Trigger   string `json:"trigger"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// Kinds of script shown in a dry-run preview.
+const (
+	ScriptAppleScript = "applescript"
+	ScriptURL         = "url"
+	ScriptSQL         = "sql"
+)
+
+// ScriptPreview is a script a write would run: AppleScript source, a
+// things:/// URL (auth token redacted) or SQLite statements.
+type ScriptPreview struct {
+	Type   string `json:"type"`
+	Script string `json:"script"`
+}
+
+// DryRunResult is returned instead of the usual response when a write is
+// made with dry_run. Result is the item as it would be after the write;
+// Resolved maps project and area names in the request to their IDs.
+type DryRunResult struct {
+	DryRun    bool              `json:"dry_run"`
+	Operation string            `json:"operation"`
+	Result    any               `json:"result"`
+	Resolved  map[string]string `json:"resolved,omitempty"`
+	Scripts   []ScriptPreview   `json:"scripts,omitempty"`
+}
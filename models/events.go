@@ -0,0 +1,47 @@
+package models
+
+// Change feed item types.
+const (
+	ItemTypeTask          = "task"
+	ItemTypeProject       = "project"
+	ItemTypeArea          = "area"
+	ItemTypeChecklistItem = "checklist_item"
+)
+
+// Change feed actions.
+const (
+	ActionCreated   = "created"
+	ActionUpdated   = "updated"
+	ActionCompleted = "completed"
+	ActionDeleted   = "deleted"
+)
+
+// Change feed event sources.
+const (
+	SourceAPI    = "api"    // a write made through this API
+	SourceThings = "things" // a change detected in the Things database
+)
+
+// ItemVersion is the change-tracking state of one item in the Things
+// database. Modified is the item's userModificationDate (0 for areas).
+type ItemVersion struct {
+	Type     string
+	ID       string
+	Title    string
+	Status   string
+	TaskID   string // checklist items only
+	Modified float64
+}
+
+// Event is one entry in the change feed.
+type Event struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Type   string `json:"type"`
+	ItemID string `json:"item_id"`
+	Title  string `json:"title,omitempty"`
+	Status string `json:"status,omitempty"`
+	TaskID string `json:"task_id,omitempty"`
+	Source string `json:"source"`
+	Time   string `json:"time"`
+}
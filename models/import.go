@@ -0,0 +1,179 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+// maxImportItems mirrors the Things URL scheme limit of 250 items per call.
+const maxImportItems = 250
+
+// ImportThingsJSONRequest is a list of top-level items in the Things JSON format.
+type ImportThingsJSONRequest []thingsurl.Item
+
+// ImportedItem describes an item created or updated by a Things JSON import.
+// ID is empty if the created item could not be found in the database in time.
+type ImportedItem struct {
+	ID        string `json:"id,omitempty"`
+	Type      string `json:"type"`
+	Operation string `json:"operation"`
+	Title     string `json:"title,omitempty"`
+}
+
+func (r ImportThingsJSONRequest) Validate() error {
+	if len(r) == 0 {
+		return fmt.Errorf("at least one item is required")
+	}
+	count := 0
+	for i, item := range r {
+		path := fmt.Sprintf("[%d]", i)
+		if item.Type != thingsurl.TypeToDo && item.Type != thingsurl.TypeProject {
+			return fmt.Errorf("%s.type must be one of: to-do, project", path)
+		}
+		if err := validateImportItem(item, path, true, &count); err != nil {
+			return err
+		}
+	}
+	if count > maxImportItems {
+		return fmt.Errorf("maximum %d items allowed", maxImportItems)
+	}
+	return nil
+}
+
+// HasUpdates reports whether any top-level item uses the update operation,
+// which requires a Things URL auth token.
+func (r ImportThingsJSONRequest) HasUpdates() bool {
+	for _, item := range r {
+		if item.Operation == thingsurl.OperationUpdate {
+			return true
+		}
+	}
+	return false
+}
+
+// validateImportItem validates one item and its children. Only top-level
+// items may carry an operation or ID.
+func validateImportItem(item thingsurl.Item, path string, topLevel bool, count *int) error {
+	*count++
+	a := item.Attributes
+
+	switch item.Operation {
+	case "", thingsurl.OperationCreate:
+		if item.ID != "" {
+			return fmt.Errorf("%s.id is only allowed with operation update", path)
+		}
+		if a.Title == "" {
+			return fmt.Errorf("%s.attributes.title is required", path)
+		}
+	case thingsurl.OperationUpdate:
+		if !topLevel {
+			return fmt.Errorf("%s.operation is only allowed on top-level items", path)
+		}
+		if err := ValidateThingsID(item.ID); err != nil {
+			return fmt.Errorf("%s.id: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s.operation must be one of: create, update", path)
+	}
+
+	if len(a.Title) > 1000 {
+		return fmt.Errorf("%s.attributes.title must be under 1000 characters", path)
+	}
+	if len(a.Notes) > 10000 || len(a.PrependNotes) > 10000 || len(a.AppendNotes) > 10000 {
+		return fmt.Errorf("%s.attributes.notes must be under 10000 characters", path)
+	}
+
+	switch item.Type {
+	case thingsurl.TypeToDo:
+		if len(a.Items) > 0 || a.Area != "" || a.AreaID != "" {
+			return fmt.Errorf("%s: to-do does not accept items or area", path)
+		}
+		if !topLevel && (a.List != "" || a.ListID != "" || a.Heading != "" || a.HeadingID != "") {
+			return fmt.Errorf("%s: nested to-do does not accept list or heading", path)
+		}
+		if err := validateImportSchedule(a, path); err != nil {
+			return err
+		}
+		checklist := [][]thingsurl.Item{a.ChecklistItems, a.PrependChecklistItems, a.AppendChecklistItems}
+		total := 0
+		for _, items := range checklist {
+			total += len(items)
+			for j, ci := range items {
+				ciPath := fmt.Sprintf("%s.checklist-items[%d]", path, j)
+				if ci.Type != thingsurl.TypeChecklistItem {
+					return fmt.Errorf("%s.type must be checklist-item", ciPath)
+				}
+				if err := validateImportItem(ci, ciPath, false, count); err != nil {
+					return err
+				}
+			}
+		}
+		if total > 100 {
+			return fmt.Errorf("%s: maximum 100 checklist items allowed", path)
+		}
+	case thingsurl.TypeProject:
+		if !topLevel {
+			return fmt.Errorf("%s: projects cannot be nested", path)
+		}
+		if len(a.ChecklistItems) > 0 || len(a.PrependChecklistItems) > 0 || len(a.AppendChecklistItems) > 0 {
+			return fmt.Errorf("%s: project does not accept checklist items", path)
+		}
+		if err := validateImportSchedule(a, path); err != nil {
+			return err
+		}
+		for j, child := range a.Items {
+			childPath := fmt.Sprintf("%s.items[%d]", path, j)
+			if child.Type != thingsurl.TypeToDo && child.Type != thingsurl.TypeHeading {
+				return fmt.Errorf("%s.type must be one of: to-do, heading", childPath)
+			}
+			if err := validateImportItem(child, childPath, false, count); err != nil {
+				return err
+			}
+		}
+	case thingsurl.TypeHeading, thingsurl.TypeChecklistItem:
+		if topLevel {
+			return fmt.Errorf("%s.type %s is only allowed inside another item", path, item.Type)
+		}
+		if a.Notes != "" || a.When != "" || a.Deadline != "" || len(a.Tags) > 0 || len(a.Items) > 0 || len(a.ChecklistItems) > 0 {
+			return fmt.Errorf("%s: %s only accepts title and status attributes", path, item.Type)
+		}
+	}
+	return nil
+}
+
+// importReminderPattern matches "when" values with a reminder time, e.g. "2026-03-01@18:30".
+var importReminderPattern = regexp.MustCompile(`^(today|tomorrow|evening|\d{4}-\d{2}-\d{2})@\d{1,2}:\d{2}(am|pm)?$`)
+
+func validateImportSchedule(a thingsurl.Attributes, path string) error {
+	if a.When != "" && !isValidWhen(a.When) && !importReminderPattern.MatchString(a.When) {
+		return fmt.Errorf("%s.attributes.when must be one of: today, evening, tomorrow, someday, anytime, or a date (YYYY-MM-DD)", path)
+	}
+	if a.Deadline != "" {
+		if _, err := time.Parse("2006-01-02", a.Deadline); err != nil {
+			return fmt.Errorf("%s.attributes.deadline must be ISO 8601 date (YYYY-MM-DD)", path)
+		}
+	}
+	tags := append(append([]string{}, a.Tags...), a.AddTags...)
+	if len(tags) > 50 {
+		return fmt.Errorf("%s: maximum 50 tags allowed", path)
+	}
+	for _, tag := range tags {
+		if len(tag) > 200 {
+			return fmt.Errorf("%s: each tag must be under 200 characters", path)
+		}
+	}
+	if a.ListID != "" {
+		if err := ValidateThingsID(a.ListID); err != nil {
+			return fmt.Errorf("%s.attributes.list-id: %w", path, err)
+		}
+	}
+	if a.AreaID != "" {
+		if err := ValidateThingsID(a.AreaID); err != nil {
+			return fmt.Errorf("%s.attributes.area-id: %w", path, err)
+		}
+	}
+	return nil
+}
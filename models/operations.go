@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Undo step actions. Each step is one call that reverts part of a write.
+const (
+	UndoDeleteTask          = "delete_task"
+	UndoUpdateTask          = "update_task"
+	UndoPlaceTask           = "place_task"
+	UndoMoveTask            = "move_task"
+	UndoCreateChecklistItem = "create_checklist_item"
+	UndoUpdateChecklistItem = "update_checklist_item"
+	UndoDeleteChecklistItem = "delete_checklist_item"
+	UndoSetChecklist        = "set_checklist"
+	UndoDeleteProject       = "delete_project"
+	UndoUpdateProject       = "update_project"
+	UndoPlaceProject        = "place_project"
+	UndoCreateArea          = "create_area"
+	UndoUpdateArea          = "update_area"
+	UndoDeleteArea          = "delete_area"
+)
+
+// Operation is a write made through the API, kept in the undo journal with
+// the steps that revert it. Steps run in order as one unit.
+type Operation struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"operation"`
+	Client    string     `json:"client"`
+	Targets   []string   `json:"targets"`
+	Steps     []UndoStep `json:"steps"`
+	CreatedAt string     `json:"created_at"`
+	UndoneAt  string     `json:"undone_at,omitempty"`
+	UndoneBy  string     `json:"undone_by,omitempty"`
+}
+
+// UndoStep is one reverting call. Which fields are used depends on Action:
+//
+//   - delete_task, delete_project, delete_area: ID
+//   - update_task, update_project, update_area: ID and Task, Project or Area
+//   - place_task: ID, ProjectID or AreaID (neither for the Inbox), Restore
+//     to take it out of the Trash first, and optionally Task to apply after
+//   - place_project: ID, AreaID (empty for none) and Restore
+//   - move_task: ID and Move
+//   - create_checklist_item: TaskID, Title and Completed (ID is the deleted
+//     item's; the new item gets a new ID)
+//   - update_checklist_item, delete_checklist_item: TaskID, ID and for
+//     updates ChecklistItem
+//   - set_checklist: TaskID and Checklist, the whole checklist to restore
+//     through the URL scheme (ID is the item that was changed)
+//   - create_area: Title, plus Projects and Tasks to move into the new area
+//     (taking them out of the Trash first with Restore)
+type UndoStep struct {
+	Action        string                      `json:"action"`
+	ID            string                      `json:"id,omitempty"`
+	TaskID        string                      `json:"task_id,omitempty"`
+	ProjectID     string                      `json:"project_id,omitempty"`
+	AreaID        string                      `json:"area_id,omitempty"`
+	Title         string                      `json:"title,omitempty"`
+	Completed     bool                        `json:"completed,omitempty"`
+	Restore       bool                        `json:"restore,omitempty"`
+	Tasks         []string                    `json:"tasks,omitempty"`
+	Projects      []string                    `json:"projects,omitempty"`
+	Task          *UpdateTaskRequest          `json:"task,omitempty"`
+	Project       *UpdateProjectRequest       `json:"project,omitempty"`
+	Area          *UpdateAreaRequest          `json:"area,omitempty"`
+	ChecklistItem *UpdateChecklistItemRequest `json:"checklist_item,omitempty"`
+	Move          *MoveTaskRequest            `json:"move,omitempty"`
+	Checklist     []ChecklistEntry            `json:"checklist,omitempty"`
+}
+
+// Targets returns the IDs of the items the step changes. For checklist
+// items that is the item rather than its to-do, except that restoring a
+// whole checklist also changes the to-do's other items.
+func (s UndoStep) Targets() []string {
+	var ids []string
+	if s.ID != "" {
+		ids = append(ids, s.ID)
+	}
+	if s.Action == UndoSetChecklist {
+		ids = append(ids, s.TaskID)
+	}
+	ids = append(ids, s.Tasks...)
+	return append(ids, s.Projects...)
+}
+
+// OperationsFilter selects journal entries for GET /operations.
+type OperationsFilter struct {
+	Client string
+	Target string
+	Limit  int
+}
+
+// ParseOperationsFilter reads an OperationsFilter from query parameters.
+func ParseOperationsFilter(get func(string) string) (OperationsFilter, error) {
+	f := OperationsFilter{Client: get("client"), Target: get("target"), Limit: 50}
+	if v := get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 500 {
+			return f, fmt.Errorf("limit must be between 1 and 500")
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
+
+// Matches reports whether op passes the filter (ignoring Limit).
+func (f OperationsFilter) Matches(op Operation) bool {
+	if f.Client != "" && op.Client != f.Client {
+		return false
+	}
+	return f.Target == "" || containsString(op.Targets, f.Target)
+}
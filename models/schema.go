@@ -0,0 +1,18 @@
+package models
+
+// SchemaInfo describes the Things database schema, as reported by /health.
+// Writable is set when direct writes support the schema; Error explains a
+// schema that is not fully supported.
+type SchemaInfo struct {
+	Version  int    `json:"version,omitempty"`
+	Profile  string `json:"profile,omitempty"`
+	Writable bool   `json:"writable"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DatabaseProfile is a Things database served read-only under
+// /profiles/{name}.
+type DatabaseProfile struct {
+	Name     string     `json:"name"`
+	Database SchemaInfo `json:"database"`
+}
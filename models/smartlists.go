@@ -0,0 +1,58 @@
+package models
+
+import "fmt"
+
+// SmartList is a saved filter query evaluated against to-dos on request.
+type SmartList struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type CreateSmartListRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+func (r *CreateSmartListRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > 500 {
+		return fmt.Errorf("name must be under 500 characters")
+	}
+	if r.Query == "" {
+		return fmt.Errorf("query is required")
+	}
+	if len(r.Query) > 2000 {
+		return fmt.Errorf("query must be under 2000 characters")
+	}
+	return nil
+}
+
+type UpdateSmartListRequest struct {
+	Name  *string `json:"name"`
+	Query *string `json:"query"`
+}
+
+func (r *UpdateSmartListRequest) Validate() error {
+	if r.Name != nil {
+		if *r.Name == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if len(*r.Name) > 500 {
+			return fmt.Errorf("name must be under 500 characters")
+		}
+	}
+	if r.Query != nil {
+		if *r.Query == "" {
+			return fmt.Errorf("query cannot be empty")
+		}
+		if len(*r.Query) > 2000 {
+			return fmt.Errorf("query must be under 2000 characters")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Template is a reusable project tree stored server-side.
+type Template struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Project     TemplateProject `json:"project"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+}
+
+// TemplateProject describes the project created from a template. Title and
+// notes may contain {{variable}} placeholders; when and deadline may be
+// relative dates (see TemplateDatePattern).
+type TemplateProject struct {
+	Title    string            `json:"title"`
+	Notes    string            `json:"notes,omitempty"`
+	When     string            `json:"when,omitempty"`
+	Deadline string            `json:"deadline,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Tasks    []TemplateTask    `json:"tasks,omitempty"`
+	Headings []TemplateHeading `json:"headings,omitempty"`
+}
+
+// TemplateHeading groups tasks under a heading inside the project.
+type TemplateHeading struct {
+	Title string         `json:"title"`
+	Tasks []TemplateTask `json:"tasks,omitempty"`
+}
+
+// TemplateTask is a to-do inside a template project or heading.
+type TemplateTask struct {
+	Title          string   `json:"title"`
+	Notes          string   `json:"notes,omitempty"`
+	When           string   `json:"when,omitempty"`
+	Deadline       string   `json:"deadline,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	ChecklistItems []string `json:"checklist_items,omitempty"`
+}
+
+// TemplateDatePattern matches relative template dates: an optional anchor
+// ("start" or "deadline") followed by an offset such as "+3d", "-1w" or
+// "+2m". A bare anchor ("deadline") is also accepted. "start" is the
+// instantiation start date; "deadline" is the project's resolved deadline.
+var TemplateDatePattern = regexp.MustCompile(`^(start|deadline)?([+-]\d{1,4}[dwm])?$`)
+
+type CreateTemplateRequest struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Project     TemplateProject `json:"project"`
+}
+
+func (r *CreateTemplateRequest) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > 500 {
+		return fmt.Errorf("name must be under 500 characters")
+	}
+	if len(r.Description) > 10000 {
+		return fmt.Errorf("description must be under 10000 characters")
+	}
+	return r.Project.Validate()
+}
+
+type UpdateTemplateRequest struct {
+	Name        *string          `json:"name"`
+	Description *string          `json:"description"`
+	Project     *TemplateProject `json:"project"`
+}
+
+func (r *UpdateTemplateRequest) Validate() error {
+	if r.Name != nil {
+		if *r.Name == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if len(*r.Name) > 500 {
+			return fmt.Errorf("name must be under 500 characters")
+		}
+	}
+	if r.Description != nil && len(*r.Description) > 10000 {
+		return fmt.Errorf("description must be under 10000 characters")
+	}
+	if r.Project != nil {
+		return r.Project.Validate()
+	}
+	return nil
+}
+
+func (p *TemplateProject) Validate() error {
+	if p.Title == "" {
+		return fmt.Errorf("project.title is required")
+	}
+	if len(p.Title) > 500 {
+		return fmt.Errorf("project.title must be under 500 characters")
+	}
+	if len(p.Notes) > 10000 {
+		return fmt.Errorf("project.notes must be under 10000 characters")
+	}
+	if p.When != "" && !isValidProjectWhen(p.When) && !isTemplateDate(p.When) {
+		return fmt.Errorf("project.when must be one of: today, someday, anytime, a date (YYYY-MM-DD), or a relative date (+3d)")
+	}
+	if p.Deadline != "" && !isISODate(p.Deadline) && !isTemplateDate(p.Deadline) {
+		return fmt.Errorf("project.deadline must be a date (YYYY-MM-DD) or a relative date (+3d)")
+	}
+	if len(p.Tags) > 50 {
+		return fmt.Errorf("maximum 50 tags allowed")
+	}
+	for _, tag := range p.Tags {
+		if len(tag) > 200 {
+			return fmt.Errorf("each tag must be under 200 characters")
+		}
+	}
+
+	count := len(p.Tasks)
+	for i := range p.Tasks {
+		if err := p.Tasks[i].validate(fmt.Sprintf("project.tasks[%d]", i)); err != nil {
+			return err
+		}
+	}
+	for i, h := range p.Headings {
+		if h.Title == "" {
+			return fmt.Errorf("project.headings[%d].title is required", i)
+		}
+		if len(h.Title) > 1000 {
+			return fmt.Errorf("project.headings[%d].title must be under 1000 characters", i)
+		}
+		count += 1 + len(h.Tasks)
+		for j := range h.Tasks {
+			if err := h.Tasks[j].validate(fmt.Sprintf("project.headings[%d].tasks[%d]", i, j)); err != nil {
+				return err
+			}
+		}
+	}
+	// The project itself counts towards the Things JSON import limit.
+	if count+1 > maxImportItems {
+		return fmt.Errorf("maximum %d tasks and headings allowed", maxImportItems-1)
+	}
+	return nil
+}
+
+func (t *TemplateTask) validate(path string) error {
+	if t.Title == "" {
+		return fmt.Errorf("%s.title is required", path)
+	}
+	if len(t.Title) > 1000 {
+		return fmt.Errorf("%s.title must be under 1000 characters", path)
+	}
+	if len(t.Notes) > 10000 {
+		return fmt.Errorf("%s.notes must be under 10000 characters", path)
+	}
+	if t.When != "" && !isValidWhen(t.When) && !isTemplateDate(t.When) {
+		return fmt.Errorf("%s.when must be one of: today, evening, tomorrow, someday, anytime, a date (YYYY-MM-DD), or a relative date (+3d)", path)
+	}
+	if t.Deadline != "" && !isISODate(t.Deadline) && !isTemplateDate(t.Deadline) {
+		return fmt.Errorf("%s.deadline must be a date (YYYY-MM-DD) or a relative date (+3d)", path)
+	}
+	if len(t.Tags) > 50 {
+		return fmt.Errorf("%s: maximum 50 tags allowed", path)
+	}
+	for _, tag := range t.Tags {
+		if len(tag) > 200 {
+			return fmt.Errorf("%s: each tag must be under 200 characters", path)
+		}
+	}
+	if len(t.ChecklistItems) > 100 {
+		return fmt.Errorf("%s: maximum 100 checklist items allowed", path)
+	}
+	for _, item := range t.ChecklistItems {
+		if item == "" {
+			return fmt.Errorf("%s: checklist item title cannot be empty", path)
+		}
+		if len(item) > 1000 {
+			return fmt.Errorf("%s: checklist item title must be under 1000 characters", path)
+		}
+	}
+	return nil
+}
+
+type InstantiateTemplateRequest struct {
+	AreaID    string            `json:"area_id"`
+	Variables map[string]string `json:"variables"`
+	StartDate string            `json:"start_date"`
+	Deadline  string            `json:"deadline"`
+}
+
+func (r *InstantiateTemplateRequest) Validate() error {
+	if r.AreaID != "" {
+		if err := ValidateThingsID(r.AreaID); err != nil {
+			return fmt.Errorf("invalid area_id")
+		}
+	}
+	if r.StartDate != "" && !isISODate(r.StartDate) {
+		return fmt.Errorf("start_date must be ISO 8601 date (YYYY-MM-DD)")
+	}
+	if r.Deadline != "" && !isISODate(r.Deadline) {
+		return fmt.Errorf("deadline must be ISO 8601 date (YYYY-MM-DD)")
+	}
+	if len(r.Variables) > 100 {
+		return fmt.Errorf("maximum 100 variables allowed")
+	}
+	for k, v := range r.Variables {
+		if len(k) > 100 || len(v) > 1000 {
+			return fmt.Errorf("variable names must be under 100 and values under 1000 characters")
+		}
+	}
+	return nil
+}
+
+// isTemplateDate reports whether s is a non-empty relative template date.
+func isTemplateDate(s string) bool {
+	return s != "" && TemplateDatePattern.MatchString(s)
+}
+
+func isISODate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
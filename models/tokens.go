@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Token scopes. ScopeAdmin grants everything.
+const (
+	ScopeTasksRead     = "tasks:read"
+	ScopeTasksWrite    = "tasks:write"
+	ScopeProjectsWrite = "projects:write"
+	ScopeAdmin         = "admin"
+)
+
+var validScopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeProjectsWrite, ScopeAdmin}
+
+// APIToken is a named API client. Only the SHA-256 hash of the secret is
+// stored. Areas and Projects, if set, limit the client to those areas and
+// projects. A ReadOnly token can only make GET and HEAD requests, whatever
+// its scopes. LastUsedAt is filled in from the separate usage file when
+// tokens are listed.
+type APIToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Hash       string   `json:"hash"`
+	Scopes     []string `json:"scopes"`
+	Areas      []string `json:"areas,omitempty"`
+	Projects   []string `json:"projects,omitempty"`
+	ReadOnly   bool     `json:"read_only,omitempty"`
+	ExpiresAt  string   `json:"expires_at,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	LastUsedAt string   `json:"last_used_at,omitempty"`
+}
+
+// Expired reports whether the token has passed its expiry time.
+func (t APIToken) Expired(now time.Time) bool {
+	if t.ExpiresAt == "" {
+		return false
+	}
+	exp, err := time.Parse(time.RFC3339, t.ExpiresAt)
+	return err != nil || !now.Before(exp)
+}
+
+type CreateTokenRequest struct {
+	Name      string
+	Scopes    []string
+	Areas     []string
+	Projects  []string
+	ReadOnly  bool
+	ExpiresIn time.Duration
+}
+
+func (r *CreateTokenRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(r.Name) > 100 {
+		return fmt.Errorf("name must be 100 characters or less")
+	}
+	if len(r.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, s := range r.Scopes {
+		if !containsString(validScopes, s) {
+			return fmt.Errorf("invalid scope %q: must be one of %s", s, strings.Join(validScopes, ", "))
+		}
+	}
+	for _, id := range r.Areas {
+		if err := ValidateThingsID(id); err != nil {
+			return fmt.Errorf("invalid area id %q", id)
+		}
+	}
+	for _, id := range r.Projects {
+		if err := ValidateThingsID(id); err != nil {
+			return fmt.Errorf("invalid project id %q", id)
+		}
+	}
+	if r.ExpiresIn < 0 {
+		return fmt.Errorf("expiry must be positive")
+	}
+	return nil
+}
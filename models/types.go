@@ -17,13 +17,27 @@ type Task struct {
 	Due            string          `json:"due,omitempty"`
 	When           string          `json:"when,omitempty"`
 	CreatedAt      string          `json:"created_at,omitempty"`
+	ModifiedAt     string          `json:"modified_at,omitempty"`
 	ChecklistItems []ChecklistItem `json:"checklist_items,omitempty"`
+	Position       int             `json:"position,omitempty"` // in lists only
 }
 
 type ChecklistItem struct {
-	ID        string `json:"id"`
-	Title     string `json:"title"`
-	Completed bool   `json:"completed"`
+	ID         string `json:"id"`
+	TaskID     string `json:"task_id,omitempty"`
+	Title      string `json:"title"`
+	Completed  bool   `json:"completed"`
+	ModifiedAt string `json:"modified_at,omitempty"`
+}
+
+// ChecklistEntry is a checklist item as sent to Things when a whole
+// checklist is replaced. Status is "open", "completed" or "canceled".
+type ChecklistEntry struct {
+	// ID is the item's current ID when read from the database. Things
+	// ignores it and assigns new IDs.
+	ID     string `json:"-"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
 }
 
 type CreateTaskRequest struct {
@@ -91,6 +105,7 @@ type UpdateTaskRequest struct {
 	Due     *string  `json:"due"`
 	When    *string  `json:"when"`
 	Tags    []string `json:"tags"`
+	Status  *string  `json:"status"`
 }
 
 func (r *UpdateTaskRequest) Validate() error {
@@ -129,15 +144,20 @@ func (r *UpdateTaskRequest) Validate() error {
 	if r.Area != nil && len(*r.Area) > 500 {
 		return fmt.Errorf("area name must be under 500 characters")
 	}
+	if r.Status != nil && !IsValidStatus(*r.Status) {
+		return fmt.Errorf("status must be one of: open, completed, canceled")
+	}
 	return nil
 }
 
 type Project struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Area      string `json:"area,omitempty"`
-	Notes     string `json:"notes,omitempty"`
-	TaskCount int    `json:"task_count,omitempty"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Area       string `json:"area,omitempty"`
+	Notes      string `json:"notes,omitempty"`
+	Status     string `json:"status,omitempty"`
+	TaskCount  int    `json:"task_count,omitempty"`
+	ModifiedAt string `json:"modified_at,omitempty"`
 }
 
 type CreateProjectRequest struct {
@@ -167,9 +187,10 @@ func (r *CreateProjectRequest) Validate() error {
 }
 
 type UpdateProjectRequest struct {
-	Name  *string `json:"name"`
-	Area  *string `json:"area"`
-	Notes *string `json:"notes"`
+	Name   *string `json:"name"`
+	Area   *string `json:"area"`
+	Notes  *string `json:"notes"`
+	Status *string `json:"status"`
 }
 
 func (r *UpdateProjectRequest) Validate() error {
@@ -187,6 +208,9 @@ func (r *UpdateProjectRequest) Validate() error {
 	if r.Area != nil && len(*r.Area) > 500 {
 		return fmt.Errorf("area name must be under 500 characters")
 	}
+	if r.Status != nil && !IsValidStatus(*r.Status) {
+		return fmt.Errorf("status must be one of: open, completed, canceled")
+	}
 	return nil
 }
 
@@ -196,6 +220,20 @@ type Area struct {
 	Projects []Project `json:"projects,omitempty"`
 }
 
+// Container is a project or area. AreaID is the area a project belongs to.
+type Container struct {
+	ID     string
+	Type   string // project or area
+	Name   string
+	AreaID string
+}
+
+// TaskContainer is the project and area a to-do is in, by ID.
+type TaskContainer struct {
+	ProjectID string
+	AreaID    string
+}
+
 type CreateAreaRequest struct {
 	Name string `json:"name"`
 }
@@ -260,6 +298,190 @@ func (r *UpdateChecklistItemRequest) Validate() error {
 	return nil
 }
 
+type DuplicateTaskRequest struct {
+	Title            *string `json:"title"`
+	ListID           string  `json:"list_id"`
+	ShiftDays        int     `json:"shift_days"`
+	IncludeCompleted bool    `json:"include_completed"`
+}
+
+func (r *DuplicateTaskRequest) Validate() error {
+	if r.Title != nil {
+		if *r.Title == "" {
+			return fmt.Errorf("title cannot be empty")
+		}
+		if len(*r.Title) > 1000 {
+			return fmt.Errorf("title must be under 1000 characters")
+		}
+	}
+	if r.ListID != "" {
+		if err := ValidateThingsID(r.ListID); err != nil {
+			return fmt.Errorf("invalid list_id")
+		}
+	}
+	if r.ShiftDays < -3650 || r.ShiftDays > 3650 {
+		return fmt.Errorf("shift_days must be between -3650 and 3650")
+	}
+	return nil
+}
+
+type DuplicateProjectRequest struct {
+	Name             *string `json:"name"`
+	AreaID           string  `json:"area_id"`
+	ShiftDays        int     `json:"shift_days"`
+	IncludeCompleted bool    `json:"include_completed"`
+}
+
+func (r *DuplicateProjectRequest) Validate() error {
+	if r.Name != nil {
+		if *r.Name == "" {
+			return fmt.Errorf("name cannot be empty")
+		}
+		if len(*r.Name) > 500 {
+			return fmt.Errorf("name must be under 500 characters")
+		}
+	}
+	if r.AreaID != "" {
+		if err := ValidateThingsID(r.AreaID); err != nil {
+			return fmt.Errorf("invalid area_id")
+		}
+	}
+	if r.ShiftDays < -3650 || r.ShiftDays > 3650 {
+		return fmt.Errorf("shift_days must be between -3650 and 3650")
+	}
+	return nil
+}
+
+// SearchDocument is a searchable task, project or area.
+type SearchDocument struct {
+	ID        string
+	Type      string // task, project or area
+	Title     string
+	Notes     string
+	Status    string
+	Project   string
+	Area      string
+	Tags      []string
+	Checklist []string
+}
+
+// SearchResult is a ranked search match with highlighted snippets.
+type SearchResult struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Title      string            `json:"title"`
+	Status     string            `json:"status,omitempty"`
+	Project    string            `json:"project,omitempty"`
+	Area       string            `json:"area,omitempty"`
+	Score      float64           `json:"score"`
+	Highlights []SearchHighlight `json:"highlights"`
+}
+
+// SearchHighlight is a snippet of a matched field with matches wrapped in <mark> tags.
+type SearchHighlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// MoveTaskRequest places a task directly before or after a sibling task.
+// List is "today" to reorder within Today; otherwise the task is reordered
+// within its project, heading or area.
+type MoveTaskRequest struct {
+	Before string `json:"before"`
+	After  string `json:"after"`
+	List   string `json:"list"`
+}
+
+func (r *MoveTaskRequest) Validate() error {
+	if (r.Before == "") == (r.After == "") {
+		return fmt.Errorf("exactly one of before or after is required")
+	}
+	sibling := r.Before
+	if sibling == "" {
+		sibling = r.After
+	}
+	if err := ValidateThingsID(sibling); err != nil {
+		return fmt.Errorf("invalid sibling id")
+	}
+	if r.List != "" && r.List != "today" {
+		return fmt.Errorf("list must be empty or today")
+	}
+	return nil
+}
+
+// Policies for the contents of a deleted project or area.
+const (
+	ContentsTrash = "trash" // trash contents together with the container
+	ContentsInbox = "inbox" // move to-dos to the Inbox; projects lose their area
+	ContentsArea  = "area"  // move to-dos and projects to another area
+)
+
+// DeleteContainerRequest holds the query parameters for deleting a project or area.
+type DeleteContainerRequest struct {
+	Contents string
+	AreaID   string
+	DryRun   bool
+}
+
+func (r *DeleteContainerRequest) Validate() error {
+	switch r.Contents {
+	case ContentsTrash, ContentsInbox:
+		if r.AreaID != "" {
+			return fmt.Errorf("area_id is only allowed with contents=area")
+		}
+	case ContentsArea:
+		if err := ValidateThingsID(r.AreaID); err != nil {
+			return fmt.Errorf("contents=area requires a valid area_id")
+		}
+	case "":
+		return fmt.Errorf("contents is required: trash, inbox, or area")
+	default:
+		return fmt.Errorf("contents must be one of: trash, inbox, area")
+	}
+	return nil
+}
+
+// DeleteResult lists what a project or area deletion affects (or affected).
+type DeleteResult struct {
+	OK       bool      `json:"ok"`
+	DryRun   bool      `json:"dry_run"`
+	Contents string    `json:"contents"`
+	AreaID   string    `json:"area_id,omitempty"`
+	Tasks    []Task    `json:"tasks"`
+	Projects []Project `json:"projects,omitempty"`
+
+	// Scripts is the script the deletion would run; set on dry runs.
+	Scripts []ScriptPreview `json:"scripts,omitempty"`
+}
+
+// SyncResponse is the result of GET /sync. When Full is set, Created holds a
+// complete snapshot and the client should replace its local copy.
+type SyncResponse struct {
+	Token   string      `json:"token"`
+	Full    bool        `json:"full"`
+	Created SyncChanges `json:"created"`
+	Updated SyncChanges `json:"updated"`
+	Deleted []string    `json:"deleted"`
+}
+
+// SyncChanges groups synced entities by type.
+type SyncChanges struct {
+	Tasks          []Task          `json:"tasks"`
+	Projects       []Project       `json:"projects"`
+	Areas          []Area          `json:"areas"`
+	ChecklistItems []ChecklistItem `json:"checklist_items"`
+}
+
+// NewSyncChanges returns SyncChanges with empty (non-nil) lists.
+func NewSyncChanges() SyncChanges {
+	return SyncChanges{
+		Tasks:          []Task{},
+		Projects:       []Project{},
+		Areas:          []Area{},
+		ChecklistItems: []ChecklistItem{},
+	}
+}
+
 var thingsIDPattern = regexp.MustCompile(`^[A-Za-z0-9\-]+$`)
 
 func ValidateThingsID(id string) error {
@@ -275,6 +497,16 @@ func ValidateThingsID(id string) error {
 	return nil
 }
 
+// IsValidStatus reports whether s is a task or project status accepted by the API.
+func IsValidStatus(s string) bool {
+	switch s {
+	case "open", "completed", "canceled":
+		return true
+	default:
+		return false
+	}
+}
+
 func isValidWhen(w string) bool {
 	switch w {
 	case "today", "evening", "tomorrow", "someday", "anytime":
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Webhook delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is a registered target URL for change feed events. Empty Events or
+// Resources match every action or item type.
+type Webhook struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret,omitempty"`
+	Events    []string `json:"events,omitempty"`
+	Resources []string `json:"resources,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+// Matches reports whether e passes the webhook's event and resource filters.
+func (h Webhook) Matches(e Event) bool {
+	return (len(h.Events) == 0 || containsString(h.Events, e.Action)) &&
+		(len(h.Resources) == 0 || containsString(h.Resources, e.Type))
+}
+
+// WebhookDelivery is one event queued for one webhook, with its attempts.
+type WebhookDelivery struct {
+	ID            string           `json:"id"`
+	WebhookID     string           `json:"webhook_id"`
+	Event         Event            `json:"event"`
+	Status        string           `json:"status"`
+	Attempts      []WebhookAttempt `json:"attempts"`
+	NextAttemptAt string           `json:"next_attempt_at,omitempty"`
+	ReplayOf      string           `json:"replay_of,omitempty"`
+	CreatedAt     string           `json:"created_at"`
+	UpdatedAt     string           `json:"updated_at"`
+}
+
+// WebhookAttempt records the outcome of one delivery attempt.
+type WebhookAttempt struct {
+	At         string `json:"at"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+type CreateWebhookRequest struct {
+	URL       string   `json:"url"`
+	Secret    string   `json:"secret"`
+	Events    []string `json:"events"`
+	Resources []string `json:"resources"`
+	Active    *bool    `json:"active"`
+}
+
+func (r *CreateWebhookRequest) Validate() error {
+	if r.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if err := validateWebhookURL(r.URL); err != nil {
+		return err
+	}
+	if r.Secret != "" {
+		if err := validateWebhookSecret(r.Secret); err != nil {
+			return err
+		}
+	}
+	return validateWebhookFilters(r.Events, r.Resources)
+}
+
+type UpdateWebhookRequest struct {
+	URL       *string   `json:"url"`
+	Secret    *string   `json:"secret"`
+	Events    *[]string `json:"events"`
+	Resources *[]string `json:"resources"`
+	Active    *bool     `json:"active"`
+}
+
+func (r *UpdateWebhookRequest) Validate() error {
+	if r.URL != nil {
+		if err := validateWebhookURL(*r.URL); err != nil {
+			return err
+		}
+	}
+	if r.Secret != nil {
+		if err := validateWebhookSecret(*r.Secret); err != nil {
+			return err
+		}
+	}
+	var events, resources []string
+	if r.Events != nil {
+		events = *r.Events
+	}
+	if r.Resources != nil {
+		resources = *r.Resources
+	}
+	return validateWebhookFilters(events, resources)
+}
+
+func validateWebhookURL(s string) error {
+	if len(s) > 2000 {
+		return fmt.Errorf("url must be under 2000 characters")
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+func validateWebhookSecret(s string) error {
+	if len(s) < 16 {
+		return fmt.Errorf("secret must be at least 16 characters")
+	}
+	if len(s) > 256 {
+		return fmt.Errorf("secret must be under 256 characters")
+	}
+	return nil
+}
+
+func validateWebhookFilters(events, resources []string) error {
+	for _, e := range events {
+		switch e {
+		case ActionCreated, ActionUpdated, ActionCompleted, ActionDeleted:
+		default:
+			return fmt.Errorf("events must be one of: created, updated, completed, deleted")
+		}
+	}
+	for _, r := range resources {
+		switch r {
+		case ItemTypeTask, ItemTypeProject, ItemTypeArea, ItemTypeChecklistItem:
+		default:
+			return fmt.Errorf("resources must be one of: task, project, area, checklist_item")
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,322 @@
+// Package search parses search queries and ranks Things items against them.
+//
+// Query syntax: whitespace-separated terms, all of which must match. A term
+// may be a "quoted phrase" and may be restricted to one field with a prefix:
+// title:, notes:, tag: or checklist: (e.g. tag:work or notes:"call back").
+package search
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// Searchable fields.
+const (
+	FieldTitle     = "title"
+	FieldNotes     = "notes"
+	FieldTag       = "tag"
+	FieldChecklist = "checklist"
+)
+
+// fieldWeights scores a match by the field it occurred in.
+var fieldWeights = map[string]float64{
+	FieldTitle:     10,
+	FieldTag:       6,
+	FieldChecklist: 3,
+	FieldNotes:     2,
+}
+
+// snippetRadius is the number of characters kept on each side of a match in
+// notes and checklist snippets.
+const snippetRadius = 40
+
+// Term is one parsed query term.
+type Term struct {
+	Field  string // empty means any field
+	Text   string // lower-cased
+	Phrase bool
+}
+
+// Parse splits a query into terms. Unknown prefixes (e.g. "http:") are kept
+// as plain text.
+func Parse(q string) ([]Term, error) {
+	var terms []Term
+	rest := strings.TrimSpace(q)
+	for rest != "" {
+		var term Term
+		if i := strings.IndexAny(rest, ": \t\""); i > 0 && rest[i] == ':' {
+			switch field := strings.ToLower(rest[:i]); field {
+			case FieldTitle, FieldNotes, FieldTag, FieldChecklist:
+				term.Field = field
+				rest = rest[i+1:]
+			}
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted phrase")
+			}
+			term.Text = rest[1 : end+1]
+			term.Phrase = true
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			term.Text = rest[:end]
+			rest = rest[end:]
+		}
+		rest = strings.TrimSpace(rest)
+
+		term.Text = strings.ToLower(strings.TrimSpace(term.Text))
+		if term.Text != "" {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query must contain at least one term")
+	}
+	return terms, nil
+}
+
+// Rank scores docs against terms and returns matches ordered by score,
+// at most limit results. A document matches only if every term matches.
+func Rank(docs []models.SearchDocument, terms []Term, limit int) []models.SearchResult {
+	results := []models.SearchResult{}
+	for _, doc := range docs {
+		if r, ok := score(doc, terms); ok {
+			results = append(results, r)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(results[i].Title) < strings.ToLower(results[j].Title)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func score(doc models.SearchDocument, terms []Term) (models.SearchResult, bool) {
+	fields := map[string][]string{
+		FieldTitle:     {doc.Title},
+		FieldNotes:     {doc.Notes},
+		FieldTag:       doc.Tags,
+		FieldChecklist: doc.Checklist,
+	}
+
+	var total float64
+	matched := make(map[string][]string) // field -> values that matched
+	for _, term := range terms {
+		best := 0.0
+		for field, values := range fields {
+			if term.Field != "" && term.Field != field {
+				continue
+			}
+			for _, v := range values {
+				if i, _ := indexFold(v, term.Text); i < 0 {
+					continue
+				}
+				w := fieldWeights[field]
+				if _, ok := prefixFold(v, term.Text); ok && field == FieldTitle {
+					w += 3
+				}
+				if field == FieldTag && strings.EqualFold(v, term.Text) {
+					w += 2
+				}
+				if w > best {
+					best = w
+				}
+				if !contains(matched[field], v) {
+					matched[field] = append(matched[field], v)
+				}
+			}
+		}
+		if best == 0 {
+			return models.SearchResult{}, false
+		}
+		total += best
+	}
+
+	if len(terms) > 1 || terms[0].Phrase {
+		var parts []string
+		for _, t := range terms {
+			parts = append(parts, t.Text)
+		}
+		if strings.EqualFold(doc.Title, strings.Join(parts, " ")) {
+			total += 20
+		}
+	}
+	if doc.Status == "" || doc.Status == "open" {
+		total += 1
+	}
+
+	result := models.SearchResult{
+		ID:      doc.ID,
+		Type:    doc.Type,
+		Title:   doc.Title,
+		Status:  doc.Status,
+		Project: doc.Project,
+		Area:    doc.Area,
+		Score:   total,
+	}
+	for _, field := range []string{FieldTitle, FieldTag, FieldChecklist, FieldNotes} {
+		for _, v := range matched[field] {
+			snippet := v
+			if field == FieldNotes || field == FieldChecklist {
+				snippet = excerpt(v, terms)
+			}
+			result.Highlights = append(result.Highlights, models.SearchHighlight{
+				Field:   field,
+				Snippet: highlight(snippet, terms),
+			})
+		}
+	}
+	return result, true
+}
+
+// excerpt returns the part of s around the first term match, trimmed with
+// ellipses when shortened.
+func excerpt(s string, terms []Term) string {
+	first := -1
+	for _, t := range terms {
+		if i, _ := indexFold(s, t.Text); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	start := first - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := first + snippetRadius*2
+	if end > len(s) {
+		end = len(s)
+	}
+	if start > end {
+		start = end
+	}
+	// Keep slice boundaries on rune starts.
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+
+	out := strings.Join(strings.Fields(s[start:end]), " ")
+	if start > 0 {
+		out = "…" + out
+	}
+	if end < len(s) {
+		out += "…"
+	}
+	return out
+}
+
+// highlight HTML-escapes s and wraps every term occurrence in <mark> tags.
+func highlight(s string, terms []Term) string {
+	marks := make([]bool, len(s))
+	for _, t := range terms {
+		for off := 0; ; {
+			i, n := indexFold(s[off:], t.Text)
+			if i < 0 {
+				break
+			}
+			for k := off + i; k < off+i+n; k++ {
+				marks[k] = true
+			}
+			off += i + n
+		}
+	}
+
+	var b strings.Builder
+	in := false
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		if marks[i] != in {
+			if marks[i] {
+				b.WriteString("<mark>")
+			} else {
+				b.WriteString("</mark>")
+			}
+			in = marks[i]
+		}
+		b.WriteString(html.EscapeString(s[i : i+size]))
+		i += size
+	}
+	if in {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// indexFold returns the byte offset and length in s of the first match of
+// substr under Unicode case folding, or -1. The offsets refer to s itself:
+// a lower-cased copy of s may differ in length.
+func indexFold(s, substr string) (int, int) {
+	for i := 0; i < len(s); {
+		if n, ok := prefixFold(s[i:], substr); ok {
+			return i, n
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return -1, 0
+}
+
+// prefixFold reports whether s starts with prefix under Unicode case
+// folding, and the length in bytes of the matching part of s.
+func prefixFold(s, prefix string) (int, bool) {
+	n := 0
+	for prefix != "" {
+		if n >= len(s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(s[n:])
+		p, psize := utf8.DecodeRuneInString(prefix)
+		if !equalFoldRune(r, p) {
+			return 0, false
+		}
+		n += size
+		prefix = prefix[psize:]
+	}
+	return n, true
+}
+
+// equalFoldRune reports whether a and b are equal under simple case folding.
+func equalFoldRune(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for r := unicode.SimpleFold(a); r != a; r = unicode.SimpleFold(r) {
+		if r == b {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
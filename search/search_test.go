@@ -0,0 +1,56 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func TestRankFoldsUnicodeCase(t *testing.T) {
+	terms, err := Parse("дантист")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []models.SearchDocument{
+		{ID: "a", Type: "task", Title: "Записаться к Дантисту"},
+		{ID: "b", Type: "task", Title: "Dentist", Notes: "ДАНТИСТ в среду"},
+		{ID: "c", Type: "task", Title: "Milk"},
+	}
+	results := Rank(docs, terms, 0)
+	if len(results) != 2 || results[0].ID != "a" || results[1].ID != "b" {
+		t.Fatalf("results = %+v", results)
+	}
+	if got, want := results[0].Highlights[0].Snippet, "Записаться к <mark>Дантист</mark>у"; got != want {
+		t.Errorf("title highlight = %q, want %q", got, want)
+	}
+	if got, want := results[1].Highlights[0].Snippet, "<mark>ДАНТИСТ</mark> в среду"; got != want {
+		t.Errorf("notes highlight = %q, want %q", got, want)
+	}
+}
+
+// TestHighlightLengthChange covers characters whose lower-case form has a
+// different UTF-8 length, which used to shift highlights.
+func TestHighlightLengthChange(t *testing.T) {
+	// "İ" (2 bytes) lower-cases to "i̇" (3 bytes); "K" (Kelvin, 3 bytes)
+	// folds to "k" (1 byte).
+	tests := []struct {
+		s, term, want string
+	}{
+		{"İstanbul trip", "trip", "İstanbul <mark>trip</mark>"},
+		{"5 K run", "k", "5 <mark>K</mark> run"},
+		{"KKK done", "done", "KKK <mark>done</mark>"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.s, []Term{{Text: tt.term}}); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.s, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	notes := "Позвонить в клинику и спросить, когда дантист принимает в субботу утром или вечером"
+	got := excerpt(notes, []Term{{Text: "ДАНТИСТ"}})
+	if i, n := indexFold(got, "дантист"); i < 0 || n != len("дантист") {
+		t.Errorf("excerpt %q lost the match", got)
+	}
+}
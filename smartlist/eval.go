@@ -0,0 +1,231 @@
+package smartlist
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// relativeDatePattern matches day, week or month offsets from today such
+// as "+3d", "-1w" or "2m".
+var relativeDatePattern = regexp.MustCompile(`^([+-]?)(\d{1,4})([dwm])$`)
+
+// Match reports whether t satisfies the query. Dates are compared by day,
+// relative to now. A date condition never matches a to-do without that date.
+func (q *Query) Match(t models.Task, now time.Time) bool {
+	if !q.usesStatus && t.Status != "open" {
+		return false
+	}
+	return q.root.match(&t, startOfDay(now))
+}
+
+// Filter returns the tasks that satisfy the query, in their original order.
+func (q *Query) Filter(tasks []models.Task, now time.Time) []models.Task {
+	matched := []models.Task{}
+	for _, t := range tasks {
+		if q.Match(t, now) {
+			matched = append(matched, t)
+		}
+	}
+	return matched
+}
+
+type node interface {
+	match(t *models.Task, today time.Time) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(t *models.Task, today time.Time) bool {
+	return n.left.match(t, today) && n.right.match(t, today)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(t *models.Task, today time.Time) bool {
+	return n.left.match(t, today) || n.right.match(t, today)
+}
+
+type notNode struct{ inner node }
+
+func (n notNode) match(t *models.Task, today time.Time) bool {
+	return !n.inner.match(t, today)
+}
+
+// textNode matches a lower-cased word or phrase in the title or notes.
+type textNode struct{ text string }
+
+func (n textNode) match(t *models.Task, _ time.Time) bool {
+	return strings.Contains(strings.ToLower(t.Title), n.text) ||
+		strings.Contains(strings.ToLower(t.Notes), n.text)
+}
+
+// condition is a validated field/operator/value triple. Values are lower-cased.
+type condition struct {
+	field string
+	op    string
+	value string
+}
+
+func (c condition) match(t *models.Task, today time.Time) bool {
+	switch c.field {
+	case FieldTitle:
+		return c.matchText(t.Title)
+	case FieldNotes:
+		return c.matchText(t.Notes)
+	case FieldTag:
+		has := false
+		for _, tag := range t.Tags {
+			if strings.EqualFold(tag, c.value) {
+				has = true
+				break
+			}
+		}
+		return has != (c.op == "!=")
+	case FieldProject:
+		return strings.EqualFold(t.Project, c.value) != (c.op == "!=")
+	case FieldArea:
+		return strings.EqualFold(t.Area, c.value) != (c.op == "!=")
+	case FieldStatus:
+		return (t.Status == c.value) != (c.op == "!=")
+	case FieldDue:
+		return c.matchDate(t.Due, today)
+	case FieldWhen:
+		if isWhenBucket(c.value) {
+			return (whenBucket(t.When) == c.value) != (c.op == "!=")
+		}
+		return c.matchDate(t.When, today)
+	case FieldCreated:
+		return c.matchDate(t.CreatedAt, today)
+	case FieldHas:
+		switch c.value {
+		case FieldDue:
+			return t.Due != ""
+		case FieldWhen:
+			return t.When != ""
+		case FieldNotes:
+			return t.Notes != ""
+		case FieldProject:
+			return t.Project != ""
+		case FieldArea:
+			return t.Area != ""
+		case "tags":
+			return len(t.Tags) > 0
+		case "checklist":
+			return len(t.ChecklistItems) > 0
+		}
+	}
+	return false
+}
+
+// matchText matches ":" as a case-insensitive substring and "=" / "!=" as
+// case-insensitive equality.
+func (c condition) matchText(s string) bool {
+	s = strings.ToLower(s)
+	switch c.op {
+	case ":":
+		return strings.Contains(s, c.value)
+	case "=":
+		return s == c.value
+	default:
+		return s != c.value
+	}
+}
+
+func (c condition) matchDate(s string, today time.Time) bool {
+	d, ok := taskDate(s, today.Location())
+	if !ok {
+		return false
+	}
+	want, err := resolveDate(c.value, today)
+	if err != nil {
+		return false
+	}
+
+	switch c.op {
+	case ":", "=":
+		return d.Equal(want)
+	case "!=":
+		return !d.Equal(want)
+	case "<":
+		return d.Before(want)
+	case "<=":
+		return !d.After(want)
+	case ">":
+		return d.After(want)
+	case ">=":
+		return !d.Before(want)
+	}
+	return false
+}
+
+// isWhenBucket reports whether v names an unscheduled when value.
+func isWhenBucket(v string) bool {
+	return v == "inbox" || v == "anytime" || v == "someday"
+}
+
+// whenBucket maps a task's when value to inbox, anytime or someday. Scheduled
+// to-dos return their date.
+func whenBucket(when string) string {
+	if when == "" {
+		return "inbox"
+	}
+	return when
+}
+
+// resolveDate converts a date value (YYYY-MM-DD, today, tomorrow, yesterday
+// or a relative offset) into a day in today's location.
+func resolveDate(v string, today time.Time) (time.Time, error) {
+	today = startOfDay(today)
+	switch v {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+
+	if m := relativeDatePattern.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.Atoi(m[2])
+		if m[1] == "-" {
+			n = -n
+		}
+		switch m[3] {
+		case "d":
+			return today.AddDate(0, 0, n), nil
+		case "w":
+			return today.AddDate(0, 0, 7*n), nil
+		default:
+			return today.AddDate(0, n, 0), nil
+		}
+	}
+
+	d, err := time.ParseInLocation("2006-01-02", v, today.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (use YYYY-MM-DD, today, tomorrow, yesterday or an offset like +3d)", v)
+	}
+	return d, nil
+}
+
+// taskDate parses the day from a task date field (YYYY-MM-DD, optionally
+// followed by a time as in RFC 3339).
+func taskDate(s string, loc *time.Location) (time.Time, bool) {
+	if len(s) < 10 {
+		return time.Time{}, false
+	}
+	d, err := time.ParseInLocation("2006-01-02", s[:10], loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return d, true
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,96 @@
+package smartlist
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+// now is a fixed evaluation time: Sunday 2026-10-18, late evening.
+var now = time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+
+var tasks = []models.Task{
+	{ID: "milk", Title: "Buy milk", Status: "open", Area: "Home", Tags: []string{"Errand"}, Due: "2026-10-19", When: "2026-10-18", CreatedAt: "2026-10-01T09:00:00Z"},
+	{ID: "tiles", Title: "Order tiles", Notes: "Ask about grout", Status: "open", Project: "Home Renovation", Area: "Home", Due: "2026-10-25", When: "anytime", CreatedAt: "2026-10-17T09:00:00Z"},
+	{ID: "report", Title: "Quarterly report", Status: "open", Project: "Work", Tags: []string{"work", "urgent"}, Due: "2026-10-17", When: "someday",
+		ChecklistItems: []models.ChecklistItem{{Title: "Draft"}}},
+	{ID: "inbox", Title: "Call plumber", Status: "open"},
+	{ID: "done", Title: "Buy paint", Status: "completed", Project: "Home Renovation", Tags: []string{"errand"}, Due: "2026-10-18"},
+	{ID: "dropped", Title: "Buy ladder", Status: "canceled", Tags: []string{"errand"}},
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"buy", []string{"milk"}},
+		{"GROUT", []string{"tiles"}},
+		{`"buy milk"`, []string{"milk"}},
+		{"tag:errand", []string{"milk"}},
+		{"tag:errand status:completed", []string{"done"}},
+		{"tag:errand OR status:canceled", []string{"milk", "done", "dropped"}},
+		{"tag!=errand", []string{"tiles", "report", "inbox"}},
+		{"NOT tag:errand AND NOT status:open", []string{}},
+		{`project:"home renovation"`, []string{"tiles"}},
+		{"project!=Work", []string{"milk", "tiles", "inbox"}},
+		{"area:home", []string{"milk", "tiles"}},
+		{"title:order", []string{"tiles"}},
+		{"title=order", []string{}},
+		{`title="Order Tiles"`, []string{"tiles"}},
+		{"title!=buy", []string{"milk", "tiles", "report", "inbox"}},
+		{"status:open", []string{"milk", "tiles", "report", "inbox"}},
+		{"status!=open", []string{"done", "dropped"}},
+		{"due:tomorrow", []string{"milk"}},
+		{"due<today", []string{"report"}},
+		{"due<=+1d", []string{"milk", "report"}},
+		{"due>=+1w", []string{"tiles"}},
+		{"due>2026-10-19", []string{"tiles"}},
+		{"due!=yesterday", []string{"milk", "tiles"}},
+		{"due<+1m status!=canceled", []string{"milk", "tiles", "report", "done"}},
+		{"when:today", []string{"milk"}},
+		{"when:someday", []string{"report"}},
+		{"when:inbox", []string{"inbox"}},
+		{"when!=anytime", []string{"milk", "report", "inbox"}},
+		{"created>=-2d", []string{"tiles"}},
+		{"created<2026-10-02", []string{"milk"}},
+		{"has:checklist", []string{"report"}},
+		{"has:notes", []string{"tiles"}},
+		{"has:project", []string{"tiles", "report"}},
+		{"has:tags", []string{"milk", "report"}},
+		{"NOT has:due", []string{"inbox"}},
+		{"(tag:work OR area:home) has:due due<=+1d", []string{"milk", "report"}},
+		{"tag:work OR area:home has:notes", []string{"tiles", "report"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, task := range q.Filter(tasks, now) {
+				got = append(got, task.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Filter(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFilterLocation checks that days are taken in the location of now.
+func TestFilterLocation(t *testing.T) {
+	q, err := Parse("due:today")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 23:30 UTC on the 18th is already the 19th in Tokyo.
+	tokyo := time.FixedZone("JST", 9*60*60)
+	got := q.Filter(tasks, now.In(tokyo))
+	if len(got) != 1 || got[0].ID != "milk" {
+		t.Errorf("Filter in JST = %v, want [milk]", got)
+	}
+}
@@ -0,0 +1,329 @@
+// Package smartlist implements the filter language used by saved smart lists.
+//
+// A query combines conditions with AND, OR, NOT and parentheses. Adjacent
+// conditions are implicitly ANDed, and AND binds tighter than OR:
+//
+//	tag:work AND due<+3d AND NOT status:completed
+//	(project:"Home Renovation" OR tag:errand) has:due
+//
+// A condition is a field, an operator (:, =, !=, <, <=, >, >=) and a value,
+// which may be quoted. A bare word or "quoted phrase" matches title or notes.
+// Queries are evaluated in Go over models.Task, so they work the same
+// whichever backend loaded the tasks.
+package smartlist
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Fields that can be used in conditions.
+const (
+	FieldTitle   = "title"
+	FieldNotes   = "notes"
+	FieldTag     = "tag"
+	FieldProject = "project"
+	FieldArea    = "area"
+	FieldStatus  = "status"
+	FieldDue     = "due"
+	FieldWhen    = "when"
+	FieldCreated = "created"
+	FieldHas     = "has"
+)
+
+// conditionPattern splits an unquoted condition into field, operator and value.
+var conditionPattern = regexp.MustCompile(`^([A-Za-z_]+)(!=|<=|>=|:|=|<|>)(.*)$`)
+
+// Query is a parsed smart list query.
+type Query struct {
+	root       node
+	usesStatus bool
+}
+
+// UsesStatus reports whether the query has a status condition. Queries
+// without one only match open to-dos, so callers may skip loading closed ones.
+func (q *Query) UsesStatus() bool {
+	return q.usesStatus
+}
+
+type tokenKind int
+
+const (
+	tokCondition tokenKind = iota
+	tokText
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind  tokenKind
+	field string
+	op    string
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokCondition:
+		return t.field + t.op + t.value
+	default:
+		return t.value
+	}
+}
+
+// Parse parses a smart list query.
+func Parse(q string) (*Query, error) {
+	toks, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].String())
+	}
+	return &Query{root: root, usesStatus: p.usesStatus}, nil
+}
+
+func lex(q string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(q); {
+		switch c := q[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen})
+			i++
+		case c == '"':
+			value, n, err := readQuoted(q[i:])
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokText, value: value})
+			i += n
+		default:
+			start := i
+			for i < len(q) && !strings.ContainsRune(" \t\n\r()\"", rune(q[i])) {
+				i++
+			}
+			word := q[start:i]
+
+			switch word {
+			case "AND":
+				toks = append(toks, token{kind: tokAnd})
+				continue
+			case "OR":
+				toks = append(toks, token{kind: tokOr})
+				continue
+			case "NOT":
+				toks = append(toks, token{kind: tokNot})
+				continue
+			}
+
+			m := conditionPattern.FindStringSubmatch(word)
+			if m == nil {
+				if i < len(q) && q[i] == '"' {
+					return nil, fmt.Errorf("unexpected quote after %q", word)
+				}
+				toks = append(toks, token{kind: tokText, value: word})
+				continue
+			}
+
+			tok := token{kind: tokCondition, field: strings.ToLower(m[1]), op: m[2], value: m[3]}
+			if tok.value == "" && i < len(q) && q[i] == '"' {
+				value, n, err := readQuoted(q[i:])
+				if err != nil {
+					return nil, err
+				}
+				tok.value = value
+				i += n
+			} else if tok.value == "" {
+				return nil, fmt.Errorf("missing value in %q", word)
+			}
+			toks = append(toks, tok)
+		}
+	}
+	return toks, nil
+}
+
+// readQuoted reads a double-quoted string at the start of s and returns its
+// contents and the number of bytes consumed.
+func readQuoted(s string) (string, int, error) {
+	end := strings.IndexByte(s[1:], '"')
+	if end < 0 {
+		return "", 0, fmt.Errorf("unterminated quoted string")
+	}
+	return s[1 : end+1], end + 2, nil
+}
+
+type parser struct {
+	toks       []token
+	pos        int
+	usesStatus bool
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.toks) {
+		return token{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokOr {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+}
+
+// parseAnd parses: not (["AND"] not)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokOr || tok.kind == tokRParen {
+			return left, nil
+		}
+		if tok.kind == tokAnd {
+			p.pos++
+		}
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+// parseNot parses: "NOT" not | primary
+func (p *parser) parseNot() (node, error) {
+	if tok, ok := p.peek(); ok && tok.kind == tokNot {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: "(" or ")" | condition | text
+func (p *parser) parsePrimary() (node, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	p.pos++
+
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokRParen {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return inner, nil
+	case tokText:
+		return textNode{strings.ToLower(tok.value)}, nil
+	case tokCondition:
+		if tok.field == FieldStatus {
+			p.usesStatus = true
+		}
+		return newCondition(tok.field, tok.op, tok.value)
+	default:
+		return nil, fmt.Errorf("unexpected %q", tok.String())
+	}
+}
+
+// newCondition validates a condition and returns its node.
+func newCondition(field, op, value string) (node, error) {
+	equality := op == ":" || op == "=" || op == "!="
+	c := condition{field: field, op: op, value: value}
+
+	switch field {
+	case FieldTitle, FieldNotes, FieldTag, FieldProject, FieldArea:
+		if !equality {
+			return nil, fmt.Errorf("operator %q is not supported for %s", op, field)
+		}
+		c.value = strings.ToLower(value)
+	case FieldStatus:
+		if !equality {
+			return nil, fmt.Errorf("operator %q is not supported for %s", op, field)
+		}
+		c.value = strings.ToLower(value)
+		switch c.value {
+		case "open", "completed", "canceled":
+		default:
+			return nil, fmt.Errorf("status must be one of: open, completed, canceled")
+		}
+	case FieldDue, FieldWhen, FieldCreated:
+		c.value = strings.ToLower(value)
+		if field == FieldWhen && isWhenBucket(c.value) {
+			if !equality {
+				return nil, fmt.Errorf("operator %q is not supported for when:%s", op, c.value)
+			}
+			break
+		}
+		if _, err := resolveDate(c.value, time.Now()); err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+	case FieldHas:
+		if op != ":" {
+			return nil, fmt.Errorf("has only supports the : operator")
+		}
+		c.value = strings.ToLower(value)
+		switch c.value {
+		case FieldDue, FieldWhen, FieldNotes, FieldProject, FieldArea, "tags", "checklist":
+		default:
+			return nil, fmt.Errorf("has must be one of: due, when, notes, project, area, tags, checklist")
+		}
+	default:
+		return nil, fmt.Errorf("unknown field %q", field)
+	}
+	return c, nil
+}
@@ -0,0 +1,71 @@
+package smartlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "query is empty"},
+		{"   ", "query is empty"},
+		{`title:"milk`, "unterminated quoted string"},
+		{`"milk`, "unterminated quoted string"},
+		{`milk"bread"`, `unexpected quote after "milk"`},
+		{"tag:", `missing value in "tag:"`},
+		{"color:red", `unknown field "color"`},
+		{"tag<work", `operator "<" is not supported for tag`},
+		{"status:done", "status must be one of: open, completed, canceled"},
+		{"status>open", `operator ">" is not supported for status`},
+		{"has=due", "has only supports the : operator"},
+		{"has:color", "has must be one of"},
+		{"due<soon", `due: invalid date "soon"`},
+		{"when<someday", `operator "<" is not supported for when:someday`},
+		{"(tag:work", "missing closing parenthesis"},
+		{"tag:work)", `unexpected ")"`},
+		{"tag:work OR", "unexpected end of query"},
+		{"NOT", "unexpected end of query"},
+		{"AND tag:work", `unexpected "AND"`},
+		{"()", `unexpected ")"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Parse(%q) error = %v, want %q", tt.query, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query      string
+		usesStatus bool
+	}{
+		{"milk", false},
+		{`"buy milk"`, false},
+		{`project:"Home Renovation"`, false},
+		{`project:"Home Renovation" OR tag:errand`, false},
+		{"tag:work AND due<+3d AND NOT status:completed", true},
+		{"(tag:work OR tag:home) has:due", false},
+		{"NOT NOT tag:work", false},
+		{"TAG:Work", false},
+		{"due>=2026-10-01 due<=yesterday created>-2w when:someday when=tomorrow", false},
+		{"status!=canceled", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			if q.UsesStatus() != tt.usesStatus {
+				t.Errorf("UsesStatus() = %v, want %v", q.UsesStatus(), tt.usesStatus)
+			}
+		})
+	}
+}
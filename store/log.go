@@ -0,0 +1,232 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// compactLines is the number of lines a log file may hold before it is
+// rewritten, as long as most of them are still live.
+const compactLines = 1000
+
+// Log is a set of records keyed by ID, persisted as an append-only file of
+// JSON lines: each change appends a line instead of rewriting the file,
+// which is rewritten with only the live records once most of its lines are
+// stale. It suits records that change often, such as a delivery queue.
+type Log[T any] struct {
+	mu    sync.RWMutex
+	path  string
+	file  *os.File
+	items map[string]T
+	lines int // lines in the file
+}
+
+// logLine is one change: a record, or its deletion when Record is nil.
+type logLine[T any] struct {
+	ID     string `json:"id"`
+	Record *T     `json:"record,omitempty"`
+}
+
+// OpenLog loads the log stored at path, creating parent directories as
+// needed, and compacts it. A missing file yields an empty log. A partly
+// written last line, left by a crash, is dropped.
+func OpenLog[T any](path string) (*Log[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create store directory: %w", err)
+	}
+
+	l := &Log[T]{path: path, items: make(map[string]T)}
+	f, err := os.Open(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, fmt.Errorf("cannot read store %s: %w", path, err)
+	default:
+		defer f.Close()
+		r := bufio.NewReader(f)
+		for {
+			data, err := r.ReadBytes('\n')
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cannot read store %s: %w", path, err)
+			}
+			var line logLine[T]
+			if err := json.Unmarshal(data, &line); err != nil {
+				return nil, fmt.Errorf("cannot parse store %s: %w", path, err)
+			}
+			if line.Record == nil {
+				delete(l.items, line.ID)
+			} else {
+				l.items[line.ID] = *line.Record
+			}
+		}
+	}
+
+	if err := l.compact(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close closes the log file.
+func (l *Log[T]) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// List returns all records ordered by ID.
+func (l *Log[T]) List() []T {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	ids := make([]string, 0, len(l.items))
+	for id := range l.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]T, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, l.items[id])
+	}
+	return out
+}
+
+// Get returns the record with the given ID.
+func (l *Log[T]) Get(id string) (T, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	v, ok := l.items[id]
+	return v, ok
+}
+
+// Put inserts or replaces the record with the given ID and appends it to
+// the file.
+func (l *Log[T]) Put(id string, v T) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := l.append([]logLine[T]{{ID: id, Record: &v}}); err != nil {
+		return err
+	}
+	l.items[id] = v
+	return l.maybeCompact()
+}
+
+// Delete removes the record with the given ID. Returns false if it did not exist.
+func (l *Log[T]) Delete(id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.items[id]; !ok {
+		return false, nil
+	}
+	if err := l.append([]logLine[T]{{ID: id}}); err != nil {
+		return false, err
+	}
+	delete(l.items, id)
+	return true, l.maybeCompact()
+}
+
+// DeleteWhere removes every record for which match returns true, appending
+// the deletions to the file in one write, and returns how many it removed.
+func (l *Log[T]) DeleteWhere(match func(T) bool) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lines []logLine[T]
+	for id, v := range l.items {
+		if match(v) {
+			lines = append(lines, logLine[T]{ID: id})
+		}
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if err := l.append(lines); err != nil {
+		return 0, err
+	}
+	for _, line := range lines {
+		delete(l.items, line.ID)
+	}
+	return len(lines), l.maybeCompact()
+}
+
+// append writes lines to the file in one write. Caller holds mu.
+func (l *Log[T]) append(lines []logLine[T]) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			return fmt.Errorf("cannot encode store: %w", err)
+		}
+	}
+	if _, err := l.file.Write(buf.Bytes()); err != nil {
+		// Rewrite the file so that a partly written line does not stay
+		// in it.
+		l.compact()
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	l.lines += len(lines)
+	return nil
+}
+
+// maybeCompact compacts the file once most of its lines are stale. Caller
+// holds mu.
+func (l *Log[T]) maybeCompact() error {
+	if l.lines < compactLines || l.lines < 2*len(l.items) {
+		return nil
+	}
+	return l.compact()
+}
+
+// compact rewrites the file with one line per live record (temp file +
+// rename) and reopens it for appending. Caller holds mu or owns l.
+func (l *Log[T]) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for id, v := range l.items {
+		v := v
+		if err := enc.Encode(logLine[T]{ID: id, Record: &v}); err != nil {
+			return fmt.Errorf("cannot encode store: %w", err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".store-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, l.lines = f, len(l.items)
+	return nil
+}
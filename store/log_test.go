@@ -0,0 +1,89 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type record struct {
+	Name string `json:"name"`
+}
+
+func lineCount(t *testing.T, path string) int {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := OpenLog[record](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct{ id, name string }{{"a", "one"}, {"b", "two"}, {"a", "three"}, {"c", "four"}} {
+		if err := l.Put(step.id, record{step.name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ok, err := l.Delete("b"); !ok || err != nil {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if n, err := l.DeleteWhere(func(r record) bool { return r.Name == "four" }); n != 1 || err != nil {
+		t.Fatalf("DeleteWhere = %d, %v", n, err)
+	}
+	if got := lineCount(t, path); got != 6 {
+		t.Errorf("file has %d lines, want one per change (6)", got)
+	}
+	l.Close()
+
+	// A crash can leave a partly written last line.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"d","record":{"na`)
+	f.Close()
+
+	l, err = OpenLog[record](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if got, want := l.List(), []record{{"three"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if got := lineCount(t, path); got != 1 {
+		t.Errorf("reopened file has %d lines, want it compacted to 1", got)
+	}
+}
+
+func TestLogCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	l, err := OpenLog[record](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for i := 0; i < compactLines; i++ {
+		if err := l.Put("a", record{"x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := lineCount(t, path); got != 1 {
+		t.Errorf("file has %d lines after %d updates of one record, want 1", got, compactLines)
+	}
+	if err := l.Put("b", record{"y"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(l.List()); got != 2 {
+		t.Errorf("List has %d records after compaction, want 2", got)
+	}
+}
@@ -0,0 +1,229 @@
+// Package store provides small file-backed persistence for server-side
+// resources (templates, smart lists, ...). Each collection is kept in memory
+// and written to a single JSON file on every change; a Log appends each
+// change to a file of JSON lines instead.
+package store
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Collection is a set of records keyed by ID, persisted as one JSON file.
+type Collection[T any] struct {
+	mu    sync.RWMutex
+	path  string
+	items map[string]T
+
+	// modTime and size identify the file version last read or written, so
+	// Reload can tell when another process changed it.
+	modTime time.Time
+	size    int64
+}
+
+// Open loads the collection stored at path, creating parent directories as
+// needed. A missing file yields an empty collection.
+func Open[T any](path string) (*Collection[T], error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("cannot create store directory: %w", err)
+	}
+
+	c := &Collection[T]{path: path, items: make(map[string]T)}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload re-reads the file if another process changed it since it was last
+// read or written, and reports whether it did. A removed file empties the
+// collection.
+func (c *Collection[T]) Reload() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	info, err := os.Stat(c.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if c.modTime.IsZero() {
+			return false, nil
+		}
+	case err != nil:
+		return false, fmt.Errorf("cannot read store %s: %w", c.path, err)
+	case info.ModTime().Equal(c.modTime) && info.Size() == c.size:
+		return false, nil
+	}
+	if err := c.load(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// load replaces the records with the file's contents. Caller holds mu or owns c.
+func (c *Collection[T]) load() error {
+	items := make(map[string]T)
+	f, err := os.Open(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		c.items, c.modTime, c.size = items, time.Time{}, 0
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read store %s: %w", c.path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read store %s: %w", c.path, err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("cannot read store %s: %w", c.path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("cannot parse store %s: %w", c.path, err)
+		}
+	}
+	c.items, c.modTime, c.size = items, info.ModTime(), info.Size()
+	return nil
+}
+
+// List returns all records ordered by ID.
+func (c *Collection[T]) List() []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ids := make([]string, 0, len(c.items))
+	for id := range c.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]T, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, c.items[id])
+	}
+	return out
+}
+
+// Get returns the record with the given ID.
+func (c *Collection[T]) Get(id string) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v, ok := c.items[id]
+	return v, ok
+}
+
+// Put inserts or replaces the record with the given ID and persists the collection.
+func (c *Collection[T]) Put(id string, v T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, existed := c.items[id]
+	c.items[id] = v
+	if err := c.save(); err != nil {
+		if existed {
+			c.items[id] = prev
+		} else {
+			delete(c.items, id)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the record with the given ID. Returns false if it did not exist.
+func (c *Collection[T]) Delete(id string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, ok := c.items[id]
+	if !ok {
+		return false, nil
+	}
+	delete(c.items, id)
+	if err := c.save(); err != nil {
+		c.items[id] = prev
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteWhere removes every record for which match returns true, persisting
+// the collection once, and returns how many it removed.
+func (c *Collection[T]) DeleteWhere(match func(T) bool) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	removed := make(map[string]T)
+	for id, v := range c.items {
+		if match(v) {
+			removed[id] = v
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	for id := range removed {
+		delete(c.items, id)
+	}
+	if err := c.save(); err != nil {
+		for id, v := range removed {
+			c.items[id] = v
+		}
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+// save writes the collection atomically (temp file + rename). Caller holds mu.
+func (c *Collection[T]) save() error {
+	data, err := json.MarshalIndent(c.items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".store-*.json")
+	if err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("cannot write store: %w", err)
+	}
+	if info, err := os.Stat(c.path); err == nil {
+		c.modTime, c.size = info.ModTime(), info.Size()
+	}
+	return nil
+}
+
+// NewID returns a random 22-character base62 ID, matching the format of
+// Things IDs so it passes models.ValidateThingsID.
+func NewID() string {
+	const chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 22)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b)
+}
@@ -0,0 +1,189 @@
+// Package templates renders stored project templates into Things JSON items,
+// substituting {{variables}} and resolving relative dates.
+package templates
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/thingsurl"
+)
+
+const dateLayout = "2006-01-02"
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Options controls how a template is instantiated.
+type Options struct {
+	AreaID    string
+	Variables map[string]string
+	Start     time.Time // anchor for relative dates such as "+3d"
+	Deadline  string    // overrides the template's project deadline (YYYY-MM-DD)
+}
+
+// Render builds the Things JSON project item for tmpl. Titles and notes of
+// the project, headings, tasks and checklist items have placeholders
+// replaced. The built-in variable "start_date" is always available.
+// Returns an error listing any placeholders without a value.
+func Render(tmpl models.Template, opts Options) (thingsurl.Item, error) {
+	vars := map[string]string{"start_date": opts.Start.Format(dateLayout)}
+	for k, v := range opts.Variables {
+		vars[k] = v
+	}
+	r := &renderer{vars: vars, start: opts.Start, missing: make(map[string]bool)}
+
+	p := tmpl.Project
+	deadline := opts.Deadline
+	if deadline == "" && p.Deadline != "" {
+		d, err := r.date(p.Deadline)
+		if err != nil {
+			return thingsurl.Item{}, fmt.Errorf("project.deadline: %w", err)
+		}
+		deadline = d
+	}
+	r.deadline = deadline
+
+	when, err := r.date(p.When)
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("project.when: %w", err)
+	}
+
+	project := thingsurl.Item{
+		Type: thingsurl.TypeProject,
+		Attributes: thingsurl.Attributes{
+			Title:    r.text(p.Title),
+			Notes:    r.text(p.Notes),
+			When:     when,
+			Deadline: deadline,
+			Tags:     p.Tags,
+			AreaID:   opts.AreaID,
+		},
+	}
+
+	for i, t := range p.Tasks {
+		item, err := r.task(t)
+		if err != nil {
+			return thingsurl.Item{}, fmt.Errorf("project.tasks[%d]: %w", i, err)
+		}
+		project.Attributes.Items = append(project.Attributes.Items, item)
+	}
+	for i, h := range p.Headings {
+		project.Attributes.Items = append(project.Attributes.Items, thingsurl.Item{
+			Type:       thingsurl.TypeHeading,
+			Attributes: thingsurl.Attributes{Title: r.text(h.Title)},
+		})
+		for j, t := range h.Tasks {
+			item, err := r.task(t)
+			if err != nil {
+				return thingsurl.Item{}, fmt.Errorf("project.headings[%d].tasks[%d]: %w", i, j, err)
+			}
+			project.Attributes.Items = append(project.Attributes.Items, item)
+		}
+	}
+
+	if len(r.missing) > 0 {
+		names := make([]string, 0, len(r.missing))
+		for name := range r.missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return thingsurl.Item{}, fmt.Errorf("missing template variables: %s", strings.Join(names, ", "))
+	}
+	return project, nil
+}
+
+type renderer struct {
+	vars     map[string]string
+	start    time.Time
+	deadline string
+	missing  map[string]bool
+}
+
+func (r *renderer) task(t models.TemplateTask) (thingsurl.Item, error) {
+	when, err := r.date(t.When)
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("when: %w", err)
+	}
+	deadline, err := r.date(t.Deadline)
+	if err != nil {
+		return thingsurl.Item{}, fmt.Errorf("deadline: %w", err)
+	}
+
+	item := thingsurl.Item{
+		Type: thingsurl.TypeToDo,
+		Attributes: thingsurl.Attributes{
+			Title:    r.text(t.Title),
+			Notes:    r.text(t.Notes),
+			When:     when,
+			Deadline: deadline,
+			Tags:     t.Tags,
+		},
+	}
+	for _, title := range t.ChecklistItems {
+		item.Attributes.ChecklistItems = append(item.Attributes.ChecklistItems, thingsurl.Item{
+			Type:       thingsurl.TypeChecklistItem,
+			Attributes: thingsurl.Attributes{Title: r.text(title)},
+		})
+	}
+	return item, nil
+}
+
+// text substitutes {{name}} placeholders, recording names without a value.
+func (r *renderer) text(s string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		v, ok := r.vars[name]
+		if !ok {
+			r.missing[name] = true
+			return m
+		}
+		return v
+	})
+}
+
+// date resolves a template date. Keywords (today, someday, ...) and absolute
+// dates pass through; relative dates are resolved to YYYY-MM-DD.
+func (r *renderer) date(s string) (string, error) {
+	if s == "" || !models.TemplateDatePattern.MatchString(s) {
+		return s, nil
+	}
+	m := models.TemplateDatePattern.FindStringSubmatch(s)
+	anchor, offset := m[1], m[2]
+
+	base := r.start
+	if anchor == "deadline" {
+		if r.deadline == "" {
+			return "", fmt.Errorf("%q is relative to the project deadline, but the project has none", s)
+		}
+		d, err := time.Parse(dateLayout, r.deadline)
+		if err != nil {
+			return "", fmt.Errorf("invalid project deadline %q", r.deadline)
+		}
+		base = d
+	}
+	if offset == "" {
+		return base.Format(dateLayout), nil
+	}
+
+	n, err := strconv.Atoi(offset[1 : len(offset)-1])
+	if err != nil {
+		return "", fmt.Errorf("invalid offset %q", offset)
+	}
+	if offset[0] == '-' {
+		n = -n
+	}
+	switch offset[len(offset)-1] {
+	case 'd':
+		base = base.AddDate(0, 0, n)
+	case 'w':
+		base = base.AddDate(0, 0, 7*n)
+	case 'm':
+		base = base.AddDate(0, n, 0)
+	}
+	return base.Format(dateLayout), nil
+}
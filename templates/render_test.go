@@ -0,0 +1,100 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+)
+
+func TestRelativeDates(t *testing.T) {
+	start := time.Date(2026, time.January, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		date     string
+		deadline string
+		want     string
+		wantErr  bool
+	}{
+		{date: "", want: ""},
+		{date: "today", want: "today"},
+		{date: "someday", want: "someday"},
+		{date: "2026-03-01", want: "2026-03-01"},
+		{date: "start", want: "2026-01-31"},
+		{date: "+3d", want: "2026-02-03"},
+		{date: "start-1d", want: "2026-01-30"},
+		{date: "+2w", want: "2026-02-14"},
+		{date: "+1m", want: "2026-03-03"},
+		{date: "deadline", deadline: "2026-02-10", want: "2026-02-10"},
+		{date: "deadline-1w", deadline: "2026-02-10", want: "2026-02-03"},
+		{date: "deadline-1d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			r := &renderer{start: start, deadline: tt.deadline}
+			got, err := r.date(tt.date)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("date(%q) = %q, want %q", tt.date, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl := models.Template{Project: models.TemplateProject{
+		Title:    "Launch {{product}}",
+		Notes:    "Starts {{ start_date }}",
+		Deadline: "+2w",
+		Tasks: []models.TemplateTask{
+			{Title: "Announce {{product}}", When: "deadline-1d", ChecklistItems: []string{"Email {{team}}"}},
+		},
+		Headings: []models.TemplateHeading{
+			{Title: "{{team}} tasks", Tasks: []models.TemplateTask{{Title: "Review", Deadline: "deadline"}}},
+		},
+	}}
+	start := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+
+	t.Run("variables and dates", func(t *testing.T) {
+		item, err := Render(tmpl, Options{Start: start, Variables: map[string]string{"product": "Widget", "team": "Ops"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := item.Attributes
+		if a.Title != "Launch Widget" || a.Notes != "Starts 2026-03-02" || a.Deadline != "2026-03-16" {
+			t.Errorf("project = %q, %q, %q", a.Title, a.Notes, a.Deadline)
+		}
+		if len(a.Items) != 3 {
+			t.Fatalf("%d items, want 3", len(a.Items))
+		}
+		task, heading, review := a.Items[0].Attributes, a.Items[1].Attributes, a.Items[2].Attributes
+		if task.Title != "Announce Widget" || task.When != "2026-03-15" || task.ChecklistItems[0].Attributes.Title != "Email Ops" {
+			t.Errorf("task = %q, %q, %q", task.Title, task.When, task.ChecklistItems[0].Attributes.Title)
+		}
+		if heading.Title != "Ops tasks" {
+			t.Errorf("heading = %q", heading.Title)
+		}
+		if review.Deadline != "2026-03-16" {
+			t.Errorf("review deadline = %q", review.Deadline)
+		}
+	})
+
+	t.Run("deadline override", func(t *testing.T) {
+		item, err := Render(tmpl, Options{Start: start, Deadline: "2026-04-01", Variables: map[string]string{"product": "Widget", "team": "Ops"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := item.Attributes.Items[0].Attributes.When; got != "2026-03-31" {
+			t.Errorf("task when = %q, want 2026-03-31", got)
+		}
+	})
+
+	t.Run("missing variables", func(t *testing.T) {
+		_, err := Render(tmpl, Options{Start: start})
+		if err == nil || !strings.HasSuffix(err.Error(), "missing template variables: product, team") {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,268 @@
+package thingsurl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Add creates one or more to-dos (things:///add).
+type Add struct {
+	Title          string
+	Titles         []string // creates one to-do per title; overrides Title
+	Notes          string
+	When           string // today, tomorrow, evening, anytime, someday, a date or date time
+	Deadline       string
+	Tags           []string
+	ChecklistItems []string
+	List           string // project or area title
+	ListID         string // project or area ID; takes precedence over List
+	Heading        string
+	HeadingID      string
+	Completed      bool
+	Canceled       bool
+	ShowQuickEntry bool
+	Reveal         bool
+	CreationDate   string
+	CompletionDate string
+}
+
+func (Add) Name() string { return "add" }
+
+func (c Add) Values() url.Values {
+	params := url.Values{}
+	if len(c.Titles) > 0 {
+		setList(params, "titles", c.Titles, "\n")
+	} else {
+		setString(params, "title", c.Title)
+	}
+	setString(params, "notes", c.Notes)
+	setString(params, "when", c.When)
+	setString(params, "deadline", c.Deadline)
+	setList(params, "tags", c.Tags, ",")
+	setList(params, "checklist-items", c.ChecklistItems, "\n")
+	setString(params, "list", c.List)
+	setString(params, "list-id", c.ListID)
+	setString(params, "heading", c.Heading)
+	setString(params, "heading-id", c.HeadingID)
+	setBool(params, "completed", c.Completed)
+	setBool(params, "canceled", c.Canceled)
+	params.Set("show-quick-entry", fmt.Sprintf("%t", c.ShowQuickEntry))
+	setBool(params, "reveal", c.Reveal)
+	setString(params, "creation-date", c.CreationDate)
+	setString(params, "completion-date", c.CompletionDate)
+	return params
+}
+
+func (c Add) validate() error {
+	if c.Title == "" && len(c.Titles) == 0 {
+		return fmt.Errorf("title is required")
+	}
+	return nil
+}
+
+// AddProject creates a project (things:///add-project).
+type AddProject struct {
+	Title          string
+	Notes          string
+	When           string
+	Deadline       string
+	Tags           []string
+	Area           string
+	AreaID         string // takes precedence over Area
+	ToDos          []string
+	Completed      bool
+	Canceled       bool
+	Reveal         bool
+	CreationDate   string
+	CompletionDate string
+}
+
+func (AddProject) Name() string { return "add-project" }
+
+func (c AddProject) Values() url.Values {
+	params := url.Values{}
+	setString(params, "title", c.Title)
+	setString(params, "notes", c.Notes)
+	setString(params, "when", c.When)
+	setString(params, "deadline", c.Deadline)
+	setList(params, "tags", c.Tags, ",")
+	setString(params, "area", c.Area)
+	setString(params, "area-id", c.AreaID)
+	setList(params, "to-dos", c.ToDos, "\n")
+	setBool(params, "completed", c.Completed)
+	setBool(params, "canceled", c.Canceled)
+	setBool(params, "reveal", c.Reveal)
+	setString(params, "creation-date", c.CreationDate)
+	setString(params, "completion-date", c.CompletionDate)
+	return params
+}
+
+func (c AddProject) validate() error {
+	if c.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	return nil
+}
+
+// Update modifies an existing to-do (things:///update). Requires an auth token.
+// Pointer fields are only sent when non-nil, so an empty string clears the
+// field in Things. Nil slices are left unchanged; an empty non-nil slice clears.
+type Update struct {
+	ID                    string
+	Title                 *string
+	Notes                 *string
+	PrependNotes          string
+	AppendNotes           string
+	When                  *string
+	Deadline              *string
+	Tags                  []string
+	AddTags               []string
+	ChecklistItems        []string
+	PrependChecklistItems []string
+	AppendChecklistItems  []string
+	List                  string
+	ListID                string
+	Heading               string
+	HeadingID             string
+	Completed             *bool
+	Canceled              *bool
+	Reveal                bool
+	Duplicate             bool
+	CreationDate          string
+	CompletionDate        string
+}
+
+func (Update) Name() string { return "update" }
+
+func (c Update) Values() url.Values {
+	params := url.Values{}
+	params.Set("id", c.ID)
+	setOptional(params, "title", c.Title)
+	setOptional(params, "notes", c.Notes)
+	setString(params, "prepend-notes", c.PrependNotes)
+	setString(params, "append-notes", c.AppendNotes)
+	setOptional(params, "when", c.When)
+	setOptional(params, "deadline", c.Deadline)
+	if c.Tags != nil {
+		params.Set("tags", strings.Join(c.Tags, ","))
+	}
+	setList(params, "add-tags", c.AddTags, ",")
+	if c.ChecklistItems != nil {
+		params.Set("checklist-items", strings.Join(c.ChecklistItems, "\n"))
+	}
+	setList(params, "prepend-checklist-items", c.PrependChecklistItems, "\n")
+	setList(params, "append-checklist-items", c.AppendChecklistItems, "\n")
+	setString(params, "list", c.List)
+	setString(params, "list-id", c.ListID)
+	setString(params, "heading", c.Heading)
+	setString(params, "heading-id", c.HeadingID)
+	setOptionalBool(params, "completed", c.Completed)
+	setOptionalBool(params, "canceled", c.Canceled)
+	setBool(params, "reveal", c.Reveal)
+	setBool(params, "duplicate", c.Duplicate)
+	setString(params, "creation-date", c.CreationDate)
+	setString(params, "completion-date", c.CompletionDate)
+	return params
+}
+
+func (c Update) validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	return nil
+}
+
+// UpdateProject modifies an existing project (things:///update-project).
+// Requires an auth token. Field semantics match Update.
+type UpdateProject struct {
+	ID             string
+	Title          *string
+	Notes          *string
+	PrependNotes   string
+	AppendNotes    string
+	When           *string
+	Deadline       *string
+	Tags           []string
+	AddTags        []string
+	Area           string
+	AreaID         string
+	Completed      *bool
+	Canceled       *bool
+	Reveal         bool
+	Duplicate      bool
+	CreationDate   string
+	CompletionDate string
+}
+
+func (UpdateProject) Name() string { return "update-project" }
+
+func (c UpdateProject) Values() url.Values {
+	params := url.Values{}
+	params.Set("id", c.ID)
+	setOptional(params, "title", c.Title)
+	setOptional(params, "notes", c.Notes)
+	setString(params, "prepend-notes", c.PrependNotes)
+	setString(params, "append-notes", c.AppendNotes)
+	setOptional(params, "when", c.When)
+	setOptional(params, "deadline", c.Deadline)
+	if c.Tags != nil {
+		params.Set("tags", strings.Join(c.Tags, ","))
+	}
+	setList(params, "add-tags", c.AddTags, ",")
+	setString(params, "area", c.Area)
+	setString(params, "area-id", c.AreaID)
+	setOptionalBool(params, "completed", c.Completed)
+	setOptionalBool(params, "canceled", c.Canceled)
+	setBool(params, "reveal", c.Reveal)
+	setBool(params, "duplicate", c.Duplicate)
+	setString(params, "creation-date", c.CreationDate)
+	setString(params, "completion-date", c.CompletionDate)
+	return params
+}
+
+func (c UpdateProject) validate() error {
+	if c.ID == "" {
+		return fmt.Errorf("id is required")
+	}
+	return nil
+}
+
+// Show navigates to a list, project, area, tag or to-do (things:///show).
+type Show struct {
+	ID     string   // item ID or built-in list ID (inbox, today, anytime, ...)
+	Query  string   // area, project, tag or built-in list name; ignored if ID is set
+	Filter []string // tag names to filter the list by
+}
+
+func (Show) Name() string { return "show" }
+
+func (c Show) Values() url.Values {
+	params := url.Values{}
+	setString(params, "id", c.ID)
+	if c.ID == "" {
+		setString(params, "query", c.Query)
+	}
+	setList(params, "filter", c.Filter, ",")
+	return params
+}
+
+func (c Show) validate() error {
+	if c.ID == "" && c.Query == "" {
+		return fmt.Errorf("id or query is required")
+	}
+	return nil
+}
+
+// Search opens the Things search screen (things:///search).
+type Search struct {
+	Query string
+}
+
+func (Search) Name() string { return "search" }
+
+func (c Search) Values() url.Values {
+	params := url.Values{}
+	setString(params, "query", c.Query)
+	return params
+}
@@ -0,0 +1,92 @@
+package thingsurl
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Item types accepted by the things:///json command.
+const (
+	TypeToDo          = "to-do"
+	TypeProject       = "project"
+	TypeHeading       = "heading"
+	TypeChecklistItem = "checklist-item"
+)
+
+// Operations accepted on top-level to-do and project items.
+const (
+	OperationCreate = "create"
+	OperationUpdate = "update"
+)
+
+// Item is one object in the Things JSON format. Items nest: projects hold
+// to-dos and headings in Attributes.Items, to-dos hold checklist items in
+// Attributes.ChecklistItems.
+type Item struct {
+	Type       string     `json:"type"`
+	Operation  string     `json:"operation,omitempty"`
+	ID         string     `json:"id,omitempty"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Attributes holds the union of attributes across all item types. Which
+// attributes are meaningful depends on the item type.
+type Attributes struct {
+	Title                 string   `json:"title,omitempty"`
+	Notes                 string   `json:"notes,omitempty"`
+	PrependNotes          string   `json:"prepend-notes,omitempty"`
+	AppendNotes           string   `json:"append-notes,omitempty"`
+	When                  string   `json:"when,omitempty"`
+	Deadline              string   `json:"deadline,omitempty"`
+	Tags                  []string `json:"tags,omitempty"`
+	AddTags               []string `json:"add-tags,omitempty"`
+	ChecklistItems        []Item   `json:"checklist-items,omitempty"`
+	PrependChecklistItems []Item   `json:"prepend-checklist-items,omitempty"`
+	AppendChecklistItems  []Item   `json:"append-checklist-items,omitempty"`
+	ListID                string   `json:"list-id,omitempty"`
+	List                  string   `json:"list,omitempty"`
+	HeadingID             string   `json:"heading-id,omitempty"`
+	Heading               string   `json:"heading,omitempty"`
+	AreaID                string   `json:"area-id,omitempty"`
+	Area                  string   `json:"area,omitempty"`
+	Items                 []Item   `json:"items,omitempty"`
+	Archived              bool     `json:"archived,omitempty"`
+	Completed             bool     `json:"completed,omitempty"`
+	Canceled              bool     `json:"canceled,omitempty"`
+	CreationDate          string   `json:"creation-date,omitempty"`
+	CompletionDate        string   `json:"completion-date,omitempty"`
+}
+
+// JSON imports a list of items in one call (things:///json). An auth token
+// is required when any item uses the update operation.
+type JSON struct {
+	Items  []Item
+	Reveal bool
+}
+
+func (JSON) Name() string { return "json" }
+
+func (c JSON) Values() url.Values {
+	params := url.Values{}
+	data, _ := json.Marshal(c.Items)
+	params.Set("data", string(data))
+	setBool(params, "reveal", c.Reveal)
+	return params
+}
+
+func (c JSON) validate() error {
+	if len(c.Items) == 0 {
+		return fmt.Errorf("at least one item is required")
+	}
+	return nil
+}
+
+func (c JSON) hasUpdates() bool {
+	for _, item := range c.Items {
+		if item.Operation == OperationUpdate {
+			return true
+		}
+	}
+	return false
+}
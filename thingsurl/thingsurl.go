@@ -0,0 +1,162 @@
+// Package thingsurl builds and dispatches Things 3 URL scheme commands
+// (things:///add, things:///update, things:///json, ...).
+//
+// The URL scheme covers features AppleScript lacks, such as checklist items,
+// headings and bulk JSON import. Commands are plain structs that encode to a
+// URL; dispatching goes through an Opener so the generated URLs can be
+// inspected without Things 3 installed.
+package thingsurl
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// Command is a single Things URL scheme command.
+type Command interface {
+	// Name returns the command name, e.g. "add" or "update-project".
+	Name() string
+	// Values returns the query parameters for the command, excluding auth-token.
+	Values() url.Values
+}
+
+// Opener opens a things:/// URL.
+type Opener func(thingsURL string) error
+
+// DefaultOpener is used by clients created without an explicit Opener.
+// It can be swapped to capture URLs instead of launching Things.
+var DefaultOpener Opener = OpenWithAppleScript
+
+// OpenWithAppleScript opens a things:/// URL via AppleScript `open location`.
+func OpenWithAppleScript(thingsURL string) error {
+	script := fmt.Sprintf(`open location "%s"`, thingsURL)
+	out, err := exec.Command("osascript", "-e", script).CombinedOutput()
+	if err != nil {
+		errMsg := strings.TrimSpace(string(out))
+		if errMsg == "" {
+			errMsg = err.Error()
+		}
+		return fmt.Errorf("failed to open things URL: %s", errMsg)
+	}
+	return nil
+}
+
+// Client builds command URLs and dispatches them through an Opener.
+type Client struct {
+	authToken string
+	opener    Opener
+}
+
+// NewClient returns a Client that attaches authToken to commands that modify
+// existing items. A nil opener means DefaultOpener at dispatch time.
+func NewClient(authToken string, opener Opener) *Client {
+	return &Client{authToken: authToken, opener: opener}
+}
+
+// URL validates cmd and returns the full things:/// URL for it.
+func (c *Client) URL(cmd Command) (string, error) {
+	if v, ok := cmd.(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			return "", fmt.Errorf("invalid %s command: %w", cmd.Name(), err)
+		}
+	}
+
+	params := cmd.Values()
+	if requiresAuth(cmd) {
+		if c.authToken == "" {
+			return "", fmt.Errorf("%s command requires a Things URL auth token", cmd.Name())
+		}
+		params.Set("auth-token", c.authToken)
+	}
+	return Build(cmd.Name(), params), nil
+}
+
+// Preview returns the URL for cmd like URL does, but with a placeholder in
+// place of the auth token so that it can be shown to clients.
+func (c *Client) Preview(cmd Command) (string, error) {
+	redacted := *c
+	if requiresAuth(cmd) {
+		redacted.authToken = "REDACTED"
+	}
+	return redacted.URL(cmd)
+}
+
+// Run builds the URL for cmd and opens it.
+func (c *Client) Run(cmd Command) error {
+	thingsURL, err := c.URL(cmd)
+	if err != nil {
+		return err
+	}
+	open := c.opener
+	if open == nil {
+		open = DefaultOpener
+	}
+	return open(thingsURL)
+}
+
+// Build returns a things:/// URL for the named command and parameters.
+// Things expects percent-encoding (%20), not form-encoding (+), so spaces are
+// re-encoded after url.Values.Encode; literal plus signs are already %2B.
+func Build(name string, params url.Values) string {
+	u := "things:///" + name
+	if len(params) == 0 {
+		return u
+	}
+	return u + "?" + strings.ReplaceAll(params.Encode(), "+", "%20")
+}
+
+// requiresAuth reports whether Things requires auth-token for cmd.
+func requiresAuth(cmd Command) bool {
+	switch c := cmd.(type) {
+	case Update, *Update, UpdateProject, *UpdateProject:
+		return true
+	case JSON:
+		return c.hasUpdates()
+	case *JSON:
+		return c.hasUpdates()
+	default:
+		return false
+	}
+}
+
+// setString sets key when value is non-empty.
+func setString(params url.Values, key, value string) {
+	if value != "" {
+		params.Set(key, value)
+	}
+}
+
+// setOptional sets key when value is non-nil. An empty string is kept so
+// that update commands can clear a field.
+func setOptional(params url.Values, key string, value *string) {
+	if value != nil {
+		params.Set(key, *value)
+	}
+}
+
+// setBool sets key to "true" when value is true.
+func setBool(params url.Values, key string, value bool) {
+	if value {
+		params.Set(key, "true")
+	}
+}
+
+// setOptionalBool sets key to "true" or "false" when value is non-nil.
+func setOptionalBool(params url.Values, key string, value *bool) {
+	if value != nil {
+		if *value {
+			params.Set(key, "true")
+		} else {
+			params.Set(key, "false")
+		}
+	}
+}
+
+// setList joins values with sep and sets key when values is non-empty.
+func setList(params url.Values, key string, values []string, sep string) {
+	if len(values) > 0 {
+		params.Set(key, strings.Join(values, sep))
+	}
+}
@@ -0,0 +1,198 @@
+package thingsurl
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name   string
+		cmd    string
+		params url.Values
+		want   string
+	}{
+		{
+			name: "no parameters",
+			cmd:  "show",
+			want: "things:///show",
+		},
+		{
+			name:   "spaces are percent-encoded",
+			cmd:    "add",
+			params: url.Values{"title": {"Buy milk and eggs"}},
+			want:   "things:///add?title=Buy%20milk%20and%20eggs",
+		},
+		{
+			name:   "plus signs stay literal",
+			cmd:    "add",
+			params: url.Values{"title": {"1+1 = 2"}},
+			want:   "things:///add?title=1%2B1%20%3D%202",
+		},
+		{
+			name:   "parameters are sorted",
+			cmd:    "add",
+			params: url.Values{"when": {"today"}, "title": {"A"}, "notes": {"B"}},
+			want:   "things:///add?notes=B&title=A&when=today",
+		},
+		{
+			name:   "newlines and unicode",
+			cmd:    "add",
+			params: url.Values{"checklist-items": {"Молоко\nЯйца"}},
+			want:   "things:///add?checklist-items=%D0%9C%D0%BE%D0%BB%D0%BE%D0%BA%D0%BE%0A%D0%AF%D0%B9%D1%86%D0%B0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Build(tt.cmd, tt.params); got != tt.want {
+				t.Errorf("Build = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientURL(t *testing.T) {
+	title := "New title"
+	empty := ""
+	done := true
+
+	tests := []struct {
+		name    string
+		token   string
+		cmd     Command
+		want    string
+		wantErr string
+	}{
+		{
+			name: "add with checklist",
+			cmd:  Add{Title: "Pack", ChecklistItems: []string{"Socks", "Shirts"}, Tags: []string{"travel", "home"}, ListID: "PRJ"},
+			want: "things:///add?checklist-items=Socks%0AShirts&list-id=PRJ&show-quick-entry=false&tags=travel%2Chome&title=Pack",
+		},
+		{
+			name: "add does not need a token",
+			cmd:  Add{Title: "A"},
+			want: "things:///add?show-quick-entry=false&title=A",
+		},
+		{
+			name:    "add without a title",
+			cmd:     Add{Notes: "x"},
+			wantErr: "invalid add command: title is required",
+		},
+		{
+			name:  "update carries the token",
+			token: "secret",
+			cmd:   Update{ID: "T1", Title: &title, Completed: &done},
+			want:  "things:///update?auth-token=secret&completed=true&id=T1&title=New%20title",
+		},
+		{
+			name:  "update clears a field with an empty value",
+			token: "secret",
+			cmd:   Update{ID: "T1", Deadline: &empty, ChecklistItems: []string{}},
+			want:  "things:///update?auth-token=secret&checklist-items=&deadline=&id=T1",
+		},
+		{
+			name:    "update without a token",
+			cmd:     Update{ID: "T1", Title: &title},
+			wantErr: "update command requires a Things URL auth token",
+		},
+		{
+			name:    "update-project without a token",
+			cmd:     &UpdateProject{ID: "P1"},
+			wantErr: "update-project command requires a Things URL auth token",
+		},
+		{
+			name:    "update without an id",
+			token:   "secret",
+			cmd:     Update{},
+			wantErr: "invalid update command: id is required",
+		},
+		{
+			name: "json create needs no token",
+			cmd: JSON{Items: []Item{{
+				Type: TypeToDo,
+				Attributes: Attributes{Title: "Pack", ChecklistItems: []Item{
+					{Type: TypeChecklistItem, Attributes: Attributes{Title: "Socks"}},
+				}},
+			}}},
+			want: "things:///json?data=" + url.QueryEscape(`[{"type":"to-do","attributes":{"title":"Pack","checklist-items":[{"type":"checklist-item","attributes":{"title":"Socks"}}]}}]`),
+		},
+		{
+			name:    "json update needs a token",
+			cmd:     JSON{Items: []Item{{Type: TypeToDo, Operation: OperationUpdate, ID: "T1"}}},
+			wantErr: "json command requires a Things URL auth token",
+		},
+		{
+			name:  "json update with a token",
+			token: "secret",
+			cmd:   &JSON{Items: []Item{{Type: TypeToDo, Operation: OperationUpdate, ID: "T1", Attributes: Attributes{Completed: true}}}, Reveal: true},
+			want:  "things:///json?auth-token=secret&data=" + url.QueryEscape(`[{"type":"to-do","operation":"update","id":"T1","attributes":{"completed":true}}]`) + "&reveal=true",
+		},
+		{
+			name:    "json without items",
+			cmd:     JSON{},
+			wantErr: "invalid json command: at least one item is required",
+		},
+		{
+			name: "show by query",
+			cmd:  Show{Query: "Work", Filter: []string{"urgent"}},
+			want: "things:///show?filter=urgent&query=Work",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewClient(tt.token, nil).URL(tt.cmd)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("URL error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// url.QueryEscape form-encodes spaces; Build never does.
+			want := strings.ReplaceAll(tt.want, "+", "%20")
+			if got != want {
+				t.Errorf("URL =\n %s\nwant\n %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPreviewRedactsToken(t *testing.T) {
+	c := NewClient("secret", nil)
+	got, err := c.Preview(Update{ID: "T1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "things:///update?auth-token=REDACTED&id=T1" {
+		t.Errorf("Preview = %s", got)
+	}
+
+	// Commands that need no token are previewed as is, and the client
+	// keeps its token.
+	if got, _ := c.Preview(Search{Query: "milk"}); got != "things:///search?query=milk" {
+		t.Errorf("Preview = %s", got)
+	}
+	if got, _ := c.URL(Update{ID: "T1"}); !strings.Contains(got, "auth-token=secret") {
+		t.Errorf("URL after Preview = %s", got)
+	}
+}
+
+func TestRunUsesOpener(t *testing.T) {
+	var opened []string
+	c := NewClient("", func(u string) error {
+		opened = append(opened, u)
+		return nil
+	})
+	if err := c.Run(Add{Title: "A"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(Add{}); err == nil {
+		t.Error("Run of an invalid command succeeded")
+	}
+	if len(opened) != 1 || opened[0] != "things:///add?show-quick-entry=false&title=A" {
+		t.Errorf("opened %q", opened)
+	}
+}
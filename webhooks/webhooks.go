@@ -0,0 +1,346 @@
+// Package webhooks delivers change feed events to registered URLs.
+//
+// Deliveries are queued durably in an append-only store log and retried with
+// exponential backoff. Each request body is the event JSON, signed with the
+// webhook's secret:
+//
+//	X-Things-Signature: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+//
+// where timestamp is the X-Things-Timestamp header (Unix seconds).
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+const (
+	// maxAttempts is the number of attempts before a delivery is marked failed.
+	maxAttempts = 8
+	// baseBackoff is the delay after the first failed attempt; it doubles
+	// after each further failure.
+	baseBackoff = 30 * time.Second
+	// deliveredRetention and failedRetention are how long succeeded and
+	// failed deliveries are kept for the delivery log. Failed ones are kept
+	// longer so they can be replayed.
+	deliveredRetention = time.Hour
+	failedRetention    = 7 * 24 * time.Hour
+	// maxIdle is the longest the queue goes unchecked, so that deliveries of
+	// a reactivated webhook resume.
+	maxIdle = time.Minute
+)
+
+// timeFormat is a fixed-width UTC timestamp, so stored times compare
+// correctly as strings.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// Dispatcher queues events for matching webhooks and delivers them.
+type Dispatcher struct {
+	hooks      *store.Collection[models.Webhook]
+	deliveries *store.Log[models.WebhookDelivery]
+	client     *http.Client
+	wake       chan struct{}
+}
+
+// NewDispatcher returns a dispatcher over the given stores.
+func NewDispatcher(hooks *store.Collection[models.Webhook], deliveries *store.Log[models.WebhookDelivery]) *Dispatcher {
+	return &Dispatcher{
+		hooks:      hooks,
+		deliveries: deliveries,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// OpenQueue opens the delivery queue at path. Deliveries that earlier
+// versions kept in a single JSON file at legacyPath are moved into it and
+// the file is removed.
+func OpenQueue(path, legacyPath string) (*store.Log[models.WebhookDelivery], error) {
+	queue, err := store.OpenLog[models.WebhookDelivery](path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		return queue, nil
+	}
+	legacy, err := store.Open[models.WebhookDelivery](legacyPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, del := range legacy.List() {
+		if err := queue.Put(del.ID, del); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.Remove(legacyPath); err != nil {
+		return nil, fmt.Errorf("cannot remove %s: %w", legacyPath, err)
+	}
+	return queue, nil
+}
+
+// Enqueue queues e for every active webhook whose filters match it.
+func (d *Dispatcher) Enqueue(e models.Event) {
+	queued := false
+	for _, hook := range d.hooks.List() {
+		if !hook.Active || !hook.Matches(e) {
+			continue
+		}
+		if _, err := d.queue(hook.ID, e, ""); err != nil {
+			slog.Error("webhooks: cannot queue delivery", "webhook", hook.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		d.notify()
+	}
+}
+
+// Replay queues a new delivery of an earlier delivery's event.
+func (d *Dispatcher) Replay(delivery models.WebhookDelivery) (models.WebhookDelivery, error) {
+	replay, err := d.queue(delivery.WebhookID, delivery.Event, delivery.ID)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	d.notify()
+	return replay, nil
+}
+
+// Deliveries returns the deliveries for a webhook, newest first.
+func (d *Dispatcher) Deliveries(webhookID string) []models.WebhookDelivery {
+	result := []models.WebhookDelivery{}
+	for _, del := range d.deliveries.List() {
+		if del.WebhookID == webhookID {
+			result = append(result, del)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt > result[j].CreatedAt
+	})
+	return result
+}
+
+// DeleteDeliveries removes all deliveries for a webhook.
+func (d *Dispatcher) DeleteDeliveries(webhookID string) error {
+	_, err := d.deliveries.DeleteWhere(func(del models.WebhookDelivery) bool {
+		return del.WebhookID == webhookID
+	})
+	return err
+}
+
+// Run delivers queued events until ctx is canceled. Deliveries still pending
+// from an earlier run are resumed. Between rounds it sleeps until the next
+// retry is due or an event is queued.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-d.wake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		}
+
+		wait := d.deliverDue(ctx)
+		d.prune()
+		timer.Reset(wait)
+	}
+}
+
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Dispatcher) queue(webhookID string, e models.Event, replayOf string) (models.WebhookDelivery, error) {
+	now := time.Now().UTC().Format(timeFormat)
+	del := models.WebhookDelivery{
+		ID:            store.NewID(),
+		WebhookID:     webhookID,
+		Event:         e,
+		Status:        models.DeliveryPending,
+		Attempts:      []models.WebhookAttempt{},
+		NextAttemptAt: now,
+		ReplayOf:      replayOf,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := d.deliveries.Put(del.ID, del); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return del, nil
+}
+
+// deliverDue attempts every pending delivery whose next attempt is due, oldest
+// first, and returns how long until the next one is, at most maxIdle.
+// Deliveries for inactive webhooks stay pending until reactivated.
+func (d *Dispatcher) deliverDue(ctx context.Context) time.Duration {
+	now := time.Now().UTC().Format(timeFormat)
+	var due []models.WebhookDelivery
+	for _, del := range d.deliveries.List() {
+		if del.Status == models.DeliveryPending && del.NextAttemptAt <= now {
+			due = append(due, del)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].CreatedAt < due[j].CreatedAt })
+
+	for _, del := range due {
+		if ctx.Err() != nil {
+			return 0
+		}
+		hook, ok := d.hooks.Get(del.WebhookID)
+		if !ok {
+			_, _ = d.deliveries.Delete(del.ID)
+			continue
+		}
+		if !hook.Active {
+			continue
+		}
+		d.attempt(ctx, hook, del)
+	}
+
+	wait := maxIdle
+	for _, del := range d.deliveries.List() {
+		if del.Status != models.DeliveryPending {
+			continue
+		}
+		if hook, ok := d.hooks.Get(del.WebhookID); !ok || !hook.Active {
+			continue
+		}
+		next, err := time.Parse(timeFormat, del.NextAttemptAt)
+		if err != nil {
+			continue
+		}
+		if w := time.Until(next); w < wait {
+			wait = max(w, 0)
+		}
+	}
+	return wait
+}
+
+// attempt sends one delivery and records the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, hook models.Webhook, del models.WebhookDelivery) {
+	start := time.Now()
+	code, err := d.send(ctx, hook, del)
+
+	a := models.WebhookAttempt{
+		At:         start.UTC().Format(time.RFC3339),
+		StatusCode: code,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		a.Error = err.Error()
+	}
+	del.Attempts = append(del.Attempts, a)
+	del.UpdatedAt = time.Now().UTC().Format(timeFormat)
+
+	switch {
+	case err == nil:
+		del.Status = models.DeliverySucceeded
+		del.NextAttemptAt = ""
+	case len(del.Attempts) >= maxAttempts:
+		del.Status = models.DeliveryFailed
+		del.NextAttemptAt = ""
+		slog.Warn("webhooks: delivery failed", "webhook", hook.ID, "delivery", del.ID, "error", err)
+	default:
+		backoff := baseBackoff << (len(del.Attempts) - 1)
+		del.NextAttemptAt = time.Now().Add(backoff).UTC().Format(timeFormat)
+	}
+
+	if err := d.deliveries.Put(del.ID, del); err != nil {
+		slog.Error("webhooks: cannot save delivery", "delivery", del.ID, "error", err)
+	}
+}
+
+// send posts the delivery's event to the webhook URL. Any 2xx response is a
+// success.
+func (d *Dispatcher) send(ctx context.Context, hook models.Webhook, del models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(del.Event)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "things3-api-webhooks")
+	req.Header.Set("X-Things-Event", del.Event.Action)
+	req.Header.Set("X-Things-Delivery", del.ID)
+	req.Header.Set("X-Things-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Things-Signature", "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// prune removes succeeded deliveries older than deliveredRetention and
+// failed ones older than failedRetention, in one write.
+func (d *Dispatcher) prune() {
+	now := time.Now()
+	delivered := now.Add(-deliveredRetention).UTC().Format(timeFormat)
+	failed := now.Add(-failedRetention).UTC().Format(timeFormat)
+	_, err := d.deliveries.DeleteWhere(func(del models.WebhookDelivery) bool {
+		switch del.Status {
+		case models.DeliverySucceeded:
+			return del.UpdatedAt < delivered
+		case models.DeliveryFailed:
+			return del.UpdatedAt < failed
+		}
+		return false
+	})
+	if err != nil {
+		slog.Error("webhooks: cannot prune deliveries", "error", err)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" with secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("crypto/rand failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/egorkaBurkenya/things3-api/models"
+	"github.com/egorkaBurkenya/things3-api/store"
+)
+
+func TestDispatcherDelivers(t *testing.T) {
+	received := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Things-Delivery")
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	hooks, err := store.Open[models.Webhook](filepath.Join(dir, "webhooks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	queue, err := OpenQueue(filepath.Join(dir, "webhook-deliveries.jsonl"), filepath.Join(dir, "webhook-deliveries.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	hooks.Put("H", models.Webhook{ID: "H", URL: srv.URL, Secret: "0123456789abcdef", Resources: []string{"task"}, Active: true})
+
+	d := NewDispatcher(hooks, queue)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	d.Enqueue(models.Event{Action: models.ActionCreated, Type: models.ItemTypeArea, ItemID: "A"})
+	d.Enqueue(models.Event{Action: models.ActionCreated, Type: models.ItemTypeTask, ItemID: "T"})
+
+	select {
+	case id := <-received:
+		del, ok := queue.Get(id)
+		if !ok || del.Event.ItemID != "T" {
+			t.Fatalf("delivered %+v", del)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery within 5s")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		dels := d.Deliveries("H")
+		if len(dels) == 1 && dels[0].Status == models.DeliverySucceeded {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries = %+v", dels)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestOpenQueueMovesLegacyDeliveries(t *testing.T) {
+	dir := t.TempDir()
+	legacyPath := filepath.Join(dir, "webhook-deliveries.json")
+	legacy, err := store.Open[models.WebhookDelivery](legacyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Put("D", models.WebhookDelivery{ID: "D", WebhookID: "H", Status: models.DeliveryPending})
+
+	queue, err := OpenQueue(filepath.Join(dir, "webhook-deliveries.jsonl"), legacyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer queue.Close()
+	if del, ok := queue.Get("D"); !ok || del.Status != models.DeliveryPending {
+		t.Errorf("legacy delivery not moved: %+v", del)
+	}
+	if _, err := os.Stat(legacyPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("legacy file not removed: %v", err)
+	}
+}